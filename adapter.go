@@ -22,28 +22,81 @@ package casbinbunadapter
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/casbin/casbin/v2/model"
 	"github.com/casbin/casbin/v2/persist"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/dialect/mssqldialect"
 	"github.com/uptrace/bun/dialect/mysqldialect"
 	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
 
 	"github.com/pkg/errors"
 )
 
 const (
+	// DefaultSchemaName is the schema used unless WithTableName sets one. It
+	// is ignored on MySQL, which has no such schema.
 	DefaultSchemaName = "public"
 	DefaultTableName  = "casbin_rule"
 )
 
+// maxFields is the number of V columns in CasbinRule.
+const maxFields = 8
+
 var (
-	ErrUnknownDriver = errors.New("unknown driver")
+	ErrUnknownDriver     = errors.New("unknown driver")
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+)
+
+// identifierRegexp matches the schema, table and column names the adapter
+// accepts; they end up in SQL unquoted.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func validateIdentifier(name string) error {
+	if !identifierRegexp.MatchString(name) {
+		return errors.Wrapf(ErrInvalidIdentifier, "%q", name)
+	}
+	return nil
+}
+
+// validateQualifiedName checks a name made of dot separated identifiers.
+func validateQualifiedName(name string) error {
+	for _, part := range strings.Split(name, ".") {
+		if !identifierRegexp.MatchString(part) {
+			return errors.Wrapf(ErrInvalidIdentifier, "%q", name)
+		}
+	}
+	return nil
+}
+
+// validateTableName checks a schema and table pair; the schema may be empty.
+// Qualified table names are only accepted with WithTableAlreadyPrefixed.
+func (a *Adapter) validateTableName(schema, table string) error {
+	if a.tablePrefixed {
+		return validateQualifiedName(table)
+	}
+	if schema != "" {
+		if err := validateIdentifier(schema); err != nil {
+			return err
+		}
+	}
+	return validateIdentifier(table)
+}
+
+var (
+	_ persist.Adapter          = (*Adapter)(nil)
+	_ persist.FilteredAdapter  = (*Adapter)(nil)
+	_ persist.BatchAdapter     = (*Adapter)(nil)
+	_ persist.UpdatableAdapter = (*Adapter)(nil)
 )
 
 type Adapter struct {
@@ -53,20 +106,155 @@ type Adapter struct {
 	filtered bool
 
 	schemaName string
+	schemaSet  bool
 	tableName  string
+
+	readTableName string
+	tablePrefixed bool
+	resetIdentity bool
+
+	logger  Logger
+	logArgs bool
+
+	conflictColumns map[string][]string
+
+	afterLoad  func(*CasbinRule)
+	beforeSave func(ptype string, rule []string) []string
+	rowMapper  func(*CasbinRule) (ptype string, tokens []string)
+
+	encryptor        Encryptor
+	encryptedColumns []int
+	codecs           map[int]ValueCodec
+
+	tableNameFunc func(ctx context.Context) (schema, table string, err error)
+
+	extraColumn     string
+	extraSerializer func(ptype string, rule []string) ([]byte, error)
+
+	stmts      *stmtCache
+	ownsClient bool
+
+	errorMapper func(error) error
+
+	timestampColumn string
+
+	connInit func(ctx context.Context, conn driver.Conn) error
+
+	consistentLoad bool
+
+	replica        *bun.DB
+	replicaMaxLag  time.Duration
+	replicaLagFunc func(ctx context.Context, replica *bun.DB) (time.Duration, error)
+
+	columnCount int
+	nullSafe    bool
+
+	inChunkSize int
+
+	validateModel model.Model
+
+	quoteIdents bool
+
+	skipExisting bool
+
+	loadRetryAttempts  int
+	loadRetryPredicate func(model.Model) bool
+
+	insertOrder []string
+
+	spannerDialect schema.Dialect
+
+	tableCreateHook func(q *bun.CreateTableQuery) *bun.CreateTableQuery
+
+	auditTable string
+
+	txRetryAttempts int
+	deadlockRetries int
+	retryMaxElapsed time.Duration
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	ptypeTables map[string][2]string
+
+	strictLoad bool
+
+	inPlaceUpdate bool
+
+	migrationVersion int
+
+	bulkSave bool
+	staging  stagingMethod
+
+	ptypeColumn string
+
+	cache *policyCache
+
+	opLogger OpLogger
+
+	fkChecksDisabled bool
+
+	maxLoadRows int
+
+	shardTables   []string
+	shardKeyIndex int
+
+	addIdempotent bool
+
+	nullEmpty bool
+
+	queryComments bool
+
+	idColumn string
+
+	execInterceptor ExecInterceptor
+
+	saveChunkRows int
+
+	connectAttempts int
+	connectBackoff  time.Duration
+
+	loadTimeoutBase        time.Duration
+	loadTimeoutPerThousand time.Duration
+
+	collation string
+
+	outboxTable   string
+	outboxMarshal func(op, ptype string, rule []string) ([]byte, error)
+
+	cursorFetchSize int
+
+	model          reflect.Value
+	modelRuleIndex int
+
+	guardEmptySave bool
+
+	columnDefs []ColumnDefinition
+
+	scanBufferRows int
+
+	detectDuplicates bool
 }
 
 type CasbinRule struct {
 	Id    int64  `bun:"id,pk,autoincrement"`
 	Ptype string `bun:",nullzero,notnull"`
-	V0    string `bun:",nullzero,notnull"`
-	V1    string `bun:",nullzero,notnull"`
-	V2    string `bun:",nullzero,notnull"`
-	V3    string `bun:",nullzero,notnull"`
-	V4    string `bun:",nullzero,notnull"`
-	V5    string `bun:",nullzero,notnull"`
-	V6    string `bun:",nullzero,notnull"`
-	V7    string `bun:",nullzero,notnull"`
+	V0    string `bun:",nullzero,notnull,default:''"`
+	V1    string `bun:",nullzero,notnull,default:''"`
+	V2    string `bun:",nullzero,notnull,default:''"`
+	V3    string `bun:",nullzero,notnull,default:''"`
+	V4    string `bun:",nullzero,notnull,default:''"`
+	V5    string `bun:",nullzero,notnull,default:''"`
+	V6    string `bun:",nullzero,notnull,default:''"`
+	V7    string `bun:",nullzero,notnull,default:''"`
+
+	// Extra holds the column configured by WithExtraColumn, if any.
+	Extra []byte `bun:"extra,scanonly"`
+}
+
+// fields returns pointers to the V columns of the rule, in column order.
+func (r *CasbinRule) fields() []*string {
+	return []*string{&r.V0, &r.V1, &r.V2, &r.V3, &r.V4, &r.V5, &r.V6, &r.V7}
 }
 
 type Filter struct {
@@ -77,20 +265,139 @@ type Filter struct {
 	V3    []string
 	V4    []string
 	V5    []string
+
+	// VnEmpty restricts the load to rules whose Vn is empty or NULL, e.g.
+	// to load only the short rules of a ptype.
+	V0Empty bool
+	V1Empty bool
+	V2Empty bool
+	V3Empty bool
+	V4Empty bool
+	V5Empty bool
 }
 
 type Option func(a *Adapter) error
 
+// WithTableName sets the schema and table the policy rules are stored in.
+// An empty schema leaves the table unqualified, so it is resolved by the
+// database (the search_path on Postgres) for every read and write alike.
 func WithTableName(schema, table string) Option {
 	return func(a *Adapter) error {
+		if schema != "" {
+			if err := validateIdentifier(schema); err != nil {
+				return err
+			}
+		}
+		// The table may be qualified with WithTableAlreadyPrefixed, which
+		// can come later; newAdapter checks it once all options are set.
+		if err := validateQualifiedName(table); err != nil {
+			return err
+		}
 		a.schemaName = schema
+		a.schemaSet = true
 		a.tableName = table
 		return nil
 	}
 }
 
-func open(driverName, dataSourceName string) (*bun.DB, error) {
-	db, err := sql.Open(driverName, dataSourceName)
+// WithTableNameFunc resolves the schema and table of every operation from its
+// context instead of using a fixed name, e.g. to route tenants to their own
+// tables. It takes precedence over WithTableName. The methods without a
+// context argument resolve the table with context.Background().
+func WithTableNameFunc(fn func(ctx context.Context) (schema, table string, err error)) Option {
+	return func(a *Adapter) error {
+		a.tableNameFunc = fn
+		return nil
+	}
+}
+
+// WithReadTable makes LoadPolicy and LoadFilteredPolicy read from another
+// table or view in the same schema, e.g. a materialized view of effective
+// policies. All writes still go to the table set by WithTableName.
+func WithReadTable(name string) Option {
+	return func(a *Adapter) error {
+		if err := validateQualifiedName(name); err != nil {
+			return err
+		}
+		a.readTableName = name
+		return nil
+	}
+}
+
+// WithTableAlreadyPrefixed uses the table set by WithTableName or
+// WithTableNameFunc verbatim, without joining the schema to it, for fully
+// qualified names such as otherdb.dbo.casbin_rule. The read table set by
+// WithReadTable is then used verbatim as well.
+func WithTableAlreadyPrefixed() Option {
+	return func(a *Adapter) error {
+		a.tablePrefixed = true
+		return nil
+	}
+}
+
+// WithTruncateResetIdentity makes SavePolicy restart the id sequence after
+// truncating the table, so ids start from 1 again on every dialect.
+func WithTruncateResetIdentity(reset bool) Option {
+	return func(a *Adapter) error {
+		a.resetIdentity = reset
+		return nil
+	}
+}
+
+// WithAfterLoad sets a hook run on every row read by LoadPolicy and
+// LoadFilteredPolicy before it is added to the model. The hook may modify
+// the row.
+func WithAfterLoad(fn func(*CasbinRule)) Option {
+	return func(a *Adapter) error {
+		a.afterLoad = fn
+		return nil
+	}
+}
+
+// WithRowMapper replaces the default conversion of a stored row to a policy
+// line on load: fn returns the ptype and tokens the row is loaded as. It runs
+// after the after-load hook.
+func WithRowMapper(fn func(*CasbinRule) (ptype string, tokens []string)) Option {
+	return func(a *Adapter) error {
+		a.rowMapper = fn
+		return nil
+	}
+}
+
+// WithNullSafeMatching makes the removes, updates and lookups of rules treat
+// NULL V columns like empty strings, for tables that store unused V columns
+// as NULL instead of ”.
+func WithNullSafeMatching() Option {
+	return func(a *Adapter) error {
+		a.nullSafe = true
+		return nil
+	}
+}
+
+// WithInPlaceUpdate makes UpdatePolicies update the matching rows of each old
+// rule to the new rule at the same index, keeping their ids, instead of
+// deleting the old rules and inserting the new ones.
+func WithInPlaceUpdate() Option {
+	return func(a *Adapter) error {
+		a.inPlaceUpdate = true
+		return nil
+	}
+}
+
+// WithBeforeSave sets a hook that rewrites a rule before it is stored. The
+// rewritten rule is used both for writes and for matching in removes and
+// updates, so a rule removed with the same input it was added with still
+// matches. RemoveFilteredPolicy and UpdateFilteredPolicies match on partial
+// field values and do not run the hook.
+func WithBeforeSave(fn func(ptype string, rule []string) []string) Option {
+	return func(a *Adapter) error {
+		a.beforeSave = fn
+		return nil
+	}
+}
+
+func (a *Adapter) open(driverName, dataSourceName string) (*bun.DB, error) {
+	db, err := openDB(driverName, dataSourceName, a.connInit)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +409,12 @@ func open(driverName, dataSourceName string) (*bun.DB, error) {
 		b = bun.NewDB(db, mysqldialect.New())
 	case "mssql":
 		b = bun.NewDB(db, mssqldialect.New())
+	case "spanner":
+		if a.spannerDialect == nil {
+			_ = db.Close()
+			return nil, ErrSpannerDialect
+		}
+		b = bun.NewDB(db, a.spannerDialect)
 	default:
 		return nil, ErrUnknownDriver
 	}
@@ -110,29 +423,48 @@ func open(driverName, dataSourceName string) (*bun.DB, error) {
 
 // NewAdapter returns an adapter by driver name and data source string.
 func NewAdapter(driverName, dataSourceName string, options ...Option) (*Adapter, error) {
-	client, err := open(driverName, dataSourceName)
+	a, err := newAdapter(options...)
 	if err != nil {
 		return nil, err
 	}
-	a := &Adapter{
-		client:     client,
-		ctx:        context.Background(),
-		schemaName: DefaultSchemaName,
-		tableName:  DefaultTableName,
+	client, err := a.open(driverName, dataSourceName)
+	if err != nil {
+		return nil, redactError(err)
 	}
-	for _, option := range options {
-		if err := option(a); err != nil {
-			return nil, err
+	if err := a.checkAddIdempotent(client.Dialect().Name()); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	if a.connectAttempts > 0 {
+		if err := a.ping(client); err != nil {
+			_ = client.Close()
+			return nil, redactError(errors.Wrap(err, "connecting to database"))
 		}
 	}
+	a.setClient(client)
+	a.ownsClient = true
 	return a, nil
 }
 
 // NewAdapterWithClient create an adapter with client passed in.
 // This method does not ensure the existence of database, user should create database manually.
 func NewAdapterWithClient(client *bun.DB, options ...Option) (*Adapter, error) {
+	a, err := newAdapter(options...)
+	if err != nil {
+		return nil, err
+	}
+	if a.connInit != nil {
+		return nil, ErrConnInitWithClient
+	}
+	if err := a.checkAddIdempotent(client.Dialect().Name()); err != nil {
+		return nil, err
+	}
+	a.setClient(client)
+	return a, nil
+}
+
+func newAdapter(options ...Option) (*Adapter, error) {
 	a := &Adapter{
-		client:     client,
 		ctx:        context.Background(),
 		schemaName: DefaultSchemaName,
 		tableName:  DefaultTableName,
@@ -142,196 +474,895 @@ func NewAdapterWithClient(client *bun.DB, options ...Option) (*Adapter, error) {
 			return nil, err
 		}
 	}
+	if err := a.validateTableName(a.schemaName, a.tableName); err != nil {
+		return nil, err
+	}
+	if a.readTableName != "" {
+		if err := a.validateTableName("", a.readTableName); err != nil {
+			return nil, err
+		}
+	}
+	if a.sharded() && len(a.ptypeTables) > 0 {
+		return nil, errors.New("WithHashSharding cannot be combined with WithPtypeTable")
+	}
+	if err := a.checkModel(); err != nil {
+		return nil, err
+	}
 	return a, nil
 }
 
-func (a *Adapter) getFullTableName() string {
-	if a.schemaName == "" {
-		return a.tableName
+func (a *Adapter) setClient(client *bun.DB) {
+	a.client = client
+	if !a.schemaSet && client.Dialect().Name() == dialect.MySQL {
+		// MySQL has no public schema; an unqualified table lives in the
+		// database of the connection.
+		a.schemaName = ""
+	}
+	if a.logger != nil {
+		client.AddQueryHook(&queryLogger{logger: a.logger, args: a.logArgs})
+	}
+	if a.opLogger != nil {
+		client.AddQueryHook(&opCounter{adapter: a})
+	}
+	if a.bulkSave {
+		name := client.Dialect().Name()
+		a.staging = stagingMethodOf(name, client.Driver())
+		if a.staging == stagingInsert && (name == dialect.PG || name == dialect.MySQL) && a.logger != nil {
+			a.logger.Debugf("bulk save: driver %T has neither COPY nor LOAD DATA support, staging rows with INSERTs", client.Driver())
+		}
+	}
+}
+
+// Close releases the resources held by the adapter. The database connection
+// is only closed if it was opened by NewAdapter.
+func (a *Adapter) Close() error {
+	var err error
+	if a.stmts != nil {
+		err = a.stmts.close()
+	}
+	if a.ownsClient {
+		if cerr := a.client.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return redactError(err)
+}
+
+// DB returns the underlying bun client, for running custom queries on the
+// same connection. Callers must not close it unless they created it and passed
+// it to NewAdapterWithClient; use Close instead.
+func (a *Adapter) DB() *bun.DB {
+	return a.client
+}
+
+// resolveTable returns the schema and table of the operation running with ctx.
+func (a *Adapter) resolveTable(ctx context.Context) (string, string, error) {
+	if a.tableNameFunc == nil {
+		return a.schemaName, a.tableName, nil
+	}
+	schema, table, err := a.tableNameFunc(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if err := a.validateTableName(schema, table); err != nil {
+		return "", "", err
+	}
+	return schema, table, nil
+}
+
+func (a *Adapter) getFullTableName(ctx context.Context) (string, error) {
+	schema, table, err := a.resolveTable(ctx)
+	if err != nil {
+		return "", err
+	}
+	return a.joinTableName(schema, table), nil
+}
+
+// getReadTableName returns the table or view policies are loaded from.
+func (a *Adapter) getReadTableName(ctx context.Context) (string, error) {
+	schema, table, err := a.resolveTable(ctx)
+	if err != nil {
+		return "", err
+	}
+	if a.readTableName != "" {
+		table = a.readTableName
+	}
+	return a.joinTableName(schema, table), nil
+}
+
+func (a *Adapter) joinTableName(schema, table string) string {
+	if a.quoteIdents {
+		parts := strings.Split(table, ".")
+		for i, part := range parts {
+			parts[i] = a.quoteIdent(part)
+		}
+		table = strings.Join(parts, ".")
+		if schema != "" {
+			schema = a.quoteIdent(schema)
+		}
+	}
+	if schema == "" || a.tablePrefixed {
+		return table
+	}
+	return schema + "." + table
+}
+
+// modelTableExpr returns the given table aliased the way bun refers to the
+// CasbinRule model, so model-based selects resolve their columns against the
+// configured table rather than the one bun derives from the struct.
+func (a *Adapter) modelTableExpr(fullTableName string) string {
+	table := a.client.Table(reflect.TypeOf((*CasbinRule)(nil)).Elem())
+	return fullTableName + " AS " + string(table.SQLAlias)
+}
+
+// newSelect returns a query selecting stored rows of table into dest. Only
+// the id, ptype and V columns in use are selected, so other columns of wide
+// tables are never fetched.
+func (a *Adapter) newSelect(ctx context.Context, db bun.IDB, dest interface{}, table string) *bun.SelectQuery {
+	q := db.NewSelect().Conn(a.queryConn(ctx, db)).Model(dest).ModelTableExpr(a.modelTableExpr(table))
+	if a.idRenamed() {
+		q.ColumnExpr("? AS id", bun.Ident(a.idColumnName()))
+	} else {
+		q.Column("id")
+	}
+	if a.ptypeRenamed() {
+		q.ColumnExpr("? AS ptype", bun.Ident(a.ptypeColumnName()))
+	} else {
+		q.Column("ptype")
+	}
+	q.Column(a.policyColumns()[1:]...)
+	if a.extraColumn != "" {
+		q.ColumnExpr("? AS extra", bun.Ident(a.extraColumn))
+	}
+	return q
+}
+
+// insertQuery returns the query inserting a single row into table.
+func (a *Adapter) insertQuery(ctx context.Context, db bun.IDB, table string, line *CasbinRule) *bun.InsertQuery {
+	q := db.NewInsert().Conn(a.queryConn(ctx, db)).Model(a.rowModel(line)).ModelTableExpr(table)
+	if a.ptypeRenamed() || a.idRenamed() {
+		// Insert the model's ptype and id under the configured columns
+		// instead.
+		columns := make([]string, 0, maxFields+2)
+		for _, column := range a.insertColumns() {
+			switch {
+			case a.ptypeRenamed() && column == a.ptypeColumnName():
+				q.Value(column, "?", line.Ptype)
+			case a.idRenamed() && column == a.idColumnName():
+				q.Value(column, "?", line.Id)
+			default:
+				columns = append(columns, column)
+			}
+		}
+		q.Column(append(columns, a.modelColumns()...)...)
+	} else if a.fieldCount() < maxFields {
+		q.Column(append(a.insertColumns(), a.modelColumns()...)...)
+	}
+	if a.extraColumn != "" {
+		q.Value(a.extraColumn, "?", line.Extra)
+	}
+	a.setNullFields(q, line)
+	return a.ignoreDuplicates(q)
+}
+
+// insertRules inserts lines into table and returns the number of rows written.
+func (a *Adapter) insertRules(ctx context.Context, db bun.IDB, table string, lines []*CasbinRule) (int64, error) {
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if a.extraColumn != "" || a.ptypeRenamed() || a.nullEmpty || (a.idRenamed() && a.spannerDialect != nil) {
+		// bun can only add a column that is not part of the model, or
+		// set the value of a column per row, in single-row inserts.
+		var n int64
+		for _, line := range lines {
+			res, err := a.insertQuery(ctx, db, table, line).Exec(ctx)
+			if err != nil {
+				return 0, err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return 0, err
+			}
+			n += affected
+		}
+		return n, nil
+	}
+	q := db.NewInsert().Conn(a.queryConn(ctx, db)).Model(a.rowModels(lines)).ModelTableExpr(table)
+	if a.fieldCount() < maxFields || a.idRenamed() {
+		q.Column(append(a.insertColumns(), a.modelColumns()...)...)
+	}
+	res, err := a.ignoreDuplicates(q).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// LoadPolicy loads all policy rules from the storage.
+func (a *Adapter) LoadPolicy(model model.Model) error {
+	return a.LoadPolicyCtx(a.ctx, model)
+}
+
+// LoadPolicyCtx loads all policy rules from the storage with context.
+func (a *Adapter) LoadPolicyCtx(ctx context.Context, model model.Model) (err error) {
+	ctx, endOp := a.startOp(ctx, "LoadPolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	defer checkTableNotFound(&err)
+	db := a.readClient(ctx)
+	ctx, cancel, err := a.loadContext(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if a.loadRetryAttempts > 1 {
+		return a.loadPolicyRetry(ctx, db, model)
+	}
+	return a.loadPolicy(ctx, db, model)
+}
+
+// loadPolicy adds the rules of all tables to model, reading them from db.
+func (a *Adapter) loadPolicy(ctx context.Context, db *bun.DB, model model.Model) error {
+	tables, err := a.getReadTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	if a.useLoadCursor(db) {
+		return a.loadPolicyCursor(ctx, db, tables, model)
+	}
+	key := strings.Join(tables, "\x00")
+	var gen uint64
+	if a.cache != nil {
+		if policies, ok := a.cache.get(key); ok {
+			return a.loadRules(ctx, policies, model)
+		}
+		gen = a.cache.generation()
+	}
+	var policies []*CasbinRule
+	err = a.withLoadDB(ctx, db, func(db bun.IDB) error {
+		var err error
+		if policies, err = a.scanBuffer(ctx, db, tables); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			// Scan into the free capacity of policies, if any.
+			lines := policies[len(policies):]
+			q := a.newSelect(ctx, db, &lines, table).OrderExpr("? ASC", bun.Ident(a.idColumnName()))
+			a.limitLoad(q, len(policies))
+			if err := q.Scan(ctx); err != nil {
+				return err
+			}
+			policies = append(policies, lines...)
+			if err := a.checkLoadRows(len(policies)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if a.cache != nil {
+		a.cache.put(key, gen, policies)
+	}
+	return a.loadRules(ctx, policies, model)
+}
+
+// LoadFilteredPolicy loads only policy rules that match the filter.
+// Filter parameter here is a Filter structure
+func (a *Adapter) LoadFilteredPolicy(model model.Model, filter interface{}) error {
+	return a.LoadFilteredPolicyCtx(a.ctx, model, filter)
+}
+
+// LoadFilteredPolicyCtx loads only policy rules that match the filter with context.
+// Filter parameter here is a Filter structure
+func (a *Adapter) LoadFilteredPolicyCtx(ctx context.Context, model model.Model, filter interface{}) error {
+	_, err := a.loadFilteredPolicy(ctx, model, filter)
+	return err
+}
+
+// LoadFilteredPolicyN loads only policy rules that match the filter like
+// LoadFilteredPolicy, and returns the number of rows loaded.
+func (a *Adapter) LoadFilteredPolicyN(model model.Model, filter interface{}) (int64, error) {
+	return a.loadFilteredPolicy(a.ctx, model, filter)
+}
+
+func (a *Adapter) loadFilteredPolicy(ctx context.Context, model model.Model, filter interface{}) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "LoadFilteredPolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	defer checkTableNotFound(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+
+	filterValue, ok := filter.(Filter)
+	if !ok {
+		return 0, fmt.Errorf("invalid filter type: %v", reflect.TypeOf(filter))
+	}
+
+	tables, err := a.getReadTableNames(ctx)
+	if err != nil {
+		return 0, err
+	}
+	conds, empty, err := a.filterConditions(filterValue)
+	if err != nil {
+		return 0, err
+	}
+
+	var lines []*CasbinRule
+	err = a.withLoadDB(ctx, a.readClient(ctx), func(db bun.IDB) error {
+		for _, table := range tables {
+			tableLines, err := a.selectFiltered(ctx, db, table, conds, empty)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, tableLines...)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := a.loadRules(ctx, lines, model); err != nil {
+		return 0, err
+	}
+	a.filtered = true
+
+	return int64(len(lines)), nil
+}
+
+// selectFiltered returns the rows of table matching the conditions of a
+// filter.
+func (a *Adapter) selectFiltered(ctx context.Context, db bun.IDB, table string, conds []inCondition, empty []bun.Ident) ([]*CasbinRule, error) {
+	var lines []*CasbinRule
+	err := a.chunkConditions(conds, func(conds []inCondition) error {
+		var chunk []*CasbinRule
+		session := a.newSelect(ctx, db, &chunk, table)
+		for _, cond := range conds {
+			session.Where(fmt.Sprintf("%s in (?)", cond.column), bun.In(cond.values))
+		}
+		for _, column := range empty {
+			session.Where("(? = '' OR ? IS NULL)", column, column)
+		}
+		if err := session.Scan(ctx); err != nil {
+			return err
+		}
+		lines = append(lines, chunk...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.inChunkSize > 0 {
+		lines = uniqueRules(lines)
+	}
+	return lines, nil
+}
+
+// LoadPolicyFor loads only the policy rules of the given ptypes, e.g. for one
+// of several enforcers sharing the table. The adapter is marked as filtered.
+func (a *Adapter) LoadPolicyFor(model model.Model, ptypes []string) error {
+	return a.LoadPolicyForCtx(a.ctx, model, ptypes)
+}
+
+// LoadPolicyForCtx loads only the policy rules of the given ptypes with
+// context.
+func (a *Adapter) LoadPolicyForCtx(ctx context.Context, model model.Model, ptypes []string) error {
+	if len(ptypes) == 0 {
+		// An empty ptype filter would match every rule.
+		a.filtered = true
+		return nil
+	}
+	return a.LoadFilteredPolicyCtx(ctx, model, Filter{Ptype: ptypes})
+}
+
+// filterConditions returns the IN conditions and the columns required to be
+// empty of filter.
+func (a *Adapter) filterConditions(filterValue Filter) ([]inCondition, []bun.Ident, error) {
+	var conds []inCondition
+	if len(filterValue.Ptype) != 0 {
+		conds = append(conds, inCondition{column: a.ptypeColumnName(), values: filterValue.Ptype})
+	}
+	fields := [][]string{filterValue.V0, filterValue.V1, filterValue.V2, filterValue.V3, filterValue.V4, filterValue.V5}
+	for i, values := range fields {
+		if len(values) == 0 {
+			continue
+		}
+		if i >= a.fieldCount() {
+			return nil, nil, errors.Errorf("filter on v%d is beyond the %d V columns of the table", i, a.fieldCount())
+		}
+		values, err := a.encodeFields(i, values)
+		if err != nil {
+			return nil, nil, err
+		}
+		conds = append(conds, inCondition{column: fmt.Sprintf("v%d", i) + a.collateSQL(), values: values})
 	}
-	return a.schemaName + "." + a.tableName
+	var empty []bun.Ident
+	emptyFields := []bool{filterValue.V0Empty, filterValue.V1Empty, filterValue.V2Empty, filterValue.V3Empty, filterValue.V4Empty, filterValue.V5Empty}
+	for i, isEmpty := range emptyFields {
+		// Columns the table doesn't have are empty by definition.
+		if isEmpty && i < a.fieldCount() {
+			empty = append(empty, bun.Ident(fmt.Sprintf("v%d", i)))
+		}
+	}
+	return conds, empty, nil
+}
+
+// IsFiltered returns true if the loaded policy has been filtered.
+func (a *Adapter) IsFiltered() bool {
+	return a.filtered
+}
+
+// IsFilteredCtx returns true if the loaded policy has been filtered.
+func (a *Adapter) IsFilteredCtx(ctx context.Context) bool {
+	return a.filtered
+}
+
+// SavePolicy saves all policy rules to the storage.
+func (a *Adapter) SavePolicy(model model.Model) error {
+	return a.SavePolicyCtx(a.ctx, model)
+}
+
+// SavePolicyCtx saves all policy rules to the storage with context.
+func (a *Adapter) SavePolicyCtx(ctx context.Context, model model.Model) error {
+	_, err := a.savePolicy(ctx, model)
+	return err
+}
+
+// SavePolicyN saves all policy rules to the storage like SavePolicy, and
+// returns the number of rows written.
+func (a *Adapter) SavePolicyN(model model.Model) (int64, error) {
+	return a.savePolicy(a.ctx, model)
+}
+
+func (a *Adapter) savePolicy(ctx context.Context, model model.Model) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "SavePolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	defer checkTableNotFound(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return 0, err
+	}
+	lines, err := a.modelLines(model)
+	if err != nil {
+		return 0, err
+	}
+	if a.guardEmptySave && len(lines) == 0 {
+		return 0, ErrEmptySave
+	}
+	if a.saveChunkRows > 0 {
+		return a.savePolicyChunked(ctx, tables, lines)
+	}
+	var n int64
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		return a.withoutForeignKeyChecks(ctx, tx, func() error {
+			for _, table := range tables {
+				if err := a.truncateTable(ctx, tx, table); err != nil {
+					return err
+				}
+			}
+			if err := a.publish(ctx, tx, "SavePolicy", "", nil); err != nil {
+				return err
+			}
+
+			var err error
+			n, err = a.insertByTable(ctx, tx, lines)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Clear removes all policy rules from the tables of ctx the same way
+// SavePolicy empties them, without touching a model.
+func (a *Adapter) Clear(ctx context.Context) (err error) {
+	ctx, endOp := a.startOp(ctx, "Clear")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		for _, table := range tables {
+			if err := a.truncateTable(ctx, tx, table); err != nil {
+				return err
+			}
+		}
+		return a.publish(ctx, tx, "SavePolicy", "", nil)
+	})
+}
+
+func (a *Adapter) truncateTable(ctx context.Context, tx bun.Tx, table string) error {
+	if a.spannerDialect != nil {
+		// Spanner has neither TRUNCATE nor sequences to restart, and
+		// requires a WHERE clause on every DELETE.
+		_, err := tx.NewDelete().Conn(a.queryConn(ctx, tx)).TableExpr(table).Where("TRUE").Exec(ctx)
+		return err
+	}
+	_, err := tx.NewTruncateTable().
+		Conn(a.queryConn(ctx, tx)).
+		TableExpr(table).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if a.resetIdentity {
+		return a.restartIdentity(ctx, tx, table)
+	}
+	return nil
+}
+
+// restartIdentity resets the auto-increment counter of the (empty) table.
+// Postgres already does this as part of TRUNCATE ... RESTART IDENTITY.
+func (a *Adapter) restartIdentity(ctx context.Context, tx bun.Tx, table string) error {
+	var err error
+	switch a.client.Dialect().Name() {
+	case dialect.MySQL:
+		_, err = tx.NewRaw("ALTER TABLE ? AUTO_INCREMENT = 1", bun.Safe(table)).Conn(a.queryConn(ctx, tx)).Exec(ctx)
+	case dialect.MSSQL:
+		// bun emulates TRUNCATE with DELETE on SQL Server, which keeps the
+		// identity; reseeding to 0 makes the next inserted row get id 1.
+		_, err = tx.NewRaw("DBCC CHECKIDENT (?, RESEED, 0)", table).Conn(a.queryConn(ctx, tx)).Exec(ctx)
+	}
+	return err
+}
+
+// AddPolicy adds a policy rule to the storage.
+// This is part of the Auto-Save feature.
+func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
+	return a.AddPolicyCtx(a.ctx, sec, ptype, rule)
+}
+
+// AddPolicyCtx adds a policy rule to the storage with context.
+// This is part of the Auto-Save feature.
+func (a *Adapter) AddPolicyCtx(ctx context.Context, sec string, ptype string, rule []string) (err error) {
+	ctx, endOp := a.startOp(ctx, "AddPolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	line, err := a.savePolicyLine(ptype, rule)
+	if err != nil {
+		return err
+	}
+	table, err := a.lineTableName(ctx, line)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		if err := a.publish(ctx, tx, "AddPolicy", ptype, rule); err != nil {
+			return err
+		}
+		if a.stmts != nil {
+			return a.execPreparedInsert(ctx, tx, table, line)
+		}
+		_, err = a.insertQuery(ctx, tx, table, line).Exec(ctx)
+		return err
+	})
+}
+
+// AddPolicyReturningID adds a policy rule to the storage and returns the id
+// of the inserted row.
+func (a *Adapter) AddPolicyReturningID(ctx context.Context, ptype string, rule []string) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "AddPolicyReturningID")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	line, err := a.savePolicyLine(ptype, rule)
+	if err != nil {
+		return 0, err
+	}
+	table, err := a.lineTableName(ctx, line)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		if err := a.publish(ctx, tx, "AddPolicy", ptype, rule); err != nil {
+			return err
+		}
+		q := a.insertQuery(ctx, tx, table, line)
+		if a.spannerDialect != nil {
+			// The id was generated by savePolicyLine.
+			if _, err := q.Returning("").Exec(ctx); err != nil {
+				return err
+			}
+			id = line.Id
+			return nil
+		}
+		switch a.client.Dialect().Name() {
+		case dialect.MySQL:
+			// MySQL has no RETURNING, the id comes from LAST_INSERT_ID().
+			res, err := q.Exec(ctx)
+			if err != nil {
+				return err
+			}
+			id, err = res.LastInsertId()
+			return err
+		case dialect.MSSQL:
+			if _, err := q.Returning("INSERTED.? AS id", bun.Ident(a.idColumnName())).Exec(ctx); err != nil {
+				return err
+			}
+		default:
+			if _, err := q.Returning("? AS id", bun.Ident(a.idColumnName())).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		id = a.modelRule(q.GetModel().Value()).Id
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// RemovePolicy removes a policy rule from the storage.
+// This is part of the Auto-Save feature.
+func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	return a.RemovePolicyCtx(a.ctx, sec, ptype, rule)
+}
+
+// RemovePolicyCtx removes a policy rule from the storage with context.
+// This is part of the Auto-Save feature.
+func (a *Adapter) RemovePolicyCtx(ctx context.Context, sec string, ptype string, rule []string) (err error) {
+	ctx, endOp := a.startOp(ctx, "RemovePolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	table, err := a.ruleTableName(ctx, ptype, rule)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		if err := a.auditPolicy(ctx, tx, table, ptype, rule); err != nil {
+			return err
+		}
+		if err := a.publish(ctx, tx, "RemovePolicy", ptype, rule); err != nil {
+			return err
+		}
+		if a.stmts != nil {
+			instance, err := a.toInstance(ptype, rule)
+			if err != nil {
+				return err
+			}
+			return a.execPreparedRemove(ctx, tx, table, instance)
+		}
+		_, err := a.buildRemoveQuery(ctx, tx, table, ptype, rule).Exec(ctx)
+		return err
+	})
+}
+
+// RemoveFilteredPolicy removes policy rules that match the filter from the storage.
+// This is part of the Auto-Save feature.
+func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	return a.RemoveFilteredPolicyCtx(a.ctx, sec, ptype, fieldIndex, fieldValues...)
 }
 
-// LoadPolicy loads all policy rules from the storage.
-func (a *Adapter) LoadPolicy(model model.Model) error {
-	var policies []*CasbinRule
-	err := a.client.NewSelect().Table(a.getFullTableName()).Order("id ASC").Scan(a.ctx, &policies)
+// RemoveFilteredPolicyCtx removes policy rules that match the filter from the storage with context.
+// This is part of the Auto-Save feature.
+func (a *Adapter) RemoveFilteredPolicyCtx(ctx context.Context, sec string, ptype string, fieldIndex int, fieldValues ...string) (err error) {
+	ctx, endOp := a.startOp(ctx, "RemoveFilteredPolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.ptypeTableNames(ctx, ptype)
 	if err != nil {
 		return err
 	}
-	for _, policy := range policies {
-		loadPolicyLine(policy, model)
-	}
-	return nil
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		if err := a.publishFiltered(ctx, tx, ptype, fieldIndex, fieldValues); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if _, err := a.removeFiltered(ctx, tx, table, ptype, fieldIndex, fieldValues); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// LoadFilteredPolicy loads only policy rules that match the filter.
-// Filter parameter here is a Filter structure
-func (a *Adapter) LoadFilteredPolicy(model model.Model, filter interface{}) error {
-
-	filterValue, ok := filter.(Filter)
-	if !ok {
-		return fmt.Errorf("invalid filter type: %v", reflect.TypeOf(filter))
-	}
+// FieldFilter selects the rules whose V columns starting at FieldIndex equal
+// FieldValues, like the arguments of RemoveFilteredPolicy.
+type FieldFilter struct {
+	FieldIndex  int
+	FieldValues []string
+}
 
-	session := a.client.NewSelect().Table(a.getFullTableName())
+// RemoveFilteredPolicies removes the policy rules matching any of filters in
+// a single transaction, and returns the number of rows removed.
+func (a *Adapter) RemoveFilteredPolicies(sec string, ptype string, filters []FieldFilter) (int64, error) {
+	return a.RemoveFilteredPoliciesCtx(a.ctx, sec, ptype, filters)
+}
 
-	if len(filterValue.Ptype) != 0 {
-		session.Where("ptype in (?)", bun.In(filterValue.Ptype))
-	}
-	if len(filterValue.V0) != 0 {
-		session.Where("v0 in (?)", bun.In(filterValue.V0))
-	}
-	if len(filterValue.V1) != 0 {
-		session.Where("v1 in (?)", bun.In(filterValue.V1))
-	}
-	if len(filterValue.V2) != 0 {
-		session.Where("v2 in (?)", bun.In(filterValue.V2))
-	}
-	if len(filterValue.V3) != 0 {
-		session.Where("v3 in (?)", bun.In(filterValue.V3))
-	}
-	if len(filterValue.V4) != 0 {
-		session.Where("v4 in (?)", bun.In(filterValue.V4))
+// RemoveFilteredPoliciesCtx removes the policy rules matching any of filters
+// in a single transaction with context.
+func (a *Adapter) RemoveFilteredPoliciesCtx(ctx context.Context, sec string, ptype string, filters []FieldFilter) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "RemoveFilteredPolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.ptypeTableNames(ctx, ptype)
+	if err != nil {
+		return 0, err
 	}
-	if len(filterValue.V5) != 0 {
-		session.Where("v5 in (?)", bun.In(filterValue.V5))
+	var total int64
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		total = 0
+		for _, filter := range filters {
+			if err := a.publishFiltered(ctx, tx, ptype, filter.FieldIndex, filter.FieldValues); err != nil {
+				return err
+			}
+		}
+		for _, table := range tables {
+			for _, filter := range filters {
+				n, err := a.removeFiltered(ctx, tx, table, ptype, filter.FieldIndex, filter.FieldValues)
+				if err != nil {
+					return err
+				}
+				total += n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
+	return total, nil
+}
 
-	var lines []*CasbinRule
-	err := session.Scan(a.ctx, &lines)
+func (a *Adapter) removeFiltered(ctx context.Context, tx bun.Tx, table, ptype string, fieldIndex int, fieldValues []string) (int64, error) {
+	err := a.auditRemoved(ctx, tx, table, ptype, func(q bun.QueryBuilder) error {
+		q.Where("? = ?", bun.Ident(a.ptypeColumnName()), ptype)
+		return a.whereFieldValues(q, fieldIndex, fieldValues)
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	for _, line := range lines {
-		loadPolicyLine(line, model)
-	}
-	a.filtered = true
+	build := tx.NewDelete().Conn(a.queryConn(ctx, tx)).Model((*CasbinRule)(nil)).ModelTableExpr(table)
 
-	return nil
-}
+	build.Where("? = ?", bun.Ident(a.ptypeColumnName()), ptype)
 
-// IsFiltered returns true if the loaded policy has been filtered.
-func (a *Adapter) IsFiltered() bool {
-	return a.filtered
+	if err := a.whereFieldValues(build.QueryBuilder(), fieldIndex, fieldValues); err != nil {
+		return 0, err
+	}
+	res, err := build.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
 
-// SavePolicy saves all policy rules to the storage.
-func (a *Adapter) SavePolicy(model model.Model) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		_, err := tx.NewTruncateTable().
-			Table(a.getFullTableName()).
-			Exec(a.ctx)
-		if err != nil {
-			return err
-		}
-
-		lines := make([]*CasbinRule, 0)
-
-		for ptype, ast := range model["p"] {
-			for _, policy := range ast.Policy {
-				line := a.savePolicyLine(tx, ptype, policy)
-				lines = append(lines, line)
+// whereFieldValues restricts q to rows whose V columns starting at fieldIndex
+// equal fieldValues.
+func (a *Adapter) whereFieldValues(q bun.QueryBuilder, fieldIndex int, fieldValues []string) error {
+	for i := 0; i < maxFields; i++ {
+		if fieldIndex <= i && i < fieldIndex+len(fieldValues) {
+			if err := a.checkFieldIndex(i, fieldValues[i-fieldIndex]); err != nil {
+				return err
 			}
-		}
-
-		for ptype, ast := range model["g"] {
-			for _, policy := range ast.Policy {
-				line := a.savePolicyLine(tx, ptype, policy)
-				lines = append(lines, line)
+			if i >= a.fieldCount() {
+				continue
+			}
+			value, err := a.encodeField(i, fieldValues[i-fieldIndex])
+			if err != nil {
+				return err
 			}
+			a.whereField(q, i, value)
 		}
-
-		_, err = tx.NewInsert().Model(&lines).ModelTableExpr(a.getFullTableName()).Exec(a.ctx)
-		return err
-	})
+	}
+	return nil
 }
 
-// AddPolicy adds a policy rule to the storage.
+// AddPolicies adds policy rules to the storage.
 // This is part of the Auto-Save feature.
-func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		line := a.savePolicyLine(tx, ptype, rule)
-		_, err := tx.NewInsert().Model(line).ModelTableExpr(a.getFullTableName()).Exec(a.ctx)
-		return err
-	})
+func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	return a.AddPoliciesCtx(a.ctx, sec, ptype, rules)
 }
 
-// RemovePolicy removes a policy rule from the storage.
+// AddPoliciesCtx adds policy rules to the storage with context.
 // This is part of the Auto-Save feature.
-func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		instance := a.toInstance(ptype, rule)
-
-		_, err := tx.NewDelete().
-			Table(a.getFullTableName()).
-			Where("ptype = ?", instance.Ptype).
-			Where("v0 = ?", instance.V0).
-			Where("v1 = ?", instance.V1).
-			Where("v2 = ?", instance.V2).
-			Where("v3 = ?", instance.V3).
-			Where("v4 = ?", instance.V4).
-			Where("v5 = ?", instance.V5).
-			Exec(a.ctx)
-		return err
-	})
+func (a *Adapter) AddPoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) error {
+	_, err := a.addPolicies(ctx, ptype, rules)
+	return err
 }
 
-// RemoveFilteredPolicy removes policy rules that match the filter from the storage.
-// This is part of the Auto-Save feature.
-func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		build := tx.NewDelete().Table(a.getFullTableName())
-
-		build.Where("ptype = ?", ptype)
+// AddPoliciesN adds policy rules to the storage like AddPolicies, and returns
+// the number of rows inserted.
+func (a *Adapter) AddPoliciesN(sec string, ptype string, rules [][]string) (int64, error) {
+	return a.addPolicies(a.ctx, ptype, rules)
+}
 
-		if fieldIndex <= 0 && 0 < fieldIndex+len(fieldValues) {
-			build.Where("v0 = ?", fieldValues[0-fieldIndex])
-		}
-		if fieldIndex <= 1 && 1 < fieldIndex+len(fieldValues) {
-			build.Where("v1 = ?", fieldValues[1-fieldIndex])
-		}
-		if fieldIndex <= 2 && 2 < fieldIndex+len(fieldValues) {
-			build.Where("v2 = ?", fieldValues[2-fieldIndex])
-		}
-		if fieldIndex <= 3 && 3 < fieldIndex+len(fieldValues) {
-			build.Where("v3 = ?", fieldValues[3-fieldIndex])
+func (a *Adapter) addPolicies(ctx context.Context, ptype string, rules [][]string) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "AddPolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	lines := make([]*CasbinRule, 0, len(rules))
+	for _, rule := range rules {
+		line, err := a.savePolicyLine(ptype, rule)
+		if err != nil {
+			return 0, err
 		}
-		if fieldIndex <= 4 && 4 < fieldIndex+len(fieldValues) {
-			build.Where("v4 = ?", fieldValues[4-fieldIndex])
+		lines = append(lines, line)
+	}
+	tables, byTable, err := a.groupByTable(ctx, lines)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		n = 0
+		if err := a.publishRules(ctx, tx, "AddPolicy", ptype, rules); err != nil {
+			return err
 		}
-		if fieldIndex <= 5 && 5 < fieldIndex+len(fieldValues) {
-			build.Where("v5 = ?", fieldValues[5-fieldIndex])
+		for _, table := range tables {
+			lines := byTable[table]
+			if a.skipExisting {
+				var err error
+				lines, err = a.missingRules(ctx, tx, table, lines)
+				if err != nil {
+					return err
+				}
+			}
+			affected, err := a.insertRules(ctx, tx, table, lines)
+			if err != nil {
+				return err
+			}
+			n += affected
 		}
-		_, err := build.Exec(a.ctx)
-		return err
+		return nil
 	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-// AddPolicies adds policy rules to the storage.
+// RemovePolicies removes policy rules from the storage.
 // This is part of the Auto-Save feature.
-func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		return a.createPolicies(tx, ptype, rules)
-	})
+func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	return a.RemovePoliciesCtx(a.ctx, sec, ptype, rules)
 }
 
-// RemovePolicies removes policy rules from the storage.
+// RemovePoliciesCtx removes policy rules from the storage with context.
 // This is part of the Auto-Save feature.
-func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
-	return a.WithTx(func(tx bun.Tx) error {
+func (a *Adapter) RemovePoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) (err error) {
+	ctx, endOp := a.startOp(ctx, "RemovePolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	return a.withTx(ctx, func(tx bun.Tx) error {
 		for _, rule := range rules {
-			instance := a.toInstance(ptype, rule)
-			if _, err := tx.NewDelete().Table(a.getFullTableName()).
-				Where("ptype = ?", instance.Ptype).
-				Where("v0 = ?", instance.V0).
-				Where("v1 = ?", instance.V1).
-				Where("v2 = ?", instance.V2).
-				Where("v3 = ?", instance.V3).
-				Where("v4 = ?", instance.V4).
-				Where("v5 = ?", instance.V5).
-				Exec(a.ctx); err != nil {
+			table, err := a.ruleTableName(ctx, ptype, rule)
+			if err != nil {
+				return err
+			}
+			if err := a.auditPolicy(ctx, tx, table, ptype, rule); err != nil {
+				return err
+			}
+			if err := a.publish(ctx, tx, "RemovePolicy", ptype, rule); err != nil {
+				return err
+			}
+			if _, err := a.buildRemoveQuery(ctx, tx, table, ptype, rule).Exec(ctx); err != nil {
 				return err
 			}
 		}
@@ -340,7 +1371,41 @@ func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) err
 }
 
 func (a *Adapter) WithTx(fn func(tx bun.Tx) error) error {
-	tx, err := a.client.Begin()
+	return a.withTx(a.ctx, fn)
+}
+
+func (a *Adapter) withTx(ctx context.Context, fn func(tx bun.Tx) error) error {
+	// Drop the cache once the transaction is over, whatever its outcome.
+	defer a.InvalidateCache()
+	if a.txRetryAttempts <= 1 && a.deadlockRetries == 0 {
+		return a.withTxOptions(ctx, a.client, nil, fn)
+	}
+	var failures, deadlocks int
+	// The first attempt counts towards WithTxRetry only when it is set;
+	// deadlock retries always come on top of it.
+	attempts := a.txRetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	attempts += a.deadlockRetries
+	return a.retry(ctx, attempts, txRetryBaseDelay, func() (bool, error) {
+		err := a.withTxOptions(ctx, a.client, nil, fn)
+		switch {
+		case err == nil:
+			return false, nil
+		case isMySQLDeadlock(err):
+			deadlocks++
+			return deadlocks <= a.deadlockRetries, err
+		case isRetryable(err):
+			failures++
+			return failures < a.txRetryAttempts, err
+		}
+		return false, err
+	})
+}
+
+func (a *Adapter) withTxOptions(ctx context.Context, db *bun.DB, opts *sql.TxOptions, fn func(tx bun.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -362,13 +1427,34 @@ func (a *Adapter) WithTx(fn func(tx bun.Tx) error) error {
 	return nil
 }
 
+// loadRule adds a stored row to the model, decrypting it and running the
+// after-load hook first.
+func (a *Adapter) loadRule(line *CasbinRule, model model.Model) error {
+	if err := a.decodeRule(line); err != nil {
+		return err
+	}
+	if a.afterLoad != nil {
+		a.afterLoad(line)
+	}
+	if a.rowMapper != nil {
+		ptype, tokens := a.rowMapper(line)
+		return persist.LoadPolicyArray(append([]string{ptype}, tokens...), model)
+	}
+	loadPolicyLine(line, model)
+	return nil
+}
+
 func loadPolicyLine(line *CasbinRule, model model.Model) {
 	var p = []string{line.Ptype,
-		line.V0, line.V1, line.V2, line.V3, line.V4, line.V5}
+		line.V0, line.V1, line.V2, line.V3, line.V4, line.V5, line.V6, line.V7}
 
 	var lineText string
-	if line.V5 != "" {
+	if line.V7 != "" {
 		lineText = strings.Join(p, ", ")
+	} else if line.V6 != "" {
+		lineText = strings.Join(p[:8], ", ")
+	} else if line.V5 != "" {
+		lineText = strings.Join(p[:7], ", ")
 	} else if line.V4 != "" {
 		lineText = strings.Join(p[:6], ", ")
 	} else if line.V3 != "" {
@@ -384,7 +1470,20 @@ func loadPolicyLine(line *CasbinRule, model model.Model) {
 	persist.LoadPolicyLine(lineText, model)
 }
 
-func (a *Adapter) toInstance(ptype string, rule []string) *CasbinRule {
+// toInstance converts a rule to the row it is stored as. Every path that
+// writes or matches a whole rule goes through here, so the before-save hook
+// applies to them alike.
+func (a *Adapter) toInstance(ptype string, rule []string) (*CasbinRule, error) {
+	if a.beforeSave != nil {
+		rule = a.beforeSave(ptype, append([]string(nil), rule...))
+	}
+
+	for i := a.fieldCount(); i < len(rule); i++ {
+		if err := a.checkFieldIndex(i, rule[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	instance := &CasbinRule{}
 
 	instance.Ptype = ptype
@@ -407,135 +1506,336 @@ func (a *Adapter) toInstance(ptype string, rule []string) *CasbinRule {
 	if len(rule) > 5 {
 		instance.V5 = rule[5]
 	}
-	return instance
+	if len(rule) > 6 {
+		instance.V6 = rule[6]
+	}
+	if len(rule) > 7 {
+		instance.V7 = rule[7]
+	}
+	if err := a.encodeRule(instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
 }
 
-func (a *Adapter) savePolicyLine(tx bun.Tx, ptype string, rule []string) *CasbinRule {
-	line := &CasbinRule{
-		Ptype: ptype,
+// buildRemoveQuery returns the DELETE query matching exactly the given rule,
+// without executing it.
+func (a *Adapter) buildRemoveQuery(ctx context.Context, db bun.IDB, table string, ptype string, rule []string) *bun.DeleteQuery {
+	instance, err := a.toInstance(ptype, rule)
+	if err != nil {
+		return db.NewDelete().Err(err)
 	}
+	q := db.NewDelete().
+		Conn(a.queryConn(ctx, db)).
+		Model((*CasbinRule)(nil)).
+		ModelTableExpr(table)
+	a.wherePolicy(q.QueryBuilder(), instance)
+	return q
+}
 
-	if len(rule) > 0 {
-		line.V0 = rule[0]
-	}
-	if len(rule) > 1 {
-		line.V1 = rule[1]
+// wherePolicy restricts q to the rows storing exactly the given rule, over
+// the V columns of the table (see WithColumnCount). Only ptype and the V
+// columns identify a rule: the id, the timestamp column, the extra column
+// and the columns added by WithModel are never compared, so removes and
+// updates match rows whatever those hold.
+func (a *Adapter) wherePolicy(q bun.QueryBuilder, rule *CasbinRule) {
+	q.Where("? = ?", bun.Ident(a.ptypeColumnName()), rule.Ptype)
+	for i, field := range rule.fields()[:a.fieldCount()] {
+		if i >= 6 && *field == "" {
+			// v6 and v7 came later than v0 to v5, and tables they
+			// were added to may hold NULL in them for older rows.
+			name := bun.Ident(fmt.Sprintf("v%d", i))
+			q.Where("(? = '' OR ? IS NULL)", name, name)
+			continue
+		}
+		a.whereField(q, i, *field)
 	}
-	if len(rule) > 2 {
-		line.V2 = rule[2]
+}
+
+// whereField restricts q to rows whose V column at index column equals value.
+func (a *Adapter) whereField(q bun.QueryBuilder, column int, value string) {
+	name := bun.Ident(fmt.Sprintf("v%d", column))
+	if a.nullSafe && value == "" {
+		q.Where("(? = '' OR ? IS NULL)", name, name)
+		return
 	}
-	if len(rule) > 3 {
-		line.V3 = rule[3]
+	q.Where("?"+a.collateSQL()+" = ?", name, value)
+}
+
+// savePolicyLine converts a rule to the row written for it.
+func (a *Adapter) savePolicyLine(ptype string, rule []string) (*CasbinRule, error) {
+	line, err := a.toInstance(ptype, rule)
+	if err != nil {
+		return nil, err
 	}
-	if len(rule) > 4 {
-		line.V4 = rule[4]
+	if a.spannerDialect != nil {
+		if line.Id, err = newRuleID(); err != nil {
+			return nil, err
+		}
 	}
-	if len(rule) > 5 {
-		line.V5 = rule[5]
+	if a.extraColumn != "" {
+		if line.Extra, err = a.extraSerializer(ptype, rule); err != nil {
+			return nil, err
+		}
 	}
-
-	return line
+	return line, nil
 }
 
 // UpdatePolicy updates a policy rule from storage.
 // This is part of the Auto-Save feature.
-func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newPolicy []string) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		rule := a.toInstance(ptype, oldRule)
-		line := tx.NewUpdate().
-			Model(rule).
-			ModelTableExpr(a.getFullTableName()).
-			Where("ptype = ?", rule.Ptype).
-			Where("v0 = ?", rule.V0).
-			Where("v1 = ?", rule.V1).
-			Where("v2 = ?", rule.V2).
-			Where("v3 = ?", rule.V3).
-			Where("v4 = ?", rule.V4).
-			Where("v5 = ?", rule.V5)
-
-		rule = a.toInstance(ptype, newPolicy)
-		line.
-			Set("v0 = ?", rule.V0).
-			Set("v1 = ?", rule.V1).
-			Set("v2 = ?", rule.V2).
-			Set("v3 = ?", rule.V3).
-			Set("v4 = ?", rule.V4).
-			Set("v5 = ?", rule.V5)
-
-		_, err := line.Exec(a.ctx)
+func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return a.UpdatePolicyCtx(a.ctx, sec, ptype, oldRule, newRule)
+}
+
+// UpdatePolicyCtx updates a policy rule from storage with context.
+// This is part of the Auto-Save feature.
+func (a *Adapter) UpdatePolicyCtx(ctx context.Context, sec string, ptype string, oldRule, newRule []string) (err error) {
+	ctx, endOp := a.startOp(ctx, "UpdatePolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		_, err := a.updatePolicy(ctx, tx, ptype, oldRule, newRule)
 		return err
 	})
 }
 
-// UpdatePolicies updates some policy rules to storage, like db, redis.
-func (a *Adapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
-	return a.WithTx(func(tx bun.Tx) error {
-		for _, policy := range oldRules {
-			rule := a.toInstance(ptype, policy)
-
-			if _, err := tx.NewDelete().
-				Table(a.getFullTableName()).
-				Where("ptype = ?", rule.Ptype).
-				Where("v0 = ?", rule.V0).
-				Where("v1 = ?", rule.V1).
-				Where("v2 = ?", rule.V2).
-				Where("v3 = ?", rule.V3).
-				Where("v4 = ?", rule.V4).
-				Where("v5 = ?", rule.V5).
-				Exec(a.ctx); err != nil {
-				return err
-			}
+// UpdatePolicyReturningOld updates a policy rule like UpdatePolicy and returns
+// the values stored before the update, or ErrPolicyNotFound if no row
+// matches oldRule.
+func (a *Adapter) UpdatePolicyReturningOld(sec string, ptype string, oldRule, newRule []string) ([]string, error) {
+	return a.UpdatePolicyReturningOldCtx(a.ctx, sec, ptype, oldRule, newRule)
+}
+
+// UpdatePolicyReturningOldCtx updates a policy rule like UpdatePolicy and
+// returns the values stored before the update, or ErrPolicyNotFound if no row
+// matches oldRule, with context.
+func (a *Adapter) UpdatePolicyReturningOldCtx(ctx context.Context, sec string, ptype string, oldRule, newRule []string) (_ []string, err error) {
+	ctx, endOp := a.startOp(ctx, "UpdatePolicyReturningOld")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	table, err := a.ruleTableName(ctx, ptype, oldRule)
+	if err != nil {
+		return nil, err
+	}
+	old := new(CasbinRule)
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		instance, err := a.toInstance(ptype, oldRule)
+		if err != nil {
+			return err
 		}
-		lines := make([]*CasbinRule, 0)
-		for _, policy := range newRules {
-			lines = append(lines, a.savePolicyLine(tx, ptype, policy))
+		q := a.newSelect(ctx, tx, old, table).OrderExpr("? ASC", bun.Ident(a.idColumnName())).Limit(1)
+		a.wherePolicy(q.QueryBuilder(), instance)
+		if err := q.Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrPolicyNotFound
+			}
+			return err
 		}
-		_, err := tx.NewInsert().Model(&lines).ModelTableExpr(a.getFullTableName()).Exec(a.ctx)
+		_, err = a.updatePolicy(ctx, tx, ptype, oldRule, newRule)
 		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+	if err := a.decodeRule(old); err != nil {
+		return nil, err
+	}
+	return CasbinRuleToStringArray(old), nil
 }
 
-// UpdateFilteredPolicies deletes old rules and adds new rules.
-func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newPolicies [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
-	oldPolicies := make([][]string, 0)
-	err := a.WithTx(func(tx bun.Tx) error {
-		line := tx.NewSelect().Table(a.getFullTableName())
-		if fieldIndex <= 0 && 0 < fieldIndex+len(fieldValues) {
-			line = line.Where("v0 = ?", fieldValues[0-fieldIndex])
+// updatePolicy sets the V columns of the rows matching oldRule to newRule and
+// returns the number of rows updated. When the rules belong to different
+// shards, the old rows are removed and the new rule is added to its shard.
+func (a *Adapter) updatePolicy(ctx context.Context, tx bun.Tx, ptype string, oldRule, newRule []string) (int64, error) {
+	if err := a.publish(ctx, tx, "RemovePolicy", ptype, oldRule); err != nil {
+		return 0, err
+	}
+	if err := a.publish(ctx, tx, "AddPolicy", ptype, newRule); err != nil {
+		return 0, err
+	}
+	rule, err := a.toInstance(ptype, oldRule)
+	if err != nil {
+		return 0, err
+	}
+	table, err := a.lineTableName(ctx, rule)
+	if err != nil {
+		return 0, err
+	}
+	if a.sharded() {
+		newTable, err := a.ruleTableName(ctx, ptype, newRule)
+		if err != nil {
+			return 0, err
+		}
+		if newTable != table {
+			return a.movePolicy(ctx, tx, table, ptype, oldRule, newRule)
 		}
-		if fieldIndex <= 1 && 1 < fieldIndex+len(fieldValues) {
-			line = line.Where("v1 = ?", fieldValues[1-fieldIndex])
+	}
+	// Without a model value bun derives neither columns nor conditions
+	// from the row; only the SET and wherePolicy clauses below apply.
+	line := tx.NewUpdate().
+		Conn(a.queryConn(ctx, tx)).
+		Model((*CasbinRule)(nil)).
+		ModelTableExpr(table)
+	a.wherePolicy(line.QueryBuilder(), rule)
+
+	oldLine := rule
+	rule, err = a.toInstance(ptype, newRule)
+	if err != nil {
+		return 0, err
+	}
+	// Only set the columns that change, including the ones the new rule
+	// leaves empty, comparing the values as they are stored.
+	changed := false
+	oldFields := oldLine.fields()
+	for i, field := range rule.fields()[:a.fieldCount()] {
+		if *field != *oldFields[i] {
+			line.Set("? = ?", bun.Ident(fmt.Sprintf("v%d", i)), a.storedValue(*field))
+			changed = true
 		}
-		if fieldIndex <= 2 && 2 < fieldIndex+len(fieldValues) {
-			line = line.Where("v2 = ?", fieldValues[2-fieldIndex])
+	}
+	if !changed {
+		// Nothing to write; report the rows matching like an update would.
+		q := a.newSelect(ctx, tx, (*CasbinRule)(nil), table)
+		a.wherePolicy(q.QueryBuilder(), oldLine)
+		n, err := q.Count(ctx)
+		return int64(n), err
+	}
+
+	res, err := line.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdatePolicies updates some policy rules to storage, like db, redis.
+func (a *Adapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return a.UpdatePoliciesCtx(a.ctx, sec, ptype, oldRules, newRules)
+}
+
+// UpdatePoliciesCtx updates some policy rules to storage, like db, redis, with context.
+// It fails with ErrPolicyNotFound, updating nothing, if one of oldRules is not
+// stored.
+func (a *Adapter) UpdatePoliciesCtx(ctx context.Context, sec string, ptype string, oldRules, newRules [][]string) (err error) {
+	ctx, endOp := a.startOp(ctx, "UpdatePolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	if a.inPlaceUpdate {
+		if len(oldRules) != len(newRules) {
+			return errors.Errorf("got %d old rules and %d new rules", len(oldRules), len(newRules))
 		}
-		if fieldIndex <= 3 && 3 < fieldIndex+len(fieldValues) {
-			line = line.Where("v3 = ?", fieldValues[3-fieldIndex])
+		return a.withTx(ctx, func(tx bun.Tx) error {
+			for i := range oldRules {
+				n, err := a.updatePolicy(ctx, tx, ptype, oldRules[i], newRules[i])
+				if err != nil {
+					return err
+				}
+				if err := checkOldRuleMatched(ptype, oldRules[i], n); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		for _, policy := range oldRules {
+			table, err := a.ruleTableName(ctx, ptype, policy)
+			if err != nil {
+				return err
+			}
+			res, err := a.buildRemoveQuery(ctx, tx, table, ptype, policy).Exec(ctx)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if err := checkOldRuleMatched(ptype, policy, n); err != nil {
+				return err
+			}
 		}
-		if fieldIndex <= 4 && 4 < fieldIndex+len(fieldValues) {
-			line = line.Where("v4 = ?", fieldValues[4-fieldIndex])
+		if err := a.publishRules(ctx, tx, "RemovePolicy", ptype, oldRules); err != nil {
+			return err
 		}
-		if fieldIndex <= 5 && 5 < fieldIndex+len(fieldValues) {
-			line = line.Where("v5 = ?", fieldValues[5-fieldIndex])
+		if err := a.publishRules(ctx, tx, "AddPolicy", ptype, newRules); err != nil {
+			return err
 		}
+		return a.createPolicies(ctx, tx, ptype, newRules)
+	})
+}
+
+// checkOldRuleMatched fails UpdatePolicies when an old rule matched no row,
+// rolling back the rules already updated.
+func checkOldRuleMatched(ptype string, rule []string, matched int64) error {
+	if matched == 0 {
+		return errors.Wrapf(ErrPolicyNotFound, "updating %s rule %q", ptype, rule)
+	}
+	return nil
+}
+
+// UpdateFilteredPolicies deletes old rules and adds new rules.
+func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	return a.UpdateFilteredPoliciesCtx(a.ctx, sec, ptype, newRules, fieldIndex, fieldValues...)
+}
+
+// UpdateFilteredPoliciesCtx deletes old rules and adds new rules with context.
+func (a *Adapter) UpdateFilteredPoliciesCtx(ctx context.Context, sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) (_ [][]string, err error) {
+	ctx, endOp := a.startOp(ctx, "UpdateFilteredPolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.ptypeTableNames(ctx, ptype)
+	if err != nil {
+		return nil, err
+	}
+	var oldPolicies [][]string
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		oldPolicies = make([][]string, 0)
 		rules := make([]*CasbinRule, 0)
-		err := line.Scan(a.ctx, &rules)
-		if err != nil {
+		for _, table := range tables {
+			tableRules := make([]*CasbinRule, 0)
+			line := a.newSelect(ctx, tx, &tableRules, table).
+				Where("? = ?", bun.Ident(a.ptypeColumnName()), ptype)
+			if err := a.whereFieldValues(line.QueryBuilder(), fieldIndex, fieldValues); err != nil {
+				return err
+			}
+			err := line.Scan(ctx)
+			if err != nil {
+				return err
+			}
+			for _, rule := range tableRules {
+				if _, err := tx.NewDelete().
+					Conn(a.queryConn(ctx, tx)).
+					Model((*CasbinRule)(nil)).
+					ModelTableExpr(table).
+					Where("? = ?", bun.Ident(a.idColumnName()), rule.Id).
+					Exec(ctx); err != nil {
+					return err
+				}
+			}
+			rules = append(rules, tableRules...)
+		}
+		if err := a.createPolicies(ctx, tx, ptype, newRules); err != nil {
 			return err
 		}
 		for _, rule := range rules {
-			if _, err := tx.NewDelete().
-				Table(a.getFullTableName()).
-				Where("id = ?", rule.Id).
-				Exec(a.ctx); err != nil {
+			if err := a.decodeRule(rule); err != nil {
 				return err
 			}
-		}
-		a.createPolicies(tx, ptype, newPolicies)
-		for _, rule := range rules {
 			oldPolicies = append(oldPolicies, CasbinRuleToStringArray(rule))
 		}
-		return nil
+		if err := a.publishRules(ctx, tx, "RemovePolicy", ptype, oldPolicies); err != nil {
+			return err
+		}
+		return a.publishRules(ctx, tx, "AddPolicy", ptype, newRules)
 	})
 	if err != nil {
 		return nil, err
@@ -543,13 +1843,25 @@ func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newPolicies [
 	return oldPolicies, nil
 }
 
-func (a *Adapter) createPolicies(tx bun.Tx, ptype string, policies [][]string) error {
+func (a *Adapter) createPolicies(ctx context.Context, tx bun.Tx, ptype string, policies [][]string) error {
 	lines := make([]*CasbinRule, 0)
 	for _, policy := range policies {
-		lines = append(lines, a.savePolicyLine(tx, ptype, policy))
+		line, err := a.savePolicyLine(ptype, policy)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
 	}
-	_, err := tx.NewInsert().Model(&lines).ModelTableExpr(a.getFullTableName()).Exec(a.ctx)
-	return err
+	tables, byTable, err := a.groupByTable(ctx, lines)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if _, err := a.insertRules(ctx, tx, table, byTable[table]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func CasbinRuleToStringArray(rule *CasbinRule) []string {
@@ -572,5 +1884,11 @@ func CasbinRuleToStringArray(rule *CasbinRule) []string {
 	if rule.V5 != "" {
 		arr = append(arr, rule.V5)
 	}
+	if rule.V6 != "" {
+		arr = append(arr, rule.V6)
+	}
+	if rule.V7 != "" {
+		arr = append(arr, rule.V7)
+	}
 	return arr
 }
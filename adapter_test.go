@@ -0,0 +1,933 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestBuildRemoveQuery(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{"pg", `DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v0" = 'alice') AND ("v1" = 'data1') AND ("v2" = 'read') AND ("v3" = '') AND ("v4" = '') AND ("v5" = '') AND (("v6" = '' OR "v6" IS NULL)) AND (("v7" = '' OR "v7" IS NULL))`},
+		{"mysql", "DELETE FROM casbin_rule WHERE (`ptype` = 'p') AND (`v0` = 'alice') AND (`v1` = 'data1') AND (`v2` = 'read') AND (`v3` = '') AND (`v4` = '') AND (`v5` = '') AND ((`v6` = '' OR `v6` IS NULL)) AND ((`v7` = '' OR `v7` IS NULL))"},
+		{"mssql", `DELETE FROM public.casbin_rule WHERE ("ptype" = N'p') AND ("v0" = N'alice') AND ("v1" = N'data1') AND ("v2" = N'read') AND ("v3" = N'') AND ("v4" = N'') AND ("v5" = N'') AND (("v6" = '' OR "v6" IS NULL)) AND (("v7" = '' OR "v7" IS NULL))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			a, _ := newTestAdapter(t, tt.dialect)
+			ctx := context.Background()
+			table, err := a.getFullTableName(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := a.buildRemoveQuery(ctx, a.client, table, "p", []string{"alice", "data1", "read"})
+			if got := q.String(); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovePolicyKeepsLongerRule(t *testing.T) {
+	short := []string{"alice", "data1", "read"}
+	long := []string{"alice", "data1", "read", "", "", "", "", "eu"}
+	for _, prepared := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prepared=%v", prepared), func(t *testing.T) {
+			var options []Option
+			if prepared {
+				options = append(options, WithPreparedStatements())
+			}
+			a, f := newTestAdapter(t, "pg", options...)
+			if err := a.RemovePolicy("p", "p", short); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.RemovePolicy("p", "p", long); err != nil {
+				t.Fatal(err)
+			}
+			deletes := f.queries("DELETE")
+			if len(deletes) != 2 {
+				t.Fatalf("got deletes %q, want 2", deletes)
+			}
+			// The short rule only matches rows without v6 and v7, so
+			// that the stored long rule is left in place.
+			query := strings.ReplaceAll(deletes[0], `"`, "")
+			for _, column := range []string{"v6", "v7"} {
+				if !strings.Contains(query, "("+column+" = '' OR "+column+" IS NULL)") {
+					t.Errorf("got %q, want %s required empty", deletes[0], column)
+				}
+			}
+			query = strings.ReplaceAll(deletes[1], `"`, "")
+			if !strings.Contains(query, "(v6 = '' OR v6 IS NULL)") || strings.Contains(query, "v7 IS NULL") {
+				t.Errorf("got %q, want v6 required empty and v7 compared", deletes[1])
+			}
+			if !prepared && !strings.Contains(query, "v7 = 'eu'") {
+				t.Errorf("got %q, want v7 compared to eu", deletes[1])
+			}
+		})
+	}
+}
+
+func TestUpdatePolicyKeepsLongerRule(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if err := a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count(`AND (("v6" = '' OR "v6" IS NULL)) AND (("v7" = '' OR "v7" IS NULL))`); n != 1 {
+		t.Errorf("got statements %q, want the update restricted to rows without v6 and v7", f.queries("UPDATE"))
+	}
+}
+
+func TestRemovePolicyRunsInTransaction(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	got := f.queries("")
+	if len(got) != 3 || got[0] != "BEGIN" || got[2] != "COMMIT" {
+		t.Fatalf("got statements %q", got)
+	}
+	if !strings.HasPrefix(got[1], "DELETE FROM public.casbin_rule WHERE") {
+		t.Errorf("got %s", got[1])
+	}
+}
+
+func TestUpdatePolicySetsChangedColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new []string
+		want     string
+	}{
+		{"same length", []string{"alice", "data1", "read"}, []string{"alice", "data2", "read"}, `SET "v1" = 'data2' WHERE`},
+		{"shorter", []string{"alice", "data1", "read"}, []string{"alice", "data1"}, `SET "v2" = '' WHERE`},
+		{"longer", []string{"alice", "data1"}, []string{"alice", "data1", "write"}, `SET "v2" = 'write' WHERE`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, f := newTestAdapter(t, "pg")
+			if err := a.UpdatePolicy("p", "p", tt.old, tt.new); err != nil {
+				t.Fatal(err)
+			}
+			updates := f.queries("UPDATE")
+			if len(updates) != 1 {
+				t.Fatalf("got updates %q", updates)
+			}
+			if !strings.Contains(updates[0], tt.want) {
+				t.Errorf("got %s, want it to contain %s", updates[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePolicyUnchanged(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithInPlaceUpdate())
+	f.on("count(*)").returns([]string{"count"}, []driver.Value{int64(1)})
+	rule := []string{"alice", "data1", "read"}
+	if err := a.UpdatePolicies("p", "p", [][]string{rule}, [][]string{rule}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("UPDATE"); n != 0 {
+		t.Errorf("got %d updates, want none", n)
+	}
+}
+
+func TestSavePolicyResetsIdentity(t *testing.T) {
+	tests := []struct {
+		dialect string
+		reset   bool
+		want    string
+	}{
+		{"pg", true, "TRUNCATE TABLE public.casbin_rule RESTART IDENTITY"},
+		{"mysql", true, "ALTER TABLE casbin_rule AUTO_INCREMENT = 1"},
+		{"mssql", true, "DBCC CHECKIDENT (N'public.casbin_rule', RESEED, 0)"},
+		{"mysql", false, ""},
+		{"mssql", false, ""},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, test.dialect, WithTruncateResetIdentity(test.reset))
+		if err := a.SavePolicy(newTestModel(t, []string{"p", "alice", "data1", "read"})); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, q := range f.queries("") {
+			if strings.HasPrefix(q, "INSERT") {
+				break
+			}
+			if strings.Contains(q, "IDENTITY") || strings.Contains(q, "AUTO_INCREMENT") || strings.Contains(q, "CHECKIDENT") {
+				got = append(got, q)
+			}
+		}
+		if test.want == "" && len(got) != 0 {
+			t.Errorf("%s without reset ran %q, want no reset", test.dialect, got)
+		}
+		if test.want != "" && (len(got) != 1 || got[0] != test.want) {
+			t.Errorf("%s with reset ran %q before the insert, want %q", test.dialect, got, test.want)
+		}
+	}
+}
+
+func TestCustomTableNameForReadsAndWrites(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTableName("auth", "rules"))
+	ctx := context.Background()
+	rule := []string{"alice", "data1", "read"}
+	if err := a.AddPolicyCtx(ctx, "p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadPolicyCtx(ctx, newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.UpdatePolicyCtx(ctx, "p", "p", rule, []string{"bob", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicyCtx(ctx, "p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"INSERT INTO auth.rules ", "SELECT ", "UPDATE auth.rules ", "DELETE FROM auth.rules "} {
+		queries := f.queries(prefix)
+		if len(queries) == 0 {
+			t.Errorf("ran no %q statement", prefix)
+		}
+		for _, q := range queries {
+			if strings.Contains(q, "casbin_rule") && !strings.Contains(q, `AS "casbin_rule"`) {
+				t.Errorf("statement uses the default table: %s", q)
+			}
+		}
+	}
+	if n := f.count("FROM auth.rules AS "); n != 1 {
+		t.Errorf("got %d selects from auth.rules, want 1", n)
+	}
+}
+
+func TestEmptySchemaLeavesTableUnqualified(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTableName("", "casbin_rule"))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SavePolicy(newTestModel(t, []string{"p", "alice", "data1", "read"})); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"SELECT ", "TRUNCATE ", "INSERT "} {
+		queries := f.queries(prefix)
+		if len(queries) != 1 {
+			t.Fatalf("got %q, want one %q statement", queries, prefix)
+		}
+		if strings.Contains(queries[0], ".casbin_rule ") || !strings.Contains(queries[0], " casbin_rule ") {
+			t.Errorf("statement does not use the unqualified table: %s", queries[0])
+		}
+	}
+}
+
+func TestMySQLDefaultSchema(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql")
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 {
+		t.Errorf("got rules %q, want the stored rule", got)
+	}
+	if n := f.count("FROM casbin_rule AS"); n != 1 {
+		t.Errorf("got selects %q, want the unqualified table", f.queries("SELECT"))
+	}
+
+	// A schema set explicitly is kept.
+	a, f = newTestAdapter(t, "mysql", WithTableName("public", "casbin_rule"))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("FROM public.casbin_rule AS"); n != 1 {
+		t.Errorf("got selects %q, want the explicit schema", f.queries("SELECT"))
+	}
+}
+
+func TestRemoveFilteredPolicyHighColumns(t *testing.T) {
+	tests := []struct {
+		fieldIndex  int
+		fieldValues []string
+		want        string
+	}{
+		{6, []string{"tenant1"}, `DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v6" = 'tenant1')`},
+		{6, []string{"tenant1", "eu"}, `DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v6" = 'tenant1') AND ("v7" = 'eu')`},
+		{7, []string{"eu"}, `DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v7" = 'eu')`},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, "pg")
+		if err := a.RemoveFilteredPolicy("p", "p", test.fieldIndex, test.fieldValues...); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.queries("DELETE"); len(got) != 1 || got[0] != test.want {
+			t.Errorf("RemoveFilteredPolicy(%d, %q) ran %q, want %q", test.fieldIndex, test.fieldValues, got, test.want)
+		}
+	}
+}
+
+func TestUpdateFilteredPoliciesScopedToPtype(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on(`FROM public.casbin_rule AS "casbin_rule" WHERE ("ptype" = 'p') AND ("v0" = 'alice')`).
+		returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+	old, err := a.UpdateFilteredPolicies("p", "p", [][]string{{"alice", "data2", "read"}}, 0, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 1 || strings.Join(old[0], ",") != "alice,data1,read" {
+		t.Errorf("got old rules %q, want only the p rule", old)
+	}
+	if got := f.queries("SELECT"); len(got) != 1 {
+		t.Errorf("got selects %q, want one scoped to ptype p", got)
+	}
+	want := `DELETE FROM public.casbin_rule WHERE ("id" = 1)`
+	if got := f.queries("DELETE"); len(got) != 1 || got[0] != want {
+		t.Errorf("got deletes %q, want %q", got, want)
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithReadTable("casbin_rule_effective"))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadFilteredPolicy(newTestModel(t), Filter{Ptype: []string{"p"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, total := f.count("FROM public.casbin_rule_effective AS "), f.count("SELECT"); n != 2 || total != 2 {
+		t.Errorf("got %d of %d selects from the read table, want 2 of 2", n, total)
+	}
+	if n := f.count("INSERT INTO public.casbin_rule "); n != 1 {
+		t.Errorf("got %d inserts into the base table, want 1", n)
+	}
+}
+
+func TestSavePolicyN(t *testing.T) {
+	m := newTestModel(t,
+		[]string{"p", "alice", "data1", "read"},
+		[]string{"p", "alice", "data1", "write"},
+		[]string{"p", "bob", "data2", "read"},
+		[]string{"g", "alice", "admin"},
+		[]string{"g", "bob", "admin"},
+	)
+	// Postgres inserts with RETURNING, so bun counts the returned rows.
+	ids := func(n int) [][]driver.Value {
+		rows := make([][]driver.Value, n)
+		for i := range rows {
+			rows[i] = []driver.Value{int64(i + 1)}
+		}
+		return rows
+	}
+
+	a, f := newTestAdapter(t, "pg")
+	f.on("INSERT").returns([]string{"id"}, ids(5)...)
+	if n, err := a.SavePolicyN(m); err != nil || n != 5 {
+		t.Errorf("SavePolicyN() = %d, %v, want 5", n, err)
+	}
+
+	a, f = newTestAdapter(t, "pg", WithPtypeTable("g", "public", "casbin_role"))
+	f.on("INSERT INTO public.casbin_rule ").returns([]string{"id"}, ids(3)...)
+	f.on("INSERT INTO public.casbin_role ").returns([]string{"id"}, ids(2)...)
+	if n, err := a.SavePolicyN(m); err != nil || n != 5 {
+		t.Errorf("SavePolicyN() over two tables = %d, %v, want 5", n, err)
+	}
+
+	a, f = newTestAdapter(t, "mysql")
+	f.on("INSERT").affects(5)
+	if n, err := a.SavePolicyN(m); err != nil || n != 5 {
+		t.Errorf("SavePolicyN() on MySQL = %d, %v, want 5", n, err)
+	}
+
+	a, f = newTestAdapter(t, "pg")
+	if n, err := a.SavePolicyN(newTestModel(t)); err != nil || n != 0 {
+		t.Errorf("SavePolicyN() of an empty model = %d, %v, want 0", n, err)
+	}
+	if n := f.count("INSERT"); n != 0 {
+		t.Errorf("got %d inserts for an empty model, want none", n)
+	}
+}
+
+func TestAfterLoad(t *testing.T) {
+	rules := []*CasbinRule{
+		{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		{Id: 2, Ptype: "p", V0: "bob", V1: "data2", V2: "write"},
+		{Id: 3, Ptype: "g", V0: "alice", V1: "admin"},
+	}
+	calls := 0
+	a, f := newTestAdapter(t, "pg", WithAfterLoad(func(line *CasbinRule) {
+		calls++
+		line.V0 = strings.ToUpper(line.V0)
+	}))
+	f.on("SELECT").returnsRules(rules...)
+
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(rules) {
+		t.Errorf("LoadPolicy ran the hook %d times, want %d", calls, len(rules))
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 2 || got[0][0] != "ALICE" || got[1][0] != "BOB" {
+		t.Errorf("loaded %q, want the rules changed by the hook", got)
+	}
+
+	calls = 0
+	if err := a.LoadFilteredPolicy(newTestModel(t), Filter{Ptype: []string{"p", "g"}}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(rules) {
+		t.Errorf("LoadFilteredPolicy ran the hook %d times, want %d", calls, len(rules))
+	}
+}
+
+func TestBeforeSave(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithBeforeSave(func(ptype string, rule []string) []string {
+		if ptype == "p" && len(rule) > 1 {
+			rule[1] = strings.ToUpper(rule[1])
+		}
+		return rule
+	}))
+	rule := []string{"alice", "data1", "read"}
+	if err := a.AddPolicy("p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SavePolicy(newTestModel(t, []string{"p", "alice", "data1", "read"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.UpdatePolicy("p", "p", rule, []string{"alice", "data2", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicy("p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule[1] != "data1" {
+		t.Errorf("the hook changed the rule of the caller to %q", rule)
+	}
+
+	for _, q := range f.queries("INSERT") {
+		if !strings.Contains(q, "'DATA1'") {
+			t.Errorf("insert does not store the rule of the hook: %s", q)
+		}
+	}
+	wantUpdate := `UPDATE public.casbin_rule SET "v1" = 'DATA2' WHERE ("ptype" = 'p') AND ("v0" = 'alice') AND ("v1" = 'DATA1')`
+	if got := f.queries("UPDATE"); len(got) != 1 || !strings.HasPrefix(got[0], wantUpdate) {
+		t.Errorf("got updates %q, want one starting with %q", got, wantUpdate)
+	}
+	wantDelete := `DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v0" = 'alice') AND ("v1" = 'DATA1')`
+	if got := f.queries("DELETE"); len(got) != 1 || !strings.HasPrefix(got[0], wantDelete) {
+		t.Errorf("got deletes %q, want one starting with %q", got, wantDelete)
+	}
+}
+
+type tenantKey struct{}
+
+func TestTableNameFunc(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTableNameFunc(func(ctx context.Context) (string, string, error) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		if tenant == "" {
+			return "", "", errors.New("no tenant")
+		}
+		return "public", "casbin_rule_" + tenant, nil
+	}))
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	globex := context.WithValue(context.Background(), tenantKey{}, "globex")
+
+	if err := a.AddPolicyCtx(acme, "p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicyCtx(globex, "p", "p", []string{"bob", "data2", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadPolicyCtx(globex, newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range f.queries("alice") {
+		if !strings.Contains(q, "public.casbin_rule_acme ") {
+			t.Errorf("statement of tenant acme is not on its table: %s", q)
+		}
+	}
+	for _, q := range append(f.queries("bob"), f.queries("SELECT")...) {
+		if !strings.Contains(q, "public.casbin_rule_globex ") {
+			t.Errorf("statement of tenant globex is not on its table: %s", q)
+		}
+	}
+
+	f.reset()
+	if err := a.LoadPolicyCtx(context.Background(), newTestModel(t)); err == nil || !strings.Contains(err.Error(), "no tenant") {
+		t.Errorf("got error %v, want the error of the table func", err)
+	}
+	if queries := f.queries(""); len(queries) != 0 {
+		t.Errorf("ran %q without a table, want nothing", queries)
+	}
+}
+
+func TestAddPolicyReturningID(t *testing.T) {
+	tests := []struct {
+		dialect   string
+		returning string
+	}{
+		{"pg", `RETURNING "id" AS id`},
+		{"mysql", ""},
+		{"mssql", `OUTPUT INSERTED."id" AS id`},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, test.dialect)
+		if test.returning == "" {
+			f.on("INSERT").insertID(42)
+		} else {
+			f.on("INSERT").returns([]string{"id"}, []driver.Value{int64(42)})
+		}
+		id, err := a.AddPolicyReturningID(context.Background(), "p", []string{"alice", "data1", "read"})
+		if err != nil || id != 42 {
+			t.Errorf("%s: AddPolicyReturningID() = %d, %v, want 42", test.dialect, id, err)
+		}
+		insert := f.queries("INSERT")
+		if len(insert) != 1 {
+			t.Fatalf("%s: got inserts %q, want one", test.dialect, insert)
+		}
+		if test.returning == "" && (strings.Contains(insert[0], "RETURNING") || strings.Contains(insert[0], "OUTPUT")) {
+			t.Errorf("%s: insert returns columns: %s", test.dialect, insert[0])
+		}
+		if test.returning != "" && !strings.Contains(insert[0], test.returning) {
+			t.Errorf("%s: insert %s does not contain %q", test.dialect, insert[0], test.returning)
+		}
+	}
+}
+
+func TestTableNameValidation(t *testing.T) {
+	tests := []struct {
+		schema, table string
+		valid         bool
+	}{
+		{"public", "casbin_rule", true},
+		{"", "casbin_rule", true},
+		{"_auth2", "Rules_v2", true},
+		{"public", "rule; DROP TABLE x", false},
+		{"public; DROP TABLE x", "casbin_rule", false},
+		{"public", "2rules", false},
+		{"public", "", false},
+		{"public", "auth.rules", false},
+	}
+	for _, test := range tests {
+		_, db := newFakeDB(t, "pg")
+		_, err := NewAdapterWithClient(db, WithTableName(test.schema, test.table))
+		if test.valid && err != nil {
+			t.Errorf("WithTableName(%q, %q) failed: %v", test.schema, test.table, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("WithTableName(%q, %q) got error %v, want ErrInvalidIdentifier", test.schema, test.table, err)
+		}
+	}
+}
+
+func TestTableNameFuncValidation(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTableNameFunc(func(ctx context.Context) (string, string, error) {
+		return "public", "rule; DROP TABLE x", nil
+	}))
+	if err := a.LoadPolicy(newTestModel(t)); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("got error %v, want ErrInvalidIdentifier", err)
+	}
+	if queries := f.queries(""); len(queries) != 0 {
+		t.Errorf("ran %q, want nothing", queries)
+	}
+}
+
+func TestDB(t *testing.T) {
+	f, db := newFakeDB(t, "pg")
+	a, err := NewAdapterWithClient(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.DB() != db {
+		t.Fatal("DB() does not return the client of the adapter")
+	}
+	f.on("SELECT 1").returns([]string{"n"}, []driver.Value{int64(1)})
+	var n int
+	if err := a.DB().NewSelect().ColumnExpr("1").Scan(context.Background(), &n); err != nil || n != 1 {
+		t.Errorf("SELECT 1 = %d, %v, want 1", n, err)
+	}
+}
+
+func TestTableAlreadyPrefixed(t *testing.T) {
+	for _, options := range [][]Option{
+		{WithTableAlreadyPrefixed(), WithTableName("ignored", "otherdb.dbo.casbin_rule")},
+		{WithTableName("ignored", "otherdb.dbo.casbin_rule"), WithTableAlreadyPrefixed()},
+	} {
+		a, f := newTestAdapter(t, "mssql", options...)
+		if err := a.LoadPolicy(newTestModel(t)); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, prefix := range []string{"SELECT", "INSERT INTO otherdb.dbo.casbin_rule ", "DELETE FROM otherdb.dbo.casbin_rule "} {
+			if n := f.count(prefix); n != 1 {
+				t.Errorf("got %d %q statements, want 1", n, prefix)
+			}
+		}
+		if n := f.count("FROM otherdb.dbo.casbin_rule AS "); n != 1 {
+			t.Errorf("got %d selects from the qualified table, want 1", n)
+		}
+		if n := f.count("ignored"); n != 0 {
+			t.Errorf("got %d statements using the schema, want none", n)
+		}
+	}
+}
+
+func TestRemoveFilteredPolicies(t *testing.T) {
+	filters := []FieldFilter{
+		{FieldIndex: 0, FieldValues: []string{"alice"}},
+		{FieldIndex: 1, FieldValues: []string{"data2", "write"}},
+	}
+
+	a, f := newTestAdapter(t, "pg")
+	f.on(`("v0" = 'alice')`).affects(3)
+	f.on(`("v1" = 'data2') AND ("v2" = 'write')`).affects(2)
+	n, err := a.RemoveFilteredPolicies("p", "p", filters)
+	if err != nil || n != 5 {
+		t.Errorf("RemoveFilteredPolicies() = %d, %v, want 5", n, err)
+	}
+	want := []string{
+		"BEGIN",
+		`DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v0" = 'alice')`,
+		`DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v1" = 'data2') AND ("v2" = 'write')`,
+		"COMMIT",
+	}
+	if got := f.queries(""); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+
+	// A failing filter rolls back the removes of the ones before it.
+	a, f = newTestAdapter(t, "pg")
+	f.on(`("v1" = 'data2')`).fails(pgError("57014", "canceling statement due to statement timeout"))
+	if n, err := a.RemoveFilteredPolicies("p", "p", filters); err == nil || n != 0 {
+		t.Errorf("RemoveFilteredPolicies() = %d, %v, want 0 and an error", n, err)
+	}
+	if n := f.count("COMMIT"); n != 0 {
+		t.Errorf("got %d commits, want none", n)
+	}
+	if got := f.queries(""); got[len(got)-1] != "ROLLBACK" || f.count("DELETE") != 2 {
+		t.Errorf("got statements %q, want both removes rolled back", got)
+	}
+}
+
+func TestRowMapper(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithRowMapper(func(line *CasbinRule) (string, []string) {
+		return line.Ptype, []string{line.V0 + "/" + line.V1, line.V2, line.V3}
+	}))
+	f.on("SELECT").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "tenant1", V1: "alice", V2: "data1", V3: "read"},
+		&CasbinRule{Id: 2, Ptype: "p", V0: "tenant2", V1: "bob", V2: "data2", V3: "write"},
+	)
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"tenant1/alice", "data1", "read"}, {"tenant2/bob", "data2", "write"}}
+	if got := m.GetPolicy("p", "p"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+}
+
+func TestNullSafeMatching(t *testing.T) {
+	// The rule is stored with NULL in v2 to v5, which only the NULL-safe
+	// comparisons match.
+	for _, options := range [][]Option{
+		{WithNullSafeMatching()},
+		{WithNullSafeMatching(), WithPreparedStatements()},
+	} {
+		a, f := newTestAdapter(t, "pg", options...)
+		f.on("IS NULL").affects(1)
+		if err := a.RemovePolicy("g", "g", []string{"alice", "admin"}); err != nil {
+			t.Fatal(err)
+		}
+		deletes := f.queries("DELETE")
+		if len(deletes) != 1 {
+			t.Fatalf("got deletes %q, want one", deletes)
+		}
+		// The prepared delete does not quote the columns.
+		query := strings.ReplaceAll(deletes[0], `"`, "")
+		for i := 0; i < 6; i++ {
+			column := fmt.Sprintf("v%d", i)
+			if nullSafe := strings.Contains(query, column+" IS NULL"); nullSafe != (i >= 2) {
+				t.Errorf("got delete %q, want NULL matched in v2 to v5 only", deletes[0])
+			}
+		}
+	}
+
+	a, f := newTestAdapter(t, "pg")
+	if err := a.RemovePolicy("g", "g", []string{"alice", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	// Only the empty v6 and v7 also match NULL by default.
+	if n := f.count(`"v1" IS NULL`); n != 0 {
+		t.Errorf("got %d NULL-safe deletes without WithNullSafeMatching, want 0", n)
+	}
+}
+
+func TestClear(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if err := a.Clear(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "TRUNCATE TABLE public.casbin_rule RESTART IDENTITY", "COMMIT"}
+	if got := f.queries(""); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+	f.reset()
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 0 {
+		t.Errorf("got rules %q after Clear, want none", got)
+	}
+}
+
+func TestClearScoped(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTableNameFunc(func(ctx context.Context) (string, string, error) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return "public", "casbin_rule_" + tenant, nil
+	}))
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	if err := a.Clear(acme); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("TRUNCATE TABLE public.casbin_rule_acme "); n != 1 {
+		t.Errorf("got statements %q, want the table of acme truncated", f.queries(""))
+	}
+	if n := f.count("casbin_rule_globex"); n != 0 {
+		t.Errorf("got statements %q, want the table of globex untouched", f.queries(""))
+	}
+}
+
+func TestInsertOrder(t *testing.T) {
+	m := newTestModel(t,
+		[]string{"p", "admin", "data1", "read"},
+		[]string{"g", "alice", "admin"},
+	)
+	const p, g = "'p', 'admin'", "'g', 'alice'"
+	for _, test := range []struct {
+		name          string
+		options       []Option
+		first, second string
+	}{
+		{name: "default", first: p, second: g},
+		{name: "roles first", options: []Option{WithInsertOrder([]string{"g"})}, first: g, second: p},
+		{name: "unknown ptype", options: []Option{WithInsertOrder([]string{"g2", "p"})}, first: p, second: g},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a, f := newTestAdapter(t, "mysql", test.options...)
+			if err := a.SavePolicy(m); err != nil {
+				t.Fatal(err)
+			}
+			inserts := f.queries("INSERT")
+			if len(inserts) != 1 {
+				t.Fatalf("got inserts %q, want one", inserts)
+			}
+			first, second := strings.Index(inserts[0], test.first), strings.Index(inserts[0], test.second)
+			if first < 0 || second < first {
+				t.Errorf("got insert %q, want %s before %s", inserts[0], test.first, test.second)
+			}
+		})
+	}
+}
+
+func TestFilterEmptyColumn(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on(`("v2" = '' OR "v2" IS NULL)`).
+		returnsRules(&CasbinRule{Id: 1, Ptype: "g", V0: "alice", V1: "admin"})
+	m := newTestModel(t)
+	if err := a.LoadFilteredPolicy(m, Filter{Ptype: []string{"g"}, V2Empty: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("g", "g"); len(got) != 1 || strings.Join(got[0], ",") != "alice,admin" {
+		t.Errorf("got roles %q, want the short rule", got)
+	}
+	if n := f.count(`("v2" = '' OR "v2" IS NULL)`); n != 1 {
+		t.Errorf("got selects %q, want one on empty v2", f.queries("SELECT"))
+	}
+
+	// A table without v2 has only short rules.
+	a, f = newTestAdapter(t, "pg", WithColumnCount(2))
+	if err := a.LoadFilteredPolicy(newTestModel(t), Filter{Ptype: []string{"g"}, V2Empty: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("v2"); n != 0 {
+		t.Errorf("got selects %q, want no condition on v2", f.queries("SELECT"))
+	}
+}
+
+func TestInPlaceUpdate(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithInPlaceUpdate())
+	oldRules := [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}
+	newRules := [][]string{{"alice", "data1", "write"}, {"bob", "data3", "write"}}
+	if err := a.UpdatePolicies("p", "p", oldRules, newRules); err != nil {
+		t.Fatal(err)
+	}
+	// The rows are updated, so they keep their ids.
+	if n := f.count("DELETE") + f.count("INSERT"); n != 0 {
+		t.Errorf("got statements %q, want no deletes or inserts", f.queries(""))
+	}
+	updates := f.queries("UPDATE")
+	if len(updates) != 2 ||
+		!strings.Contains(updates[0], `"v2" = 'write'`) || !strings.Contains(updates[0], `("v0" = 'alice') AND ("v1" = 'data1') AND ("v2" = 'read')`) ||
+		!strings.Contains(updates[1], `"v1" = 'data3'`) || !strings.Contains(updates[1], `("v0" = 'bob') AND ("v1" = 'data2')`) {
+		t.Errorf("got updates %q, want each old rule set to the new rule at its index", updates)
+	}
+	for _, update := range updates {
+		if strings.Contains(update, `"id" =`) {
+			t.Errorf("got update %q, want the id left unchanged", update)
+		}
+	}
+
+	f.reset()
+	if err := a.UpdatePolicies("p", "p", oldRules, newRules[:1]); err == nil {
+		t.Error("got no error for rule lists of different lengths")
+	}
+	if n := f.count(""); n != 0 {
+		t.Errorf("got statements %q for rule lists of different lengths, want none", f.queries(""))
+	}
+}
+
+func TestUpdatePolicyReturningOld(t *testing.T) {
+	// Rules are stored lower-cased, so the stored rule differs from the
+	// one given.
+	a, f := newTestAdapter(t, "pg", WithBeforeSave(func(ptype string, rule []string) []string {
+		lower := make([]string, len(rule))
+		for i, value := range rule {
+			lower[i] = strings.ToLower(value)
+		}
+		return lower
+	}))
+	f.on(`SELECT`).returnsRules(&CasbinRule{Id: 3, Ptype: "p", V0: "alice", V1: "data1", V2: "read"}).times(1)
+
+	old, err := a.UpdatePolicyReturningOld("p", "p", []string{"Alice", "DATA1", "read"}, []string{"alice", "data1", "write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice", "data1", "read"}; fmt.Sprint(old) != fmt.Sprint(want) {
+		t.Errorf("got old rule %q, want the stored %q", old, want)
+	}
+	if got := f.queries(""); len(got) != 4 || !strings.HasPrefix(got[1], "SELECT") || !strings.HasPrefix(got[2], "UPDATE") {
+		t.Errorf("got statements %q, want a select then an update in one transaction", got)
+	}
+
+	f.reset()
+	if _, err := a.UpdatePolicyReturningOld("p", "p", []string{"bob", "data1", "read"}, []string{"bob", "data1", "write"}); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got error %v for a missing rule, want ErrPolicyNotFound", err)
+	}
+	if n := f.count("UPDATE"); n != 0 {
+		t.Errorf("got %d updates for a missing rule, want none", n)
+	}
+}
+
+func TestUpdatePoliciesMissingOldRule(t *testing.T) {
+	oldRules := [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}
+	newRules := [][]string{{"alice", "data1", "write"}, {"bob", "data3", "write"}}
+	for _, options := range [][]Option{nil, {WithInPlaceUpdate()}} {
+		a, f := newTestAdapter(t, "mysql", options...)
+		// bob's rule is not stored.
+		f.on("'bob'").affects(0)
+
+		err := a.UpdatePolicies("p", "p", oldRules, newRules)
+		if !errors.Is(err, ErrPolicyNotFound) || !strings.Contains(err.Error(), `["bob" "data2" "write"]`) {
+			t.Errorf("got error %v, want ErrPolicyNotFound naming bob's rule", err)
+		}
+		if n := f.count("COMMIT"); n != 0 {
+			t.Errorf("got statements %q, want no commit", f.queries(""))
+		}
+		if got := f.queries(""); got[len(got)-1] != "ROLLBACK" {
+			t.Errorf("got statements %q, want the update rolled back", got)
+		}
+		if n := f.count("INSERT"); n != 0 {
+			t.Errorf("got statements %q, want the new rules not inserted", f.queries(""))
+		}
+	}
+}
+
+func TestLoadPolicyFor(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("ptype in ('g')").
+		returnsRules(&CasbinRule{Id: 2, Ptype: "g", V0: "alice", V1: "admin"})
+	m := newTestModel(t)
+	if err := a.LoadPolicyFor(m, []string{"g"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("g", "g"); len(got) != 1 || strings.Join(got[0], ",") != "alice,admin" {
+		t.Errorf("got roles %q, want alice's role", got)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 0 {
+		t.Errorf("got policies %q, want none", got)
+	}
+	if selects := f.queries("SELECT"); len(selects) != 1 || f.count("ptype in ('g')") != 1 {
+		t.Errorf("got selects %q, want one on ptype g", selects)
+	}
+	if !a.IsFiltered() {
+		t.Error("IsFiltered() = false after LoadPolicyFor")
+	}
+
+	// No ptypes load nothing rather than everything.
+	a, f = newTestAdapter(t, "pg")
+	if err := a.LoadPolicyFor(newTestModel(t), nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("SELECT"); n != 0 {
+		t.Errorf("got selects %q, want none", f.queries("SELECT"))
+	}
+	if !a.IsFiltered() {
+		t.Error("IsFiltered() = false after LoadPolicyFor without ptypes")
+	}
+}
+
+func TestLoadFilteredPolicyN(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("v0 in ('alice')").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "p", V0: "alice", V1: "data2", V2: "read"},
+		&CasbinRule{Id: 3, Ptype: "p", V0: "alice", V1: "data2", V2: "write"},
+	)
+	m := newTestModel(t)
+	n, err := a.LoadFilteredPolicyN(m, Filter{V0: []string{"alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); n != 3 || len(got) != 3 {
+		t.Errorf("got %d rows and policies %q, want 3", n, got)
+	}
+
+	n, err = a.LoadFilteredPolicyN(newTestModel(t), Filter{V0: []string{"bob"}})
+	if err != nil || n != 0 {
+		t.Errorf("LoadFilteredPolicyN() = %d, %v for no matching rows, want 0", n, err)
+	}
+	if n, err := a.LoadFilteredPolicyN(newTestModel(t), "v0 = 'alice'"); err == nil || n != 0 {
+		t.Errorf("LoadFilteredPolicyN() = %d, %v for an invalid filter, want an error", n, err)
+	}
+}
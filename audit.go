@@ -0,0 +1,81 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// WithAuditTable makes RemovePolicy, RemovePolicies and the filtered removes
+// copy the rows they delete into the table name, in the schema of the table
+// they are deleted from, within the same transaction. The audit table needs the policy columns and a
+// deleted_at timestamp column, which is set to the time of the removal.
+func WithAuditTable(name string) Option {
+	return func(a *Adapter) error {
+		if err := validateIdentifier(name); err != nil {
+			return err
+		}
+		a.auditTable = name
+		return nil
+	}
+}
+
+// auditPolicy copies the stored rows of the given rule to the audit table.
+func (a *Adapter) auditPolicy(ctx context.Context, tx bun.Tx, table, ptype string, rule []string) error {
+	if a.auditTable == "" {
+		return nil
+	}
+	instance, err := a.toInstance(ptype, rule)
+	if err != nil {
+		return err
+	}
+	return a.auditRemoved(ctx, tx, table, ptype, func(q bun.QueryBuilder) error {
+		a.wherePolicy(q, instance)
+		return nil
+	})
+}
+
+// auditRemoved copies the rows of table selected by where, storing rules of
+// ptype, to the audit table, if there is one.
+func (a *Adapter) auditRemoved(ctx context.Context, tx bun.Tx, table, ptype string, where func(q bun.QueryBuilder) error) error {
+	if a.auditTable == "" {
+		return nil
+	}
+	schema, err := a.ptypeSchema(ctx, ptype)
+	if err != nil {
+		return err
+	}
+	columns := a.policyColumns()
+	sel := tx.NewSelect().
+		TableExpr(table).
+		Column(columns...).
+		ColumnExpr("CURRENT_TIMESTAMP")
+	if err := where(sel.QueryBuilder()); err != nil {
+		return err
+	}
+	_, err = tx.NewRaw("INSERT INTO ? (?, deleted_at) ?",
+		bun.Safe(a.joinTableName(schema, a.auditTable)),
+		bun.Safe(strings.Join(columns, ", ")),
+		sel).Conn(a.queryConn(ctx, tx)).Exec(uncounted(ctx))
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAuditTable(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithAuditTable("casbin_rule_audit"))
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	where := ` WHERE ("ptype" = 'p') AND ("v0" = 'alice') AND ("v1" = 'data1') AND ("v2" = 'read') AND ("v3" = '') AND ("v4" = '') AND ("v5" = '') AND (("v6" = '' OR "v6" IS NULL)) AND (("v7" = '' OR "v7" IS NULL))`
+	want := []string{
+		"BEGIN",
+		`INSERT INTO public.casbin_rule_audit (ptype, v0, v1, v2, v3, v4, v5, v6, v7, deleted_at) SELECT "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7", CURRENT_TIMESTAMP FROM public.casbin_rule` + where,
+		"DELETE FROM public.casbin_rule" + where,
+		"COMMIT",
+	}
+	if got := f.queries(""); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+
+	f.reset()
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data1"); err != nil {
+		t.Fatal(err)
+	}
+	audits := f.queries("INSERT INTO public.casbin_rule_audit")
+	if len(audits) != 1 || !strings.HasSuffix(audits[0], ` WHERE ("ptype" = 'p') AND ("v1" = 'data1')`) {
+		t.Errorf("got audit copies %q, want the filtered rows copied", audits)
+	}
+}
+
+func TestAuditTableFails(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithAuditTable("casbin_rule_audit"))
+	f.on("casbin_rule_audit").fails(pgError("42P01", `relation "public.casbin_rule_audit" does not exist`))
+	if err := a.RemovePolicies("p", "p", [][]string{{"alice", "data1", "read"}}); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := f.count("DELETE"); n != 0 {
+		t.Errorf("got %d deletes, want none without a copy", n)
+	}
+	if n := f.count("ROLLBACK"); n != 1 {
+		t.Errorf("got statements %q, want a rollback", f.queries(""))
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// RemoveFilteredPolicyBatched removes the rules RemoveFilteredPolicy would,
+// deleting at most batchSize rows per transaction, and returns the number of
+// rows removed. It is meant for cleanups of many rows, where one DELETE would
+// hold its locks for long. The write timeout applies to each batch.
+//
+// The removal is not atomic: if a batch fails, the rows of the earlier
+// batches stay removed, and the returned count says how many.
+func (a *Adapter) RemoveFilteredPolicyBatched(ctx context.Context, ptype string, fieldIndex int, batchSize int, fieldValues ...string) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "RemoveFilteredPolicyBatched")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	if batchSize < 1 {
+		return 0, errors.Errorf("invalid remove batch size %d", batchSize)
+	}
+	tables, err := a.ptypeTableNames(ctx, ptype)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	first := true
+	for _, table := range tables {
+		for {
+			n, err := a.removeFilteredBatch(ctx, table, ptype, fieldIndex, fieldValues, batchSize, first)
+			if err != nil {
+				return total, errors.Wrapf(err, "removing batch after %d rows", total)
+			}
+			first = false
+			total += n
+			if n < int64(batchSize) {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// removeFilteredBatch removes, in its own transaction, up to limit of the
+// rows of table matching the filter, selected by id, and returns the number
+// of rows removed. first publishes the outbox event of the removal.
+func (a *Adapter) removeFilteredBatch(ctx context.Context, table, ptype string, fieldIndex int, fieldValues []string, limit int, first bool) (int64, error) {
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	var n int64
+	err := a.withTx(ctx, func(tx bun.Tx) error {
+		n = 0
+		if first {
+			if err := a.publishFiltered(ctx, tx, ptype, fieldIndex, fieldValues); err != nil {
+				return err
+			}
+		}
+		idCol := bun.Ident(a.idColumnName())
+		var ids []int64
+		q := tx.NewSelect().
+			Conn(a.queryConn(ctx, tx)).
+			TableExpr(table).
+			ColumnExpr("?", idCol).
+			Where("? = ?", bun.Ident(a.ptypeColumnName()), ptype)
+		if err := a.whereFieldValues(q.QueryBuilder(), fieldIndex, fieldValues); err != nil {
+			return err
+		}
+		if err := q.OrderExpr("? ASC", idCol).Limit(limit).Scan(ctx, &ids); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		err := a.auditRemoved(ctx, tx, table, ptype, func(q bun.QueryBuilder) error {
+			q.Where("? IN (?)", idCol, bun.In(ids))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		res, err := tx.NewDelete().
+			Conn(a.queryConn(ctx, tx)).
+			Model((*CasbinRule)(nil)).
+			ModelTableExpr(table).
+			Where("? IN (?)", idCol, bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n, err
+}
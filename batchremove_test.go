@@ -0,0 +1,108 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// onMatchingIDs scripts the selects of f to return the ids 1 to n in batches
+// of size, like a table with n rows matching a batched remove.
+func onMatchingIDs(f *fakeDB, n, size int) {
+	for start := 1; start <= n; start += size {
+		var rows [][]driver.Value
+		for id := start; id < start+size && id <= n; id++ {
+			rows = append(rows, []driver.Value{int64(id)})
+		}
+		f.on("SELECT").returns([]string{"id"}, rows...).times(1)
+	}
+	f.on("SELECT").returns([]string{"id"})
+}
+
+func TestRemoveFilteredPolicyBatched(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	onMatchingIDs(f, 5000, 1000)
+	f.on("DELETE").affects(1000)
+
+	n, err := a.RemoveFilteredPolicyBatched(context.Background(), "p", 1, 1000, "data1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5000 {
+		t.Errorf("removed %d rows, want 5000", n)
+	}
+	// Five full batches, then one finding nothing left.
+	if commits := f.count("COMMIT"); commits != 6 {
+		t.Errorf("got %d transactions, want 6", commits)
+	}
+	selects := f.queries("SELECT")
+	if len(selects) != 6 {
+		t.Errorf("got %d selects, want 6", len(selects))
+	}
+	want := `SELECT "id" FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v1" = 'data1') ORDER BY "id" ASC LIMIT 1000`
+	for _, q := range selects {
+		if q != want {
+			t.Errorf("got select %q, want %q", q, want)
+		}
+	}
+	deletes := f.queries("DELETE")
+	if len(deletes) != 5 {
+		t.Fatalf("got %d deletes, want 5", len(deletes))
+	}
+	for i, q := range deletes {
+		first, last := i*1000+1, (i+1)*1000
+		prefix := fmt.Sprintf(`DELETE FROM public.casbin_rule WHERE ("id" IN (%d, `, first)
+		if !strings.HasPrefix(q, prefix) || !strings.HasSuffix(q, fmt.Sprintf(", %d))", last)) || strings.Count(q, ",") != 999 {
+			t.Errorf("delete %d: got %.80q, want ids %d to %d", i, q, first, last)
+		}
+	}
+}
+
+func TestRemoveFilteredPolicyBatchedFailure(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	onMatchingIDs(f, 5000, 1000)
+	f.on("DELETE").affects(1000).times(2)
+	f.on("DELETE").fails(pgError("55P03", "could not obtain lock on row"))
+
+	n, err := a.RemoveFilteredPolicyBatched(context.Background(), "p", 1, 1000, "data1")
+	if err == nil || !strings.Contains(err.Error(), "after 2000 rows") {
+		t.Fatalf("got error %v, want a failure after 2000 rows", err)
+	}
+	if n != 2000 {
+		t.Errorf("got %d rows removed, want 2000", n)
+	}
+	if f.count("COMMIT") != 2 || f.count("ROLLBACK") != 1 {
+		t.Errorf("got statements %q, want 2 commits and a rollback", statementKinds(f))
+	}
+}
+
+func TestRemoveFilteredPolicyBatchedInvalidSize(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if _, err := a.RemoveFilteredPolicyBatched(context.Background(), "p", 0, 0, "alice"); err == nil {
+		t.Error("got no error for a batch size of 0")
+	}
+	if n := len(f.queries("")); n != 0 {
+		t.Errorf("got %d statements, want none", n)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	// stagingTable is the temporary table WithBulkSave stages rows in.
+	stagingTable = "casbin_rule_staging"
+	// stagingChunkSize is the number of rows per INSERT into the staging
+	// table when COPY is not available.
+	stagingChunkSize = 1000
+)
+
+// stagingMethod is how fillStaging writes the rows of the staging table.
+type stagingMethod int
+
+const (
+	// stagingInsert inserts the rows in chunks of stagingChunkSize.
+	stagingInsert stagingMethod = iota
+	// stagingCopy streams the rows with lib/pq's COPY FROM STDIN.
+	stagingCopy
+	// stagingLoadData streams the rows with go-sql-driver/mysql's LOAD
+	// DATA LOCAL INFILE.
+	stagingLoadData
+)
+
+// libPQDriver is the package path and name of the driver type of lib/pq,
+// which is recognized without importing it.
+var libPQDriver = [2]string{"github.com/lib/pq", "Driver"}
+
+var (
+	// stagingReaders numbers the reader handlers loadStaging registers, as
+	// go-sql-driver/mysql keeps them in a global registry.
+	stagingReaders uint64
+
+	// registerReaderHandler and deregisterReaderHandler manage the reader
+	// registry of go-sql-driver/mysql; tests replace them to read what
+	// LOAD DATA would.
+	registerReaderHandler   = mysql.RegisterReaderHandler
+	deregisterReaderHandler = mysql.DeregisterReaderHandler
+)
+
+// loadDataEscaper escapes the characters LOAD DATA reads as separators or
+// escapes with its default FIELDS and LINES clauses.
+var loadDataEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// WithBulkSave makes SavePolicy and ImportCSV write the rows to a temporary
+// staging table first and move them to the policy table with a single
+// INSERT ... SELECT, instead of sending all rows in one huge INSERT.
+//
+// On Postgres with lib/pq the staging table is filled with COPY, and on
+// MySQL with go-sql-driver/mysql with LOAD DATA LOCAL INFILE, which needs
+// local_infile to be enabled on the server. With other drivers, such as pgx
+// or pgdriver, it is filled with INSERTs of a bounded number of rows, as
+// COPY and LOAD DATA need APIs specific to the driver; WithLogger reports
+// the fallback when the adapter is created. Other dialects keep the default
+// insert.
+func WithBulkSave() Option {
+	return func(a *Adapter) error {
+		a.bulkSave = true
+		return nil
+	}
+}
+
+// bulkInsertRules inserts lines into table through the staging table.
+func (a *Adapter) bulkInsertRules(ctx context.Context, tx bun.Tx, table string, lines []*CasbinRule) (int64, error) {
+	var create, drop string
+	switch a.client.Dialect().Name() {
+	case dialect.PG:
+		create = "CREATE TEMPORARY TABLE ? (LIKE ? INCLUDING DEFAULTS)"
+		drop = "DROP TABLE ?"
+	case dialect.MySQL:
+		create = "CREATE TEMPORARY TABLE ? LIKE ?"
+		drop = "DROP TEMPORARY TABLE ?"
+	default:
+		return a.insertRules(ctx, tx, table, lines)
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if _, err := tx.NewRaw(create, bun.Ident(stagingTable), bun.Safe(table)).Conn(a.queryConn(ctx, tx)).Exec(ctx); err != nil {
+		return 0, err
+	}
+	if err := a.fillStaging(uncounted(ctx), tx, lines); err != nil {
+		return 0, err
+	}
+
+	columns := a.policyColumns()
+	if a.extraColumn != "" {
+		columns = append(columns, a.extraColumn)
+	}
+	idents := make([]interface{}, len(columns))
+	for i, column := range columns {
+		idents[i] = bun.Ident(column)
+	}
+	list := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	keyword, suffix := a.ignoreDuplicatesSQL()
+	query := fmt.Sprintf("INSERT%s INTO ? (%s) SELECT %s FROM ?%s", keyword, list, list, suffix)
+	args := append(append(append([]interface{}{bun.Safe(table)}, idents...), idents...), bun.Ident(stagingTable))
+	res, err := tx.NewRaw(query, args...).Conn(a.queryConn(ctx, tx)).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// Drop the staging table right away rather than at the end of the
+	// transaction, as a save through several tables stages each of them.
+	if _, err := tx.NewRaw(drop, bun.Ident(stagingTable)).Conn(a.queryConn(ctx, tx)).Exec(ctx); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// stagingMethodOf returns how the staging table can be filled with the
+// driver d of a client of the dialect name.
+func stagingMethodOf(name dialect.Name, d driver.Driver) stagingMethod {
+	switch name {
+	case dialect.PG:
+		t := reflect.TypeOf(d)
+		if t != nil && t.Kind() == reflect.Ptr && t.Elem().PkgPath() == libPQDriver[0] && t.Elem().Name() == libPQDriver[1] {
+			return stagingCopy
+		}
+	case dialect.MySQL:
+		if _, ok := d.(*mysql.MySQLDriver); ok {
+			return stagingLoadData
+		}
+	}
+	return stagingInsert
+}
+
+// fillStaging writes lines to the staging table.
+func (a *Adapter) fillStaging(ctx context.Context, tx bun.Tx, lines []*CasbinRule) error {
+	switch a.staging {
+	case stagingCopy:
+		return a.copyStaging(ctx, tx, lines)
+	case stagingLoadData:
+		return a.loadStaging(ctx, tx, lines)
+	}
+	for start := 0; start < len(lines); start += stagingChunkSize {
+		end := start + stagingChunkSize
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if _, err := a.insertRules(ctx, tx, stagingTable, lines[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyStaging writes lines to the staging table with lib/pq's COPY FROM
+// STDIN protocol: one Exec per row, then an Exec without arguments to
+// flush.
+func (a *Adapter) copyStaging(ctx context.Context, tx bun.Tx, lines []*CasbinRule) error {
+	columns := a.policyColumns()
+	if a.extraColumn != "" {
+		columns = append(columns, a.extraColumn)
+	}
+	stmt, err := tx.Tx.PrepareContext(ctx,
+		fmt.Sprintf("COPY %s (%s) FROM STDIN", a.identList([]string{stagingTable}), a.identList(columns)))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, line := range lines {
+		args := a.insertValues(line)
+		if a.extraColumn != "" {
+			args = append(args, line.Extra)
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// loadStaging writes lines to the staging table with LOAD DATA LOCAL INFILE,
+// reading them from a reader registered with go-sql-driver/mysql.
+func (a *Adapter) loadStaging(ctx context.Context, tx bun.Tx, lines []*CasbinRule) error {
+	columns := a.policyColumns()
+	if a.extraColumn != "" {
+		columns = append(columns, a.extraColumn)
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		args := a.insertValues(line)
+		if a.extraColumn != "" {
+			args = append(args, line.Extra)
+		}
+		for i, arg := range args {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			writeLoadDataValue(&buf, arg)
+		}
+		buf.WriteByte('\n')
+	}
+
+	name := fmt.Sprintf("%s_%d", stagingTable, atomic.AddUint64(&stagingReaders, 1))
+	registerReaderHandler(name, func() io.Reader { return &buf })
+	defer deregisterReaderHandler(name)
+
+	args := []interface{}{"Reader::" + name, bun.Ident(stagingTable)}
+	for _, column := range columns {
+		args = append(args, bun.Ident(column))
+	}
+	list := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE ? INTO TABLE ? CHARACTER SET utf8mb4 (%s)", list)
+	_, err := tx.NewRaw(query, args...).Conn(a.queryConn(ctx, tx)).Exec(ctx)
+	return err
+}
+
+// writeLoadDataValue writes value to buf as a field of LOAD DATA, with \N
+// for NULL.
+func writeLoadDataValue(buf *bytes.Buffer, value interface{}) {
+	switch value := value.(type) {
+	case nil:
+		buf.WriteString(`\N`)
+	case []byte:
+		if value == nil {
+			buf.WriteString(`\N`)
+			return
+		}
+		_, _ = loadDataEscaper.WriteString(buf, string(value))
+	case string:
+		_, _ = loadDataEscaper.WriteString(buf, value)
+	default:
+		_, _ = loadDataEscaper.WriteString(buf, fmt.Sprint(value))
+	}
+}
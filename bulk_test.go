@@ -0,0 +1,221 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun/dialect"
+)
+
+func TestBulkSaveDropsStagingTableAfterEachUse(t *testing.T) {
+	for _, name := range []string{"pg", "mysql"} {
+		t.Run(name, func(t *testing.T) {
+			a, f := newTestAdapter(t, name, WithBulkSave(), WithPtypeTable("g", "public", "casbin_role"))
+			input := "p, alice, data1, read\ng, alice, admin\np, bob, data2, write\n"
+			if err := a.ImportCSV(context.Background(), strings.NewReader(input), false); err != nil {
+				t.Fatal(err)
+			}
+			var staged []string
+			for _, q := range f.queries("casbin_rule_staging") {
+				switch {
+				case strings.HasPrefix(q, "CREATE TEMPORARY TABLE"):
+					staged = append(staged, "create")
+				case strings.HasPrefix(q, "DROP"):
+					staged = append(staged, "drop")
+				}
+			}
+			if got, want := strings.Join(staged, " "), "create drop create drop"; got != want {
+				t.Errorf("got staging statements %q, want %q", got, want)
+			}
+			if n := f.count("ON COMMIT DROP"); n != 0 {
+				t.Errorf("got %d staging tables dropped on commit, want none", n)
+			}
+		})
+	}
+}
+
+func TestBulkSaveChunksStagingInserts(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithBulkSave())
+	var input strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&input, "p, user%d, data%d, read\n", i, i)
+	}
+	f.on("INSERT INTO public.casbin_rule").affects(100000)
+	if err := a.ImportCSV(context.Background(), strings.NewReader(input.String()), false); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("INSERT INTO casbin_rule_staging"); n != 100000/stagingChunkSize {
+		t.Errorf("got %d inserts into the staging table, want %d", n, 100000/stagingChunkSize)
+	}
+	moves := f.queries(") SELECT ")
+	if len(moves) != 1 || !strings.HasPrefix(moves[0], "INSERT INTO public.casbin_rule") {
+		t.Errorf("got statements %q, want one INSERT ... SELECT", moves)
+	}
+}
+
+func TestWriteLoadDataValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"alice", `alice`},
+		{"a\tb\nc\\d\x00", `a\tb\nc\\d\0`},
+		{"", ``},
+		{nil, `\N`},
+		{[]byte(nil), `\N`},
+		{[]byte(`{"a":1}`), `{"a":1}`},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writeLoadDataValue(&buf, test.value)
+		if got := buf.String(); got != test.want {
+			t.Errorf("writeLoadDataValue(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+// stagedMove is the statement moving the staged rows to the policy table of
+// pg or mysql.
+func stagedMove(table, quote string) string {
+	columns := []string{"ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7"}
+	for i, column := range columns {
+		columns[i] = quote + column + quote
+	}
+	list := strings.Join(columns, ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %scasbin_rule_staging%s", table, list, list, quote, quote)
+}
+
+func TestBulkSaveCopy(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithBulkSave())
+	// The fake driver stands in for lib/pq, which answers COPY FROM STDIN
+	// with one Exec per row on a prepared statement.
+	a.staging = stagingCopy
+	var input strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&input, "p, user%d, data%d, read\n", i, i)
+	}
+	f.on(stagedMove("public.casbin_rule", `"`)).affects(100000)
+	if err := a.ImportCSV(context.Background(), strings.NewReader(input.String()), false); err != nil {
+		t.Fatal(err)
+	}
+
+	copyQuery := `COPY "casbin_rule_staging" ("ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7") FROM STDIN`
+	if n, _ := f.prepared(copyQuery); n != 1 {
+		t.Errorf("got %d COPY statements prepared, want 1", n)
+	}
+	rows := f.args(copyQuery)
+	if len(rows) != 100001 || len(rows[100000]) != 0 {
+		t.Fatalf("got %d COPY execs, want one per row and a flush", len(rows))
+	}
+	for i, row := range rows[:100000] {
+		want := []driver.Value{"p", fmt.Sprintf("user%d", i), fmt.Sprintf("data%d", i), "read", "", "", "", "", ""}
+		if !reflect.DeepEqual(row, want) {
+			t.Fatalf("got row %d copied as %q, want %q", i, row, want)
+		}
+	}
+	if n := f.count("INSERT INTO casbin_rule_staging"); n != 0 {
+		t.Errorf("got %d inserts into the staging table, want none", n)
+	}
+	// All copied rows move to the policy table in one statement.
+	want := []string{"BEGIN", "CREATE", "COPY", "INSERT", "DROP", "COMMIT"}
+	var got []string
+	for _, kind := range statementKinds(f) {
+		if len(got) == 0 || got[len(got)-1] != kind {
+			got = append(got, kind)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+	if n := f.count(stagedMove("public.casbin_rule", `"`)); n != 1 {
+		t.Errorf("got statements %q, want the staged rows moved to the policy table", f.queries("INSERT"))
+	}
+}
+
+func TestBulkSaveLoadData(t *testing.T) {
+	readers := make(map[string]func() io.Reader)
+	register, deregister := registerReaderHandler, deregisterReaderHandler
+	registerReaderHandler = func(name string, handler func() io.Reader) { readers[name] = handler }
+	deregisterReaderHandler = func(name string) {}
+	defer func() { registerReaderHandler, deregisterReaderHandler = register, deregister }()
+
+	a, f := newTestAdapter(t, "mysql", WithBulkSave())
+	// The fake driver stands in for go-sql-driver/mysql, which reads the
+	// registered reader for LOAD DATA.
+	a.staging = stagingLoadData
+	input := "p, alice, data1, read\ng, bob, admin\np, \"tab\there\", data2, write\n"
+	if err := a.ImportCSV(context.Background(), strings.NewReader(input), false); err != nil {
+		t.Fatal(err)
+	}
+
+	loads := f.queries("LOAD DATA")
+	if len(loads) != 1 || len(readers) != 1 {
+		t.Fatalf("got statements %q and %d readers, want one LOAD DATA", f.queries(""), len(readers))
+	}
+	for name, handler := range readers {
+		want := "LOAD DATA LOCAL INFILE 'Reader::" + name + "' INTO TABLE `casbin_rule_staging` CHARACTER SET utf8mb4 " +
+			"(`ptype`, `v0`, `v1`, `v2`, `v3`, `v4`, `v5`, `v6`, `v7`)"
+		if loads[0] != want {
+			t.Errorf("got %q, want %q", loads[0], want)
+		}
+		data, err := io.ReadAll(handler())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := "p\talice\tdata1\tread\t\t\t\t\t\n" +
+			"g\tbob\tadmin\t\t\t\t\t\t\n" +
+			"p\ttab\\there\tdata2\twrite\t\t\t\t\t\n"
+		if string(data) != rows {
+			t.Errorf("got rows %q loaded, want %q", data, rows)
+		}
+	}
+	if n := f.count(stagedMove("casbin_rule", "`")); n != 1 {
+		t.Errorf("got statements %q, want the staged rows moved to the policy table", f.queries("INSERT"))
+	}
+}
+
+func TestBulkSaveStagingMethod(t *testing.T) {
+	for _, name := range []string{"pg", "mysql"} {
+		l := new(captureLogger)
+		a, _ := newTestAdapter(t, name, WithBulkSave(), WithLogger(l))
+		if a.staging != stagingInsert {
+			t.Errorf("%s: got staging method %d with the fake driver, want INSERTs", name, a.staging)
+		}
+		if len(l.debugs) != 1 || !strings.Contains(l.debugs[0], "staging rows with INSERTs") {
+			t.Errorf("%s: got debug messages %q, want the fallback reported", name, l.debugs)
+		}
+	}
+
+	if got := stagingMethodOf(dialect.MySQL, &mysql.MySQLDriver{}); got != stagingLoadData {
+		t.Errorf("got staging method %d with go-sql-driver/mysql, want LOAD DATA", got)
+	}
+	if got := stagingMethodOf(dialect.PG, &mysql.MySQLDriver{}); got != stagingInsert {
+		t.Errorf("got staging method %d with go-sql-driver/mysql on pg, want INSERTs", got)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithCache makes LoadPolicy serve the rules it loaded from memory for ttl,
+// instead of querying the storage on every reload. Every write made through
+// the adapter drops the cache; writes made by other processes or through DB
+// are only seen once ttl expires or after InvalidateCache.
+func WithCache(ttl time.Duration) Option {
+	return func(a *Adapter) error {
+		if ttl <= 0 {
+			return errors.Errorf("invalid cache ttl %s", ttl)
+		}
+		a.cache = &policyCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+		return nil
+	}
+}
+
+// InvalidateCache drops the rules cached by WithCache, if any.
+func (a *Adapter) InvalidateCache() {
+	if a.cache != nil {
+		a.cache.invalidate()
+	}
+}
+
+// policyCache holds the stored rows of the tables loaded by LoadPolicy.
+type policyCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	gen     uint64
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	rows    []CasbinRule
+	expires time.Time
+}
+
+// get returns copies of the rows cached under key, if not expired.
+func (c *policyCache) get(key string) ([]*CasbinRule, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	rows := make([]*CasbinRule, len(entry.rows))
+	for i := range entry.rows {
+		row := entry.rows[i]
+		rows[i] = &row
+	}
+	return rows, true
+}
+
+// generation returns the number of invalidations so far, to be passed to put
+// by a load starting now.
+func (c *policyCache) generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.gen
+}
+
+// put caches copies of rows under key, unless the cache was invalidated since
+// gen: the rows may then predate a write.
+func (c *policyCache) put(key string, gen uint64, rows []*CasbinRule) {
+	entry := cacheEntry{rows: make([]CasbinRule, len(rows)), expires: time.Now().Add(c.ttl)}
+	for i, row := range rows {
+		entry.rows[i] = *row
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.entries[key] = entry
+	}
+}
+
+func (c *policyCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]cacheEntry)
+}
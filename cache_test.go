@@ -0,0 +1,159 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// selectRecorder is a bun query hook recording the SELECT queries run.
+type selectRecorder struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (r *selectRecorder) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (r *selectRecorder) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Operation() == "SELECT" {
+		r.mu.Lock()
+		r.queries = append(r.queries, event.Query)
+		r.mu.Unlock()
+	}
+}
+
+func (r *selectRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.queries)
+}
+
+// newCachedAdapter returns an adapter with WithCache(ttl) on a fakeDB holding
+// one rule, and the counter of the queries of its client.
+func newCachedAdapter(t *testing.T, ttl time.Duration) (*Adapter, *selectRecorder) {
+	t.Helper()
+	f, db := newFakeDB(t, "pg")
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+	counter := new(selectRecorder)
+	db.AddQueryHook(counter)
+	a, err := NewAdapterWithClient(db, WithCache(ttl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, counter
+}
+
+func TestCacheHit(t *testing.T) {
+	a, counter := newCachedAdapter(t, time.Hour)
+	for i := 0; i < 3; i++ {
+		m := newTestModel(t)
+		if err := a.LoadPolicy(m); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.GetPolicy("p", "p"); len(got) != 1 {
+			t.Fatalf("load %d: got rules %q, want the stored rule", i+1, got)
+		}
+	}
+	if n := counter.count(); n != 1 {
+		t.Errorf("got %d selects for 3 loads, want 1", n)
+	}
+}
+
+func TestCacheInvalidatedByWrites(t *testing.T) {
+	empty := newTestModel(t)
+	writes := map[string]func(a *Adapter) error{
+		"AddPolicy": func(a *Adapter) error {
+			return a.AddPolicy("p", "p", []string{"bob", "data1", "read"})
+		},
+		"RemovePolicy": func(a *Adapter) error {
+			return a.RemovePolicy("p", "p", []string{"alice", "data1", "read"})
+		},
+		"UpdatePolicy": func(a *Adapter) error {
+			return a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "write"})
+		},
+		"SavePolicy": func(a *Adapter) error {
+			return a.SavePolicy(empty)
+		},
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			a, counter := newCachedAdapter(t, time.Hour)
+			if err := a.LoadPolicy(newTestModel(t)); err != nil {
+				t.Fatal(err)
+			}
+			if err := write(a); err != nil {
+				t.Fatal(err)
+			}
+			before := counter.count()
+			if err := a.LoadPolicy(newTestModel(t)); err != nil {
+				t.Fatal(err)
+			}
+			if n := counter.count() - before; n != 1 {
+				t.Errorf("got %d selects for a load after %s, want 1", n, name)
+			}
+		})
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	a, counter := newCachedAdapter(t, 20*time.Millisecond)
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.count(); n != 2 {
+		t.Errorf("got %d selects, want the load after the ttl to query again", n)
+	}
+}
+
+func TestCacheConcurrentLoads(t *testing.T) {
+	a, _ := newCachedAdapter(t, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		m := newTestModel(t)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				var err error
+				if i%2 == 0 {
+					err = a.LoadPolicy(m)
+				} else {
+					err = a.AddPolicy("p", "p", []string{"bob", "data1", "read"})
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// WithInChunkSize limits the IN lists of LoadFilteredPolicy to n values. A
+// filter with longer lists is loaded with several queries, one for each
+// combination of chunks, whose results are merged.
+func WithInChunkSize(n int) Option {
+	return func(a *Adapter) error {
+		if n < 1 {
+			return errors.Errorf("invalid IN chunk size %d", n)
+		}
+		a.inChunkSize = n
+		return nil
+	}
+}
+
+// inCondition restricts column to one of values.
+type inCondition struct {
+	column string
+	values []string
+}
+
+// chunkConditions calls fn with conds, or with every combination of chunks of
+// conds when WithInChunkSize is set.
+func (a *Adapter) chunkConditions(conds []inCondition, fn func(conds []inCondition) error) error {
+	if a.inChunkSize <= 0 {
+		return fn(conds)
+	}
+	var walk func(i int, chunks []inCondition) error
+	walk = func(i int, chunks []inCondition) error {
+		if i == len(conds) {
+			return fn(chunks)
+		}
+		values := conds[i].values
+		for start := 0; start < len(values); start += a.inChunkSize {
+			end := start + a.inChunkSize
+			if end > len(values) {
+				end = len(values)
+			}
+			chunk := inCondition{column: conds[i].column, values: values[start:end]}
+			if err := walk(i+1, append(chunks[:i:i], chunk)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(0, make([]inCondition, 0, len(conds)))
+}
+
+// uniqueRules drops the rows loaded more than once, which happens when a
+// chunked filter lists the same value twice.
+func uniqueRules(lines []*CasbinRule) []*CasbinRule {
+	seen := make(map[int64]bool, len(lines))
+	unique := lines[:0]
+	for _, line := range lines {
+		if seen[line.Id] {
+			continue
+		}
+		seen[line.Id] = true
+		unique = append(unique, line)
+	}
+	return unique
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInChunkSize(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithInChunkSize(500))
+	values := make([]string, 3000)
+	for i := range values {
+		values[i] = fmt.Sprintf("user%d", i)
+	}
+	// Every chunk loads the rule of its first value.
+	for i := 0; i < 6; i++ {
+		user := fmt.Sprintf("user%d", i*500)
+		f.on("'" + user + "'").returnsRules(&CasbinRule{Id: int64(i + 1), Ptype: "p", V0: user, V1: "data1", V2: "read"})
+	}
+
+	m := newTestModel(t)
+	if err := a.LoadFilteredPolicy(m, Filter{Ptype: []string{"p"}, V0: values}); err != nil {
+		t.Fatal(err)
+	}
+	selects := f.queries("SELECT")
+	if len(selects) != 6 {
+		t.Fatalf("got %d selects, want 6", len(selects))
+	}
+	for _, query := range selects {
+		if n := strings.Count(query, "'user"); n != 500 {
+			t.Errorf("got a select with %d values, want 500", n)
+		}
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 6 || got[5][0] != "user2500" {
+		t.Errorf("got rules %q, want the rules of all chunks", got)
+	}
+
+	// A value listed twice loads its rule once.
+	f.reset()
+	m = newTestModel(t)
+	if err := a.LoadFilteredPolicy(m, Filter{Ptype: []string{"p"}, V0: append(values, "user0")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("SELECT"); n != 7 {
+		t.Errorf("got %d selects, want 7", n)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 6 {
+		t.Errorf("got %d rules, want 6", len(got))
+	}
+}
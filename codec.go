@@ -0,0 +1,147 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// ValueCodec converts the values of a V column between the form Casbin uses
+// and the form stored in the database.
+//
+// Matching rules and filters against the column is done by marshaling the
+// query values, so Marshal must produce the same output for equal values.
+type ValueCodec interface {
+	Marshal(value string) (string, error)
+	Unmarshal(stored string) (string, error)
+}
+
+// WithValueCodec applies codec to the V column at index column (0 for V0) on
+// save and load. When the column is also encrypted, the codec runs on the
+// plaintext.
+func WithValueCodec(column int, codec ValueCodec) Option {
+	return func(a *Adapter) error {
+		if column < 0 || column >= maxFields {
+			return errors.Errorf("codec column index %d out of range", column)
+		}
+		if a.codecs == nil {
+			a.codecs = make(map[int]ValueCodec)
+		}
+		a.codecs[column] = codec
+		return nil
+	}
+}
+
+// JSONCodec stores JSON values in a canonical form, with insignificant
+// whitespace removed and object keys sorted, so that equal documents are
+// stored and matched identically however they were written.
+type JSONCodec struct{}
+
+var _ ValueCodec = JSONCodec{}
+
+func (JSONCodec) Marshal(value string) (string, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader([]byte(value)))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (JSONCodec) Unmarshal(stored string) (string, error) {
+	return stored, nil
+}
+
+// encodeField returns value as it is stored in the given V column.
+func (a *Adapter) encodeField(column int, value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	if codec, ok := a.codecs[column]; ok {
+		v, err := codec.Marshal(value)
+		if err != nil {
+			return "", errors.Wrapf(err, "marshaling v%d", column)
+		}
+		value = v
+	}
+	if a.isEncrypted(column) {
+		v, err := a.encryptor.Encrypt(value)
+		if err != nil {
+			return "", errors.Wrapf(err, "encrypting v%d", column)
+		}
+		value = v
+	}
+	return value, nil
+}
+
+func (a *Adapter) encodeFields(column int, values []string) ([]string, error) {
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		v, err := a.encodeField(column, value)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = v
+	}
+	return encoded, nil
+}
+
+func (a *Adapter) encodeRule(rule *CasbinRule) error {
+	for i, field := range rule.fields() {
+		v, err := a.encodeField(i, *field)
+		if err != nil {
+			return err
+		}
+		*field = v
+	}
+	return nil
+}
+
+// decodeRule reverts encodeRule on a row read from the storage.
+func (a *Adapter) decodeRule(rule *CasbinRule) error {
+	for i, field := range rule.fields() {
+		if *field == "" {
+			continue
+		}
+		if a.isEncrypted(i) {
+			v, err := a.encryptor.Decrypt(*field)
+			if err != nil {
+				return errors.Wrapf(err, "decrypting v%d of rule %d", i, rule.Id)
+			}
+			*field = v
+		}
+		if codec, ok := a.codecs[i]; ok {
+			v, err := codec.Unmarshal(*field)
+			if err != nil {
+				return errors.Wrapf(err, "unmarshaling v%d of rule %d", i, rule.Id)
+			}
+			*field = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestJSONCodecMarshal(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{`{"b": 1, "a": [1, 2]}`, `{"a":[1,2],"b":1}`},
+		{` {"a":{"d":true,"c":null}} `, `{"a":{"c":null,"d":true}}`},
+		{`12345678901234567890`, `12345678901234567890`},
+		{`"x"`, `"x"`},
+	}
+	for _, test := range tests {
+		got, err := JSONCodec{}.Marshal(test.value)
+		if err != nil || got != test.want {
+			t.Errorf("Marshal(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+	if _, err := (JSONCodec{}).Marshal(`{"a":`); err == nil {
+		t.Error("Marshal of invalid JSON succeeded")
+	}
+}
+
+func TestValueCodecMatching(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithValueCodec(2, JSONCodec{}))
+	stored := `{"ip":"10.0.0.1","method":"GET"}`
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", `{"method": "GET", "ip": "10.0.0.1"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.queries("INSERT"); len(got) != 1 || !strings.Contains(got[0], "'"+stored+"'") {
+		t.Errorf("got inserts %q, want V2 stored as %s", got, stored)
+	}
+
+	f.on(`("v2" = '` + stored + `')`).returnsRules(&CasbinRule{Id: 3, Ptype: "p", V0: "alice", V1: "data1", V2: stored})
+	line, err := a.GetPolicy(context.Background(), "p", []string{"alice", "data1", `{ "ip": "10.0.0.1", "method": "GET" }`})
+	if err != nil {
+		t.Fatalf("GetPolicy of an equal document: %v", err)
+	}
+	if line.V2 != stored {
+		t.Errorf("got V2 %q, want %q", line.V2, stored)
+	}
+
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", `{"method":"GET","ip":"10.0.0.1"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.queries("DELETE"); len(got) != 1 || !strings.Contains(got[0], `("v2" = '`+stored+`')`) {
+		t.Errorf("got deletes %q, want V2 matched as %s", got, stored)
+	}
+
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "not json"}); err == nil || !strings.Contains(err.Error(), "marshaling v2") {
+		t.Errorf("got error %v, want a marshaling error", err)
+	}
+}
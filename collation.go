@@ -0,0 +1,44 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+// WithComparisonCollation compares the V columns with the collation c in
+// removes, updates and filters, e.g. utf8mb4_bin on MySQL, whose default
+// collations are case and accent insensitive and would match Admin against
+// admin. The name is used as written, so it must be one the database knows
+// unquoted.
+func WithComparisonCollation(c string) Option {
+	return func(a *Adapter) error {
+		if err := validateIdentifier(c); err != nil {
+			return err
+		}
+		a.collation = c
+		return nil
+	}
+}
+
+// collateSQL returns the COLLATE clause following the V columns in
+// comparisons, if any.
+func (a *Adapter) collateSQL() string {
+	if a.collation == "" {
+		return ""
+	}
+	return " COLLATE " + a.collation
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+)
+
+// onCaseInsensitiveTable scripts f like a MySQL table with the default
+// utf8mb4_0900_ai_ci collation holding the roles of Admin and admin: a
+// comparison on v0 matches both unless made with a binary collation.
+func onCaseInsensitiveTable(f *fakeDB) {
+	f.on("COLLATE utf8mb4_bin").
+		returnsRules(&CasbinRule{Id: 2, Ptype: "g", V0: "admin", V1: "root"}).
+		affects(1)
+	f.on("casbin_rule").
+		returnsRules(
+			&CasbinRule{Id: 1, Ptype: "g", V0: "Admin", V1: "root"},
+			&CasbinRule{Id: 2, Ptype: "g", V0: "admin", V1: "root"}).
+		affects(2)
+}
+
+func TestComparisonCollation(t *testing.T) {
+	for _, test := range []struct {
+		options []Option
+		want    int64
+	}{
+		{nil, 2},
+		{[]Option{WithComparisonCollation("utf8mb4_bin")}, 1},
+	} {
+		a, f := newTestAdapter(t, "mysql", test.options...)
+		onCaseInsensitiveTable(f)
+		n, err := a.LoadFilteredPolicyN(newTestModel(t), Filter{Ptype: []string{"g"}, V0: []string{"admin"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != test.want {
+			t.Errorf("got %d rows for admin, want %d", n, test.want)
+		}
+	}
+}
+
+func TestComparisonCollationSQL(t *testing.T) {
+	for _, options := range [][]Option{nil, {WithPreparedStatements()}} {
+		a, f := newTestAdapter(t, "mysql", append(options, WithComparisonCollation("utf8mb4_bin"))...)
+		if err := a.LoadFilteredPolicy(newTestModel(t), Filter{V0: []string{"admin"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.RemovePolicy("g", "g", []string{"admin", "root"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.RemoveFilteredPolicy("g", "g", 1, "root"); err != nil {
+			t.Fatal(err)
+		}
+		statements := strings.Join(f.queries(""), "\n")
+		for _, want := range []string{"v0 COLLATE utf8mb4_bin in ('admin')", "`v1` COLLATE utf8mb4_bin = "} {
+			if !strings.Contains(statements, want) {
+				t.Errorf("got statements %q, want %q", statements, want)
+			}
+		}
+		if strings.Contains(statements, "`ptype` COLLATE") {
+			t.Errorf("got statements %q, want ptype compared as is", statements)
+		}
+	}
+}
+
+func TestComparisonCollationValidation(t *testing.T) {
+	_, db := newFakeDB(t, "mysql")
+	if _, err := NewAdapterWithClient(db, WithComparisonCollation("utf8mb4_bin; DROP TABLE casbin_rule")); err == nil {
+		t.Error("got no error for an invalid collation")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// WithColumnCount makes the adapter use only the columns v0 to v(n-1), for
+// tables created with fewer than the 8 V columns of CasbinRule. Rules with
+// more fields are rejected.
+func WithColumnCount(n int) Option {
+	return func(a *Adapter) error {
+		if n < 1 || n > maxFields {
+			return errors.Errorf("column count %d out of range", n)
+		}
+		a.columnCount = n
+		return nil
+	}
+}
+
+// WithPtypeColumn sets the name of the ptype column, for tables that call it
+// e.g. p_type or policy_type. CreateTable renames the column of the tables it
+// creates.
+func WithPtypeColumn(name string) Option {
+	return func(a *Adapter) error {
+		if err := validateIdentifier(name); err != nil {
+			return err
+		}
+		a.ptypeColumn = name
+		return nil
+	}
+}
+
+// ptypeColumnName returns the name of the ptype column of the table.
+func (a *Adapter) ptypeColumnName() string {
+	if a.ptypeColumn == "" {
+		return "ptype"
+	}
+	return a.ptypeColumn
+}
+
+// ptypeRenamed reports whether the ptype column differs from the one of
+// CasbinRule, which bun cannot map from the model.
+func (a *Adapter) ptypeRenamed() bool {
+	return a.ptypeColumnName() != "ptype"
+}
+
+// WithIDColumn sets the name of the id column, for tables whose primary key
+// is called e.g. rule_id or pk. CreateTable renames the column of the tables
+// it creates.
+func WithIDColumn(name string) Option {
+	return func(a *Adapter) error {
+		if err := validateIdentifier(name); err != nil {
+			return err
+		}
+		a.idColumn = name
+		return nil
+	}
+}
+
+// idColumnName returns the name of the id column of the table.
+func (a *Adapter) idColumnName() string {
+	if a.idColumn == "" {
+		return "id"
+	}
+	return a.idColumn
+}
+
+// idRenamed reports whether the id column differs from the one of
+// CasbinRule.
+func (a *Adapter) idRenamed() bool {
+	return a.idColumnName() != "id"
+}
+
+// fieldCount returns the number of V columns of the table.
+func (a *Adapter) fieldCount() int {
+	if a.columnCount == 0 {
+		return maxFields
+	}
+	return a.columnCount
+}
+
+// policyColumns returns the ptype and V columns of the table.
+func (a *Adapter) policyColumns() []string {
+	columns := []string{a.ptypeColumnName()}
+	for i := 0; i < a.fieldCount(); i++ {
+		columns = append(columns, fmt.Sprintf("v%d", i))
+	}
+	return columns
+}
+
+// identList returns columns quoted for the dialect and separated by commas,
+// for statements not formatted by bun.
+func (a *Adapter) identList(columns []string) string {
+	var b []byte
+	for i, column := range columns {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = a.client.Formatter().AppendIdent(b, column)
+	}
+	return string(b)
+}
+
+// insertColumns returns the columns written by inserts.
+func (a *Adapter) insertColumns() []string {
+	if a.spannerDialect != nil {
+		// Spanner has no auto-increment, ids are generated by the adapter.
+		return append([]string{a.idColumnName()}, a.policyColumns()...)
+	}
+	return a.policyColumns()
+}
+
+// policyValues returns the values of line for policyColumns.
+func (a *Adapter) policyValues(line *CasbinRule) []interface{} {
+	values := []interface{}{line.Ptype}
+	for _, field := range line.fields()[:a.fieldCount()] {
+		values = append(values, *field)
+	}
+	return values
+}
+
+// checkFieldIndex reports an error if a non-empty value targets the V column
+// at index column and the table does not have it.
+func (a *Adapter) checkFieldIndex(column int, value string) error {
+	if column >= a.fieldCount() && value != "" {
+		return errors.Errorf("v%d is beyond the %d V columns of the table", column, a.fieldCount())
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestColumnCount(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithColumnCount(6))
+	f.on("INSERT").returns([]string{"id"}, []driver.Value{int64(1)})
+	f.on("SELECT").returns([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"},
+		[]driver.Value{int64(1), "p", "alice", "data1", "read", "", "", ""})
+	f.on("DELETE").affects(1)
+
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 || strings.Join(got[0], ",") != "alice,data1,read" {
+		t.Errorf("got rules %q, want the added rule", got)
+	}
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []string{"INSERT", "SELECT", "DELETE"} {
+		if f.count(kind) == 0 {
+			t.Errorf("got no %s", kind)
+		}
+	}
+	for _, query := range f.queries("") {
+		if strings.Contains(query, "v6") || strings.Contains(query, "v7") {
+			t.Errorf("got query %q on columns the table does not have", query)
+		}
+	}
+
+	// Rules with more fields than the table has columns are rejected.
+	f.reset()
+	if err := a.AddPolicy("p", "p", []string{"a", "b", "c", "d", "e", "f", "g"}); err == nil {
+		t.Error("got no error adding a rule with 7 fields")
+	}
+	if err := a.RemoveFilteredPolicy("p", "p", 6, "g"); err == nil {
+		t.Error("got no error filtering on v6")
+	}
+	if n := f.count("casbin_rule"); n != 0 {
+		t.Errorf("got %d queries on the table for rejected rules, want 0", n)
+	}
+}
+
+func TestColumnCountRange(t *testing.T) {
+	for _, n := range []int{0, 9} {
+		_, db := newFakeDB(t, "pg")
+		if _, err := NewAdapterWithClient(db, WithColumnCount(n)); err == nil {
+			t.Errorf("got no error for column count %d", n)
+		}
+	}
+}
+
+func TestPtypeColumn(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithPtypeColumn("p_type"))
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 || strings.Join(got[0], ",") != "alice,data1,read" {
+		t.Errorf("got rules %q, want the stored rule", got)
+	}
+	if err := a.LoadFilteredPolicy(newTestModel(t), Filter{Ptype: []string{"p"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"p_type") VALUES`, `"p_type" AS ptype`, `(p_type in ('p'))`, `("p_type" = 'p')`} {
+		if n := f.count(want); n == 0 {
+			t.Errorf("got statements %q, want %q", f.queries(""), want)
+		}
+	}
+	for _, query := range f.queries("") {
+		if strings.Contains(strings.ReplaceAll(query, "AS ptype", ""), "ptype") {
+			t.Errorf("got statement %q on the ptype column", query)
+		}
+	}
+}
+
+func TestLoadSelectsPolicyColumns(t *testing.T) {
+	for _, test := range []struct {
+		options []Option
+		want    string
+	}{
+		{want: `"casbin_rule"."id", "casbin_rule"."ptype", "casbin_rule"."v0", "casbin_rule"."v1", "casbin_rule"."v2", "casbin_rule"."v3", "casbin_rule"."v4", "casbin_rule"."v5", "casbin_rule"."v6", "casbin_rule"."v7"`},
+		{options: []Option{WithColumnCount(3)}, want: `"casbin_rule"."id", "casbin_rule"."ptype", "casbin_rule"."v0", "casbin_rule"."v1", "casbin_rule"."v2"`},
+	} {
+		// The table would also have a large notes column, which a SELECT *
+		// fetches.
+		_, db := newFakeDB(t, "pg")
+		recorder := new(selectRecorder)
+		db.AddQueryHook(recorder)
+		a, err := NewAdapterWithClient(db, test.options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.LoadPolicy(newTestModel(t)); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.LoadFilteredPolicy(newTestModel(t), Filter{Ptype: []string{"p"}}); err != nil {
+			t.Fatal(err)
+		}
+		if len(recorder.queries) != 2 {
+			t.Fatalf("got selects %q, want 2", recorder.queries)
+		}
+		for _, query := range recorder.queries {
+			list := strings.TrimPrefix(query[:strings.Index(query, " FROM ")], "SELECT ")
+			if list != test.want {
+				t.Errorf("got select list %s, want %s", list, test.want)
+			}
+		}
+	}
+}
+
+func TestIDColumn(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithIDColumn("rule_id"))
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 7, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+	f.on("RETURNING").returns([]string{"id"}, []driver.Value{int64(8)})
+
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 || strings.Join(got[0], ",") != "alice,data1,read" {
+		t.Errorf("got rules %q, want the stored rule", got)
+	}
+	old, err := a.UpdateFilteredPolicies("p", "p", [][]string{{"alice", "data2", "read"}}, 0, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 1 || strings.Join(old[0], ",") != "alice,data1,read" {
+		t.Errorf("got old rules %q, want the stored rule", old)
+	}
+	id, err := a.AddPolicyReturningID(context.Background(), "p", []string{"bob", "data2", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 8 {
+		t.Errorf("got id %d, want 8", id)
+	}
+
+	for _, want := range []string{`SELECT "rule_id" AS id`, `ORDER BY "rule_id" ASC`, `WHERE ("rule_id" = 7)`, `RETURNING "rule_id" AS id`} {
+		if n := f.count(want); n == 0 {
+			t.Errorf("got statements %q, want %q", f.queries(""), want)
+		}
+	}
+	for _, query := range f.queries("") {
+		if strings.Contains(strings.ReplaceAll(query, "AS id", ""), `"id"`) {
+			t.Errorf("got statement %q on the id column", query)
+		}
+	}
+}
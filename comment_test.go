@@ -0,0 +1,97 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryComments(t *testing.T) {
+	rule := []string{"alice", "data1", "read"}
+	tests := []struct {
+		op        string
+		statement string
+		run       func(a *Adapter) error
+	}{
+		{"LoadPolicy", "SELECT", func(a *Adapter) error {
+			return a.LoadPolicy(newTestModel(t))
+		}},
+		{"AddPolicy", "INSERT", func(a *Adapter) error {
+			return a.AddPolicy("p", "p", rule)
+		}},
+		{"SavePolicy", "TRUNCATE", func(a *Adapter) error {
+			return a.SavePolicy(newTestModel(t, append([]string{"p"}, rule...)))
+		}},
+		{"RemoveFilteredPolicy", "DELETE", func(a *Adapter) error {
+			return a.RemoveFilteredPolicy("p", "p", 0, "alice")
+		}},
+		{"UpdatePolicy", "UPDATE", func(a *Adapter) error {
+			return a.UpdatePolicy("p", "p", rule, []string{"alice", "data2", "read"})
+		}},
+	}
+	for _, tt := range tests {
+		a, f := newTestAdapter(t, "pg", WithQueryComments())
+		if err := tt.run(a); err != nil {
+			t.Fatalf("%s: %v", tt.op, err)
+		}
+		want := " /* casbin:" + tt.op + " */"
+		queries := f.queries(tt.statement)
+		if len(queries) == 0 {
+			t.Errorf("%s: got no %s", tt.op, tt.statement)
+		}
+		for _, query := range queries {
+			if !strings.HasSuffix(query, want) {
+				t.Errorf("%s: got %q, want it to end with %q", tt.op, query, want)
+			}
+		}
+	}
+}
+
+func TestQueryCommentsOff(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("/*"); n != 0 {
+		t.Errorf("got %q, want no comments", f.queries(""))
+	}
+}
+
+func TestQueryCommentsAfterHooks(t *testing.T) {
+	f, db := newFakeDB(t, "pg")
+	recorder := new(selectRecorder)
+	db.AddQueryHook(recorder)
+	a, err := NewAdapterWithClient(db, WithQueryComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	// The hook sees the statement as built by bun, the driver the
+	// commented one.
+	if recorder.count() != 1 || strings.Contains(recorder.queries[0], "/*") {
+		t.Errorf("hook got %q, want the select without the comment", recorder.queries)
+	}
+	if n := f.count("/* casbin:LoadPolicy */"); n != 1 {
+		t.Errorf("driver got %q, want the commented select", f.queries(""))
+	}
+}
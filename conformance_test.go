@@ -0,0 +1,50 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter_test
+
+import (
+	"testing"
+
+	"github.com/casbin/casbin/v2/persist"
+
+	casbinbunadapter "github.com/cuipeiyu/casbin-bun-adapter"
+)
+
+// The adapter must keep satisfying the persist interfaces Casbin detects at
+// run time, as seen from outside the package.
+var (
+	_ persist.Adapter          = (*casbinbunadapter.Adapter)(nil)
+	_ persist.FilteredAdapter  = (*casbinbunadapter.Adapter)(nil)
+	_ persist.BatchAdapter     = (*casbinbunadapter.Adapter)(nil)
+	_ persist.UpdatableAdapter = (*casbinbunadapter.Adapter)(nil)
+)
+
+func TestAdapterInterfaces(t *testing.T) {
+	var a interface{} = (*casbinbunadapter.Adapter)(nil)
+	if _, ok := a.(persist.FilteredAdapter); !ok {
+		t.Error("Adapter does not implement persist.FilteredAdapter")
+	}
+	if _, ok := a.(persist.BatchAdapter); !ok {
+		t.Error("Adapter does not implement persist.BatchAdapter")
+	}
+	if _, ok := a.(persist.UpdatableAdapter); !ok {
+		t.Error("Adapter does not implement persist.UpdatableAdapter")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+)
+
+// queryConn returns the connection the queries of ctx are run on: db, with
+// the comment of the operation of ctx appended if WithQueryComments is set,
+// its writes run through the interceptor of WithExecInterceptor and counted
+// for the OpLogger.
+func (a *Adapter) queryConn(ctx context.Context, db bun.IConn) bun.IConn {
+	var comment string
+	if op, ok := ctx.Value(opNameKey{}).(string); ok && a.queryComments {
+		comment = " /* casbin:" + op + " */"
+	}
+	if comment == "" && a.execInterceptor == nil && a.opLogger == nil {
+		return db
+	}
+	// Unwrap bun's connections like bun does, so that query hooks are not
+	// run twice.
+	switch c := db.(type) {
+	case *bun.DB:
+		db = c.DB
+	case bun.Tx:
+		db = c.Tx
+	case bun.Conn:
+		db = c.Conn
+	}
+	conn := &adapterConn{conn: db, comment: comment, intercept: a.execInterceptor}
+	if a.opLogger != nil {
+		conn.counter = a
+	}
+	return conn
+}
+
+// adapterConn appends comment to the statements run on conn, runs the writes
+// through intercept and counts their rows for the operation of counter.
+type adapterConn struct {
+	conn      bun.IConn
+	comment   string
+	intercept ExecInterceptor
+	counter   *Adapter
+}
+
+var _ bun.IConn = (*adapterConn)(nil)
+
+func (c *adapterConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query += c.comment
+	if c.intercept == nil || !isWriteStatement(query) {
+		return c.conn.QueryContext(ctx, query, args...)
+	}
+	// Writes with a RETURNING clause are run as queries; the interceptor
+	// gets a nil Result for them.
+	var rows *sql.Rows
+	_, err := c.intercept(func() (sql.Result, error) {
+		var err error
+		rows, err = c.conn.QueryContext(ctx, query, args...)
+		return nil, err
+	})
+	if err != nil {
+		if rows != nil {
+			rows.Close()
+		}
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (c *adapterConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query += c.comment
+	if !isWriteStatement(query) {
+		return c.conn.ExecContext(ctx, query, args...)
+	}
+	var res sql.Result
+	var err error
+	if c.intercept == nil {
+		res, err = c.conn.ExecContext(ctx, query, args...)
+	} else {
+		res, err = c.intercept(func() (sql.Result, error) {
+			return c.conn.ExecContext(ctx, query, args...)
+		})
+	}
+	if err == nil && c.counter != nil {
+		c.counter.countExec(ctx, res)
+	}
+	return res, err
+}
+
+func (c *adapterConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.conn.QueryRowContext(ctx, query+c.comment, args...)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConnInitWithClient is returned when WithConnInit is passed to
+// NewAdapterWithClient: the adapter can only hook into connections it opens.
+var ErrConnInitWithClient = errors.New("casbinbunadapter: WithConnInit requires NewAdapter")
+
+// WithConnInit runs fn once on every new physical connection, before it is
+// handed to the pool, e.g. to SET statement_timeout, search_path or sql_mode.
+// Most drivers implement driver.ExecerContext on conn for running the
+// statements. Only supported by NewAdapter.
+func WithConnInit(fn func(ctx context.Context, conn driver.Conn) error) Option {
+	return func(a *Adapter) error {
+		a.connInit = fn
+		return nil
+	}
+}
+
+// openDB opens the database like sql.Open, running init on each new
+// connection if set.
+func openDB(driverName, dataSourceName string, init func(ctx context.Context, conn driver.Conn) error) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil || init == nil {
+		return db, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+	var connector driver.Connector
+	if dc, ok := drv.(driver.DriverContext); ok {
+		connector, err = dc.OpenConnector(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		connector = &dsnConnector{driver: drv, dsn: dataSourceName}
+	}
+	return sql.OpenDB(&initConnector{Connector: connector, init: init}), nil
+}
+
+type initConnector struct {
+	driver.Connector
+	init func(ctx context.Context, conn driver.Conn) error
+}
+
+func (c *initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.init(ctx, conn); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "initializing connection")
+	}
+	return conn, nil
+}
+
+// dsnConnector adapts drivers that don't implement driver.DriverContext.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
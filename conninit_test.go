@@ -0,0 +1,93 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestConnInitRunsOncePerConnection(t *testing.T) {
+	f, _ := newFakeDB(t, "pg")
+	inits := 0
+	db, err := openDB(fakeDriverName, f.dsn, func(ctx context.Context, conn driver.Conn) error {
+		inits++
+		_, err := conn.(driver.ExecerContext).ExecContext(ctx, "SET statement_timeout = 5000", nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := db.PingContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inits != 1 {
+		t.Errorf("ran the init %d times on one connection, want 1", inits)
+	}
+
+	// Holding the first connection makes the pool open a second one.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if inits != 2 {
+		t.Errorf("ran the init %d times on two connections, want 2", inits)
+	}
+	if n := f.count("SET statement_timeout = 5000"); n != 2 {
+		t.Errorf("ran the init SQL %d times, want 2", n)
+	}
+}
+
+func TestConnInitError(t *testing.T) {
+	f, _ := newFakeDB(t, "pg")
+	db, err := openDB(fakeDriverName, f.dsn, func(ctx context.Context, conn driver.Conn) error {
+		return errors.New("permission denied")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err == nil || !strings.Contains(err.Error(), "initializing connection: permission denied") {
+		t.Errorf("got error %v, want the init error", err)
+	}
+}
+
+func TestConnInitWithClient(t *testing.T) {
+	_, db := newFakeDB(t, "pg")
+	_, err := NewAdapterWithClient(db, WithConnInit(func(ctx context.Context, conn driver.Conn) error {
+		return nil
+	}))
+	if !errors.Is(err, ErrConnInitWithClient) {
+		t.Errorf("got error %v, want ErrConnInitWithClient", err)
+	}
+}
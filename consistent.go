@@ -0,0 +1,125 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithConsistentLoad runs the policy loads in a transaction that reads from a
+// single snapshot, so writes committed while a load is in progress never show
+// up half applied in the model. It uses REPEATABLE READ, or SNAPSHOT on SQL
+// Server, which requires ALLOW_SNAPSHOT_ISOLATION on the database.
+func WithConsistentLoad() Option {
+	return func(a *Adapter) error {
+		a.consistentLoad = true
+		return nil
+	}
+}
+
+// withLoadDB runs fn with db, the client of readClient, or with a snapshot
+// of it for WithConsistentLoad.
+func (a *Adapter) withLoadDB(ctx context.Context, db *bun.DB, fn func(db bun.IDB) error) error {
+	if !a.consistentLoad {
+		return fn(db)
+	}
+	return a.withSnapshot(ctx, db, fn)
+}
+
+// withSnapshot runs fn in a read transaction on a single snapshot of db.
+func (a *Adapter) withSnapshot(ctx context.Context, db *bun.DB, fn func(db bun.IDB) error) error {
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	if db.Dialect().Name() == dialect.MSSQL {
+		// go-mssqldb rejects read-only transactions.
+		opts = &sql.TxOptions{Isolation: sql.LevelSnapshot}
+	}
+	return a.withTxOptions(ctx, db, opts, func(tx bun.Tx) error {
+		return fn(tx)
+	})
+}
+
+// LoadPolicyMixed loads all the policy rules of the ptypes in full and the
+// rules matching any of filtered, reading both from a single snapshot like
+// WithConsistentLoad, so that writes made in between never show up in one
+// part only. The adapter is marked as filtered.
+func (a *Adapter) LoadPolicyMixed(model model.Model, full []string, filtered []Filter) error {
+	return a.LoadPolicyMixedCtx(a.ctx, model, full, filtered)
+}
+
+// LoadPolicyMixedCtx loads the rules of LoadPolicyMixed with context.
+func (a *Adapter) LoadPolicyMixedCtx(ctx context.Context, model model.Model, full []string, filtered []Filter) (err error) {
+	ctx, endOp := a.startOp(ctx, "LoadPolicyMixed")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	defer checkTableNotFound(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+
+	filters := filtered
+	if len(full) > 0 {
+		filters = append([]Filter{{Ptype: full}}, filtered...)
+	}
+	type filterConds struct {
+		conds []inCondition
+		empty []bun.Ident
+	}
+	all := make([]filterConds, 0, len(filters))
+	for _, filter := range filters {
+		conds, empty, err := a.filterConditions(filter)
+		if err != nil {
+			return err
+		}
+		all = append(all, filterConds{conds: conds, empty: empty})
+	}
+	tables, err := a.getReadTableNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lines []*CasbinRule
+	err = a.withSnapshot(ctx, a.readClient(ctx), func(db bun.IDB) error {
+		for _, table := range tables {
+			var tableLines []*CasbinRule
+			for _, f := range all {
+				matched, err := a.selectFiltered(ctx, db, table, f.conds, f.empty)
+				if err != nil {
+					return err
+				}
+				tableLines = append(tableLines, matched...)
+			}
+			// A row matching several filters is loaded once.
+			lines = append(lines, uniqueRules(tableLines)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.loadRules(ctx, lines, model); err != nil {
+		return err
+	}
+	a.filtered = true
+	return nil
+}
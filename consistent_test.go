@@ -0,0 +1,117 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsistentLoad(t *testing.T) {
+	tests := []struct {
+		dialect string
+		begin   string
+	}{
+		{"pg", "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"},
+		{"mysql", "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"},
+		{"mssql", "BEGIN ISOLATION LEVEL SNAPSHOT"},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, test.dialect, WithConsistentLoad(), WithPtypeTable("g", "", "casbin_role"))
+		if err := a.LoadPolicy(newTestModel(t)); err != nil {
+			t.Fatal(err)
+		}
+		// Both tables are read in the same snapshot.
+		var got []string
+		for _, q := range f.queries("") {
+			if strings.HasPrefix(q, "SELECT") {
+				q = "SELECT"
+			}
+			got = append(got, q)
+		}
+		want := []string{test.begin, "SELECT", "SELECT", "COMMIT"}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: got statements %q, want %q", test.dialect, got, want)
+		}
+	}
+}
+
+func TestLoadWithoutConsistentLoad(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithPtypeTable("g", "", "casbin_role"))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("BEGIN"); n != 0 {
+		t.Errorf("got %d transactions, want none", n)
+	}
+}
+
+func TestLoadPolicyMixed(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("ptype in ('p')").takes(50 * time.Millisecond).
+		returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+	f.on("ptype in ('g')").
+		returnsRules(&CasbinRule{Id: 2, Ptype: "g", V0: "alice", V1: "admin"})
+	m := newTestModel(t)
+	done := make(chan error, 1)
+	go func() {
+		done <- a.LoadPolicyMixed(m, []string{"p"}, []Filter{{Ptype: []string{"g"}, V0: []string{"alice"}}})
+	}()
+
+	// Write while the load is between its two selects.
+	for f.count("ptype in ('p')") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := a.AddPolicy("g", "g", []string{"alice", "auditor"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+		t.Fatal("the load ended before the concurrent write")
+	default:
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Both selects ran in the read-only snapshot, apart from the write.
+	load := f.connOf("READ ONLY")
+	var got []string
+	for _, q := range f.connQueries(load) {
+		if strings.HasPrefix(q, "SELECT") {
+			q = "SELECT"
+		}
+		got = append(got, q)
+	}
+	want := []string{"BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY", "SELECT", "SELECT", "COMMIT"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got statements %q on the loading connection, want %q", got, want)
+	}
+	if f.connOf("INSERT") == load {
+		t.Error("the write ran on the loading connection")
+	}
+	if n := f.count("v0 in ('alice')"); n != 1 {
+		t.Errorf("got selects %q, want one filtered on alice", f.queries("SELECT"))
+	}
+	if p, g := m.GetPolicy("p", "p"), m.GetPolicy("g", "g"); len(p) != 1 || len(g) != 1 {
+		t.Errorf("got policies %q and roles %q, want one of each", p, g)
+	}
+}
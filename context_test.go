@@ -0,0 +1,121 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// contextMethods returns calls of the context-aware methods of Adapter by
+// name.
+func contextMethods(t *testing.T) map[string]func(a *Adapter, ctx context.Context) error {
+	rule := []string{"alice", "data1", "read"}
+	loaded := newTestModel(t)
+	saved := newTestModel(t, append([]string{"p"}, rule...))
+	return map[string]func(a *Adapter, ctx context.Context) error{
+		"LoadPolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.LoadPolicyCtx(ctx, loaded)
+		},
+		"LoadFilteredPolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.LoadFilteredPolicyCtx(ctx, loaded, Filter{Ptype: []string{"p"}})
+		},
+		"LoadPolicyForCtx": func(a *Adapter, ctx context.Context) error {
+			return a.LoadPolicyForCtx(ctx, loaded, []string{"g"})
+		},
+		"SavePolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.SavePolicyCtx(ctx, saved)
+		},
+		"AddPolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.AddPolicyCtx(ctx, "p", "p", rule)
+		},
+		"AddPoliciesCtx": func(a *Adapter, ctx context.Context) error {
+			return a.AddPoliciesCtx(ctx, "p", "p", [][]string{rule})
+		},
+		"RemovePolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.RemovePolicyCtx(ctx, "p", "p", rule)
+		},
+		"RemovePoliciesCtx": func(a *Adapter, ctx context.Context) error {
+			return a.RemovePoliciesCtx(ctx, "p", "p", [][]string{rule})
+		},
+		"RemoveFilteredPolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.RemoveFilteredPolicyCtx(ctx, "p", "p", 0, "alice")
+		},
+		"UpdatePolicyCtx": func(a *Adapter, ctx context.Context) error {
+			return a.UpdatePolicyCtx(ctx, "p", "p", rule, []string{"bob", "data1", "read"})
+		},
+		"UpdatePoliciesCtx": func(a *Adapter, ctx context.Context) error {
+			return a.UpdatePoliciesCtx(ctx, "p", "p", [][]string{rule}, [][]string{{"bob", "data1", "read"}})
+		},
+		"UpdateFilteredPoliciesCtx": func(a *Adapter, ctx context.Context) error {
+			_, err := a.UpdateFilteredPoliciesCtx(ctx, "p", "p", [][]string{{"bob", "data1", "read"}}, 0, "alice")
+			return err
+		},
+	}
+}
+
+func TestContextMethodsUseContext(t *testing.T) {
+	for name, method := range contextMethods(t) {
+		t.Run(name, func(t *testing.T) {
+			a, f := newTestAdapter(t, "pg")
+			if err := method(a, context.Background()); err != nil {
+				t.Fatalf("with a live context: %v", err)
+			}
+			f.reset()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := method(a, ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("with a canceled context: got error %v, want context.Canceled", err)
+			}
+			if queries := f.queries(""); len(queries) != 0 {
+				t.Errorf("with a canceled context: ran %q, want nothing", queries)
+			}
+		})
+	}
+}
+
+func TestContextMethodsResolveTableFromContext(t *testing.T) {
+	for name, method := range contextMethods(t) {
+		t.Run(name, func(t *testing.T) {
+			var tenants []interface{}
+			a, f := newTestAdapter(t, "pg", WithTableNameFunc(func(ctx context.Context) (string, string, error) {
+				tenants = append(tenants, ctx.Value(tenantKey{}))
+				return "public", "casbin_rule_acme", nil
+			}))
+			if err := method(a, context.WithValue(context.Background(), tenantKey{}, "acme")); err != nil {
+				t.Fatal(err)
+			}
+			if len(tenants) == 0 {
+				t.Fatal("the table func is not called")
+			}
+			for _, tenant := range tenants {
+				if tenant != "acme" {
+					t.Errorf("the table func got tenant %v, want the one of the call", tenant)
+				}
+			}
+			if n := f.count("public.casbin_rule_acme"); n == 0 {
+				t.Errorf("got statements %q, want them on the table of the tenant", f.queries(""))
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+
+	"github.com/uptrace/bun"
+
+	"github.com/pkg/errors"
+)
+
+// ExportCSV writes all policy rules to w in Casbin's CSV policy format,
+// one `ptype, v0, v1, ...` line per row, with trailing empty fields trimmed.
+func (a *Adapter) ExportCSV(ctx context.Context, w io.Writer) (err error) {
+	ctx, endOp := a.startOp(ctx, "ExportCSV")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	for _, table := range tables {
+		if err := a.exportTable(ctx, cw, table); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (a *Adapter) exportTable(ctx context.Context, cw *csv.Writer, table string) error {
+	rows, err := a.newSelect(ctx, a.client, (*CasbinRule)(nil), table).OrderExpr("? ASC", bun.Ident(a.idColumnName())).Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := new(CasbinRule)
+		if err := a.client.ScanRow(ctx, rows, rule); err != nil {
+			return err
+		}
+		if err := a.decodeRule(rule); err != nil {
+			return err
+		}
+		if err := cw.Write(append([]string{rule.Ptype}, CasbinRuleToStringArray(rule)...)); err != nil {
+			return err
+		}
+		a.addOpRows(ctx, 1)
+	}
+	return rows.Err()
+}
+
+// ImportCSV reads policy rules in Casbin's CSV policy format from r and
+// bulk-inserts them into the storage. When replace is true the table is
+// truncated first; both steps run in one transaction, so a malformed input
+// leaves the stored rules untouched.
+func (a *Adapter) ImportCSV(ctx context.Context, r io.Reader, replace bool) (err error) {
+	ctx, endOp := a.startOp(ctx, "ImportCSV")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	lines := make([]*CasbinRule, 0)
+	// records keeps the rules as read for the outbox, as lines may be
+	// encoded for storage.
+	var records [][]string
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 2 || len(record) > maxFields+1 {
+			line, _ := cr.FieldPos(0)
+			return errors.Errorf("line %d: expected ptype and 1 to %d values, got %d fields", line, maxFields, len(record))
+		}
+		rule, err := a.savePolicyLine(record[0], record[1:])
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return errors.Wrapf(err, "line %d", line)
+		}
+		lines = append(lines, rule)
+		if a.outboxTable != "" {
+			records = append(records, record)
+		}
+	}
+
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		if replace {
+			for _, table := range tables {
+				if err := a.truncateTable(ctx, tx, table); err != nil {
+					return err
+				}
+			}
+			if err := a.publish(ctx, tx, "SavePolicy", "", nil); err != nil {
+				return err
+			}
+		}
+		if _, err := a.insertByTable(ctx, tx, lines); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := a.publish(ctx, tx, "AddPolicy", record[0], record[1:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
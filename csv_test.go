@@ -0,0 +1,113 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportImportCSVRoundTrip(t *testing.T) {
+	src, f := newTestAdapter(t, "pg")
+	f.on("SELECT").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "p", V0: "bob", V1: "data, 2", V2: "write"},
+		&CasbinRule{Id: 3, Ptype: "g", V0: "alice", V1: "admin"},
+	)
+	var buf bytes.Buffer
+	if err := src.ExportCSV(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "p,alice,data1,read\np,bob,\"data, 2\",write\ng,alice,admin\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("exported %q, want %q", got, want)
+	}
+
+	dst, f := newTestAdapter(t, "pg")
+	if err := dst.ImportCSV(context.Background(), &buf, true); err != nil {
+		t.Fatal(err)
+	}
+	queries := f.queries("")
+	if len(queries) != 4 || queries[1] != "TRUNCATE TABLE public.casbin_rule RESTART IDENTITY" {
+		t.Fatalf("got statements %q, want a truncate and an insert in a transaction", queries)
+	}
+	insert := queries[2]
+	if n := strings.Count(insert, "(DEFAULT, "); n != 3 {
+		t.Errorf("imported %d rows, want 3: %s", n, insert)
+	}
+	for _, value := range []string{"'alice'", "'data, 2'", "'admin'"} {
+		if !strings.Contains(insert, value) {
+			t.Errorf("import does not insert %s: %s", value, insert)
+		}
+	}
+}
+
+func TestImportCSVReportsLineOfInvalidRule(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithColumnCount(3))
+	input := "p, alice, data1, read\np, bob, data2, write, extra\n"
+	err := a.ImportCSV(context.Background(), strings.NewReader(input), true)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+		t.Fatalf("got error %v, want one for line 2", err)
+	}
+	if n := f.count("BEGIN"); n != 0 {
+		t.Errorf("got %d transactions, want none", n)
+	}
+}
+
+func TestImportCSVMalformedKeepsRows(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"p, alice, data1, read\ng\n", "line 2: "},
+		{"p, alice, data1, read\np, bob, data2, write\np, 1, 2, 3, 4, 5, 6, 7, 8, 9\n", "line 3: "},
+		{"p, alice, data1, read\np, \"bob, data2, write\n", "line 2"},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, "pg")
+		err := a.ImportCSV(context.Background(), strings.NewReader(test.input), true)
+		if err == nil || !strings.Contains(err.Error(), test.line) {
+			t.Errorf("importing %q: got error %v, want one for %s", test.input, err, strings.TrimSuffix(test.line, ": "))
+		}
+		if queries := f.queries(""); len(queries) != 0 {
+			t.Errorf("importing %q ran %q, want nothing", test.input, queries)
+		}
+	}
+}
+
+func TestImportCSVRollsBackReplace(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("INSERT").fails(pgError("23505", "duplicate key value violates unique constraint"))
+	input := "p, alice, data1, read\np, alice, data1, read\n"
+	if err := a.ImportCSV(context.Background(), strings.NewReader(input), true); err == nil {
+		t.Fatal("got no error, want the insert to fail")
+	}
+	if n := f.count("TRUNCATE"); n != 1 {
+		t.Errorf("got %d truncates, want 1", n)
+	}
+	if n := f.count("ROLLBACK"); n != 1 {
+		t.Errorf("got %d rollbacks, want the truncate rolled back", n)
+	}
+	if n := f.count("COMMIT"); n != 0 {
+		t.Errorf("got %d commits, want none", n)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// loadCursorName is the name of the cursor of cursor loads.
+const loadCursorName = "casbin_load_cursor"
+
+// WithPreferServerSideCursor makes LoadPolicy on Postgres read the rules
+// through a server-side cursor, fetching and adding them to the model
+// fetchSize rows at a time, so that the memory used by the load stays
+// bounded however many rules are stored. The load then runs in a read-only
+// transaction on a single snapshot, like with WithConsistentLoad.
+//
+// The option is ignored on other dialects and with WithCache, which keeps
+// the whole result.
+func WithPreferServerSideCursor(fetchSize int) Option {
+	return func(a *Adapter) error {
+		if fetchSize < 1 {
+			return errors.Errorf("invalid cursor fetch size %d", fetchSize)
+		}
+		a.cursorFetchSize = fetchSize
+		return nil
+	}
+}
+
+// useLoadCursor reports whether LoadPolicy reads from db through a cursor.
+func (a *Adapter) useLoadCursor(db *bun.DB) bool {
+	return a.cursorFetchSize > 0 && a.cache == nil && db.Dialect().Name() == dialect.PG
+}
+
+// loadPolicyCursor adds the rules of tables to model, fetching them from db
+// through a cursor.
+func (a *Adapter) loadPolicyCursor(ctx context.Context, db *bun.DB, tables []string, model model.Model) error {
+	var check loadCheck
+	err := a.withSnapshot(ctx, db, func(db bun.IDB) error {
+		loaded := 0
+		for _, table := range tables {
+			q := a.newSelect(ctx, db, (*CasbinRule)(nil), table).OrderExpr("? ASC", bun.Ident(a.idColumnName()))
+			a.limitLoad(q, loaded)
+			if _, err := db.NewRaw("DECLARE ? NO SCROLL CURSOR FOR ?", bun.Ident(loadCursorName), q).
+				Conn(a.queryConn(ctx, db)).Exec(ctx); err != nil {
+				return err
+			}
+			for {
+				var lines []*CasbinRule
+				if err := db.NewRaw("FETCH FORWARD ? FROM ?", a.cursorFetchSize, bun.Ident(loadCursorName)).
+					Conn(a.queryConn(ctx, db)).Scan(ctx, &lines); err != nil {
+					return err
+				}
+				loaded += len(lines)
+				if err := a.checkLoadRows(loaded); err != nil {
+					return err
+				}
+				if err := a.loadRuleBatch(ctx, lines, model, &check); err != nil {
+					return err
+				}
+				if len(lines) < a.cursorFetchSize {
+					break
+				}
+			}
+			if _, err := db.NewRaw("CLOSE ?", bun.Ident(loadCursorName)).
+				Conn(a.queryConn(ctx, db)).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return check.err()
+}
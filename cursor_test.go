@@ -0,0 +1,117 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/uptrace/bun"
+)
+
+// fetchRecorder is a bun query hook recording, before each FETCH, how many
+// rules the model being loaded already holds.
+type fetchRecorder struct {
+	model  model.Model
+	loaded []int
+}
+
+func (r *fetchRecorder) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if strings.HasPrefix(event.Query, "FETCH") {
+		r.loaded = append(r.loaded, len(r.model.GetPolicy("p", "p")))
+	}
+	return ctx
+}
+
+func (r *fetchRecorder) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+// onCursorRows scripts the fetches of f to return n rules in batches of
+// size.
+func onCursorRows(f *fakeDB, n, size int) {
+	for start := 0; start < n; start += size {
+		var rules []*CasbinRule
+		for i := start; i < start+size && i < n; i++ {
+			rules = append(rules, &CasbinRule{Id: int64(i + 1), Ptype: "p", V0: fmt.Sprintf("user%d", i), V1: "data1", V2: "read"})
+		}
+		f.on("FETCH FORWARD").returnsRules(rules...).times(1)
+	}
+	f.on("FETCH FORWARD").returnsRules()
+}
+
+func TestServerSideCursor(t *testing.T) {
+	f, db := newFakeDB(t, "pg")
+	m := newTestModel(t)
+	recorder := &fetchRecorder{model: m}
+	db.AddQueryHook(recorder)
+	a, err := NewAdapterWithClient(db, WithPreferServerSideCursor(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	onCursorRows(f, 10500, 1000)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.GetPolicy("p", "p")); n != 10500 {
+		t.Errorf("loaded %d rules, want 10500", n)
+	}
+	// Each batch is in the model before the next one is fetched, so no
+	// more than a batch of rows is held at once.
+	want := []int{0, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000}
+	if !reflect.DeepEqual(recorder.loaded, want) {
+		t.Errorf("got rules loaded before each fetch %v, want %v", recorder.loaded, want)
+	}
+
+	kinds := statementKinds(f)
+	if len(kinds) != 15 || kinds[0] != "BEGIN" || kinds[1] != "DECLARE" || kinds[13] != "CLOSE" || kinds[14] != "COMMIT" {
+		t.Errorf("got statements %q, want 11 fetches from a cursor in a transaction", kinds)
+	}
+	if begin := f.queries("BEGIN"); len(begin) != 1 || begin[0] != "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY" {
+		t.Errorf("got %q, want a read-only snapshot", begin)
+	}
+	if n := f.count(`FETCH FORWARD 1000 FROM "casbin_load_cursor"`); n != 11 {
+		t.Errorf("got %d fetches of 1000 rows, want 11", n)
+	}
+	if n := f.count(`DECLARE "casbin_load_cursor" NO SCROLL CURSOR FOR SELECT`); n != 1 {
+		t.Errorf("got statements %q, want the cursor declared on the select", f.queries("DECLARE"))
+	}
+}
+
+func TestServerSideCursorIgnored(t *testing.T) {
+	for _, test := range []struct {
+		dialect string
+		options []Option
+	}{
+		{"mysql", nil},
+		{"pg", []Option{WithCache(time.Hour)}},
+	} {
+		a, f := newTestAdapter(t, test.dialect, append(test.options, WithPreferServerSideCursor(1000))...)
+		if err := a.LoadPolicy(newTestModel(t)); err != nil {
+			t.Fatal(err)
+		}
+		if n := f.count("CURSOR") + f.count("FETCH"); n != 0 {
+			t.Errorf("%s: got statements %q, want a plain select", test.dialect, f.queries(""))
+		}
+	}
+}
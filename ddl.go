@@ -0,0 +1,109 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithTableCreateHook lets fn customize the query CreateTable runs, e.g. to
+// add columns, constraints or storage parameters.
+func WithTableCreateHook(fn func(q *bun.CreateTableQuery) *bun.CreateTableQuery) Option {
+	return func(a *Adapter) error {
+		a.tableCreateHook = fn
+		return nil
+	}
+}
+
+// CreateTable creates the policy tables of ctx that do not exist yet,
+// including the column set by WithExtraColumn and the name set by
+// WithPtypeColumn.
+func (a *Adapter) CreateTable(ctx context.Context) (err error) {
+	ctx, endOp := a.startOp(ctx, "CreateTable")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := a.createTable(ctx, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) createTable(ctx context.Context, table string) error {
+	model, err := a.ddlModel()
+	if err != nil {
+		return err
+	}
+	q := a.client.NewCreateTable().
+		Conn(a.queryConn(ctx, a.client)).
+		Model(model).
+		ModelTableExpr(table).
+		IfNotExists()
+	if a.extraColumn != "" {
+		q.ColumnExpr("? ?", bun.Ident(a.extraColumn), bun.Safe(a.blobType()))
+	}
+	if a.tableCreateHook != nil {
+		q = a.tableCreateHook(q)
+	}
+	if _, err := q.Exec(ctx); err != nil {
+		return err
+	}
+	if a.ptypeRenamed() && !a.hasColumn(ctx, table, a.ptypeColumnName()) {
+		if err := a.renameColumn(ctx, table, "ptype", a.ptypeColumnName()); err != nil {
+			return err
+		}
+	}
+	if a.idRenamed() && !a.hasColumn(ctx, table, a.idColumnName()) {
+		return a.renameColumn(ctx, table, "id", a.idColumnName())
+	}
+	return nil
+}
+
+// renameColumn renames the column from of table to to.
+func (a *Adapter) renameColumn(ctx context.Context, table, from, to string) error {
+	var err error
+	if a.client.Dialect().Name() == dialect.MSSQL {
+		_, err = a.client.NewRaw("EXEC sp_rename ?, ?, 'COLUMN'", table+"."+from, to).Conn(a.queryConn(ctx, a.client)).Exec(ctx)
+	} else {
+		_, err = a.client.NewRaw("ALTER TABLE ? RENAME COLUMN ? TO ?",
+			bun.Safe(table), bun.Ident(from), bun.Ident(to)).Conn(a.queryConn(ctx, a.client)).Exec(ctx)
+	}
+	return err
+}
+
+// blobType returns the column type of binary values in the dialect.
+func (a *Adapter) blobType() string {
+	switch a.client.Dialect().Name() {
+	case dialect.PG:
+		return "BYTEA"
+	case dialect.MSSQL:
+		return "VARBINARY(MAX)"
+	default:
+		return "BLOB"
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+func TestTableCreateHook(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTableCreateHook(func(q *bun.CreateTableQuery) *bun.CreateTableQuery {
+		return q.ColumnExpr("tenant_id BIGINT NOT NULL DEFAULT 0").
+			TableSpace("fast_ssd")
+	}))
+	if err := a.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	creates := f.queries("CREATE TABLE")
+	if len(creates) != 1 {
+		t.Fatalf("got statements %q, want one CREATE TABLE", f.queries(""))
+	}
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS public.casbin_rule (",
+		`"v7" VARCHAR`,
+		"tenant_id BIGINT NOT NULL DEFAULT 0",
+		`TABLESPACE "fast_ssd"`,
+	} {
+		if !strings.Contains(creates[0], want) {
+			t.Errorf("got %q, want %q", creates[0], want)
+		}
+	}
+}
+
+func TestColumnDefinitions(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithColumnDefinitions(
+		ColumnDefinition{Name: "v0", Type: "VARCHAR(100)", Default: "''"},
+		ColumnDefinition{Name: "v7", Nullable: true},
+	))
+	if err := a.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	creates := f.queries("CREATE TABLE")
+	if len(creates) != 1 {
+		t.Fatalf("got statements %q, want one CREATE TABLE", f.queries(""))
+	}
+	for _, want := range []string{
+		`"id" BIGSERIAL NOT NULL`,
+		`"ptype" VARCHAR NOT NULL`,
+		`"v0" VARCHAR(100) NOT NULL DEFAULT ''`,
+		`"v6" VARCHAR NOT NULL DEFAULT ''`,
+		`"v7" VARCHAR, `,
+	} {
+		if !strings.Contains(creates[0], want) {
+			t.Errorf("got %q, want %q", creates[0], want)
+		}
+	}
+}
+
+func TestColumnDefinitionsRenamed(t *testing.T) {
+	a, f := newTestAdapter(t, "pg",
+		WithPtypeColumn("policy_type"),
+		WithColumnDefinitions(ColumnDefinition{Name: "policy_type", Type: "VARCHAR(8)"}),
+	)
+	f.on(`SELECT "policy_type" FROM public.casbin_rule WHERE 1 = 0`).
+		fails(pgError("42703", `column "policy_type" does not exist`))
+	if err := a.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The table is created with the column of the model, then renamed.
+	if n := f.count(`"ptype" VARCHAR(8) NOT NULL`); n != 1 {
+		t.Errorf("got %q, want the renamed column defined", f.queries("CREATE TABLE"))
+	}
+	if n := f.count(`RENAME COLUMN "ptype" TO "policy_type"`); n != 1 {
+		t.Errorf("got statements %q, want the column renamed", f.queries(""))
+	}
+}
+
+func TestColumnDefinitionsMigrate(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithMigrationVersion(1),
+		WithColumnDefinitions(ColumnDefinition{Name: "v7", Nullable: true}))
+	f.on("MAX(version)").returns([]string{"max"}, []driver.Value{nil})
+	if err := a.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count(`CREATE TABLE IF NOT EXISTS public.casbin_rule ("id"`); n != 1 {
+		t.Fatalf("got statements %q, want the policy table created", f.queries(""))
+	}
+	if n := f.count(`"v7" VARCHAR, `); n != 1 {
+		t.Errorf("got %q, want a nullable v7", f.queries("CREATE TABLE"))
+	}
+}
+
+func TestColumnDefinitionsInvalid(t *testing.T) {
+	_, db := newFakeDB(t, "pg")
+	if _, err := NewAdapterWithClient(db, WithColumnDefinitions(ColumnDefinition{Type: "TEXT"})); err == nil {
+		t.Error("got no error for a definition without a name")
+	}
+
+	a, f := newTestAdapter(t, "pg", WithColumnDefinitions(ColumnDefinition{Name: "v9"}))
+	if err := a.CreateTable(context.Background()); err == nil {
+		t.Error("got no error for an unknown column")
+	}
+	if n := f.count("CREATE TABLE"); n != 0 {
+		t.Errorf("got statements %q, want nothing created", f.queries(""))
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ColumnDefinition overrides how CreateTable and Migrate define a column of
+// the policy table instead of the bun tags of CasbinRule.
+type ColumnDefinition struct {
+	// Name is the column, e.g. "v7", as named by the tags of CasbinRule or
+	// WithModel, or by WithPtypeColumn and WithIDColumn.
+	Name string
+	// Type is the SQL type, e.g. "VARCHAR(100)". Empty keeps the type of
+	// the dialect.
+	Type string
+	// Nullable drops the NOT NULL constraint.
+	Nullable bool
+	// Default is the SQL expression of the default value, e.g. "''". Empty
+	// means no default.
+	Default string
+}
+
+// WithColumnDefinitions makes CreateTable and Migrate define the columns of
+// defs as given, e.g. a nullable V7 or VARCHAR(100) V columns. Columns
+// without a definition keep the ones of the struct tags. Inserts leave empty
+// V columns to their default, so a nullable V column without a default
+// stores them as NULL and needs WithNullSafeMatching.
+func WithColumnDefinitions(defs ...ColumnDefinition) Option {
+	return func(a *Adapter) error {
+		for _, def := range defs {
+			if def.Name == "" {
+				return errors.New("column definition without a name")
+			}
+		}
+		a.columnDefs = append(a.columnDefs, defs...)
+		return nil
+	}
+}
+
+// ddlModel returns the model CreateTable creates tables from: the one of
+// tableModel, or with WithColumnDefinitions a struct of the same fields whose
+// tags define the columns as configured.
+func (a *Adapter) ddlModel() (interface{}, error) {
+	model := a.tableModel()
+	if len(a.columnDefs) == 0 {
+		return model, nil
+	}
+	defs := make(map[string]ColumnDefinition, len(a.columnDefs))
+	for _, def := range a.columnDefs {
+		name := def.Name
+		switch {
+		case a.ptypeRenamed() && name == a.ptypeColumnName():
+			name = "ptype"
+		case a.idRenamed() && name == a.idColumnName():
+			name = "id"
+		}
+		defs[name] = def
+	}
+	table := a.client.Table(reflect.TypeOf(model).Elem())
+	fields := make([]reflect.StructField, 0, len(table.Fields))
+	for _, field := range table.Fields {
+		sf := reflect.StructField{
+			Name: field.StructField.Name,
+			Type: field.StructField.Type,
+			Tag:  field.StructField.Tag,
+		}
+		if def, ok := defs[field.Name]; ok {
+			sf.Tag = columnTag(field.Name, field.IsPK, field.AutoIncrement, def)
+			delete(defs, field.Name)
+		}
+		fields = append(fields, sf)
+	}
+	for name := range defs {
+		return nil, errors.Errorf("column definition for unknown column %s", name)
+	}
+	return reflect.New(reflect.StructOf(fields)).Interface(), nil
+}
+
+// columnTag returns the bun tag of the column name defined by def.
+func columnTag(name string, pk, autoincrement bool, def ColumnDefinition) reflect.StructTag {
+	opts := []string{name}
+	if pk {
+		opts = append(opts, "pk")
+	}
+	if autoincrement {
+		opts = append(opts, "autoincrement")
+	}
+	if def.Type != "" {
+		opts = append(opts, "type:"+quoteTagValue(def.Type))
+	}
+	if !def.Nullable {
+		opts = append(opts, "notnull")
+	}
+	if def.Default != "" {
+		opts = append(opts, "default:"+quoteTagValue(def.Default))
+	}
+	return reflect.StructTag("bun:" + strconv.Quote(strings.Join(opts, ",")))
+}
+
+// quoteTagValue quotes value for a bun tag, so that commas and parentheses
+// in SQL types and expressions stay part of it.
+func quoteTagValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
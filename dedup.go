@@ -0,0 +1,71 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// WithSkipExistingOnAdd makes AddPolicies insert only the rules that are not
+// stored yet, checked within the same transaction, so that adding the same
+// batch twice is harmless. AddPoliciesN reports the rows actually inserted.
+func WithSkipExistingOnAdd() Option {
+	return func(a *Adapter) error {
+		a.skipExisting = true
+		return nil
+	}
+}
+
+// missingRules returns the lines that are not stored in table, dropping
+// repeated lines as well.
+func (a *Adapter) missingRules(ctx context.Context, tx bun.Tx, table string, lines []*CasbinRule) ([]*CasbinRule, error) {
+	seen := make(map[string]bool, len(lines))
+	missing := make([]*CasbinRule, 0, len(lines))
+	for _, line := range lines {
+		key := ruleKey(line)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		q := a.newSelect(ctx, tx, (*CasbinRule)(nil), table)
+		a.wherePolicy(q.QueryBuilder(), line)
+		exists, err := q.Exists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, line)
+		}
+	}
+	return missing, nil
+}
+
+// ruleKey identifies the stored values of line.
+func ruleKey(line *CasbinRule) string {
+	values := []string{line.Ptype}
+	for _, field := range line.fields() {
+		values = append(values, *field)
+	}
+	return strings.Join(values, "\x00")
+}
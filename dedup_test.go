@@ -0,0 +1,69 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestSkipExistingOnAdd(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql", WithSkipExistingOnAdd())
+	// alice and bob are stored already.
+	f.on("`v0` = 'alice'").returns([]string{"exists"}, []driver.Value{true})
+	f.on("`v0` = 'bob'").returns([]string{"exists"}, []driver.Value{true})
+	f.on("EXISTS").returns([]string{"exists"}, []driver.Value{false})
+	f.on("INSERT").affects(2)
+
+	rules := [][]string{
+		{"alice", "data1", "read"},
+		{"carol", "data1", "read"},
+		{"bob", "data2", "write"},
+		{"dave", "data2", "write"},
+		{"carol", "data1", "read"},
+	}
+	n, err := a.AddPoliciesN("p", "p", rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d rows inserted, want 2", n)
+	}
+	if checks := f.count("EXISTS"); checks != 4 {
+		t.Errorf("got %d existence checks, want one per distinct rule", checks)
+	}
+	inserts := f.queries("INSERT")
+	if len(inserts) != 1 {
+		t.Fatalf("got inserts %q, want one", inserts)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if f.count("'"+user+"', 'data") != 0 {
+			t.Errorf("got insert %q, want %s skipped", inserts[0], user)
+		}
+	}
+	for _, user := range []string{"carol", "dave"} {
+		if f.count("'"+user+"', 'data") != 1 {
+			t.Errorf("got insert %q, want %s inserted once", inserts[0], user)
+		}
+	}
+	if got := f.queries(""); got[0] != "BEGIN" || got[len(got)-1] != "COMMIT" {
+		t.Errorf("got statements %q, want the checks and insert in one transaction", got)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDuplicateRows is returned by loads with WithDetectDuplicatesOnLoad when
+// the same rule is stored in more than one row.
+var ErrDuplicateRows = errors.New("rules are stored more than once")
+
+// WithDetectDuplicatesOnLoad makes loads fail with ErrDuplicateRows, listing
+// each rule stored more than once with the ids of its rows, instead of
+// silently loading the duplicates. Rows are the same rule when their ptype
+// and decoded V columns are equal. The rules are still added to the model.
+// Duplicates usually mean the table lacks a unique index, see
+// CreateUniqueConstraint.
+//
+// The detection keeps the values of every loaded rule until the load ends,
+// including on loads through WithPreferServerSideCursor.
+func WithDetectDuplicatesOnLoad() Option {
+	return func(a *Adapter) error {
+		a.detectDuplicates = true
+		return nil
+	}
+}
+
+// addRule records the loaded line, noting it as a duplicate if a row of the
+// same rule was loaded before.
+func (c *loadCheck) addRule(line *CasbinRule) {
+	if c.firstIDs == nil {
+		c.firstIDs = make(map[string]int64)
+	}
+	key := ruleKey(line)
+	first, ok := c.firstIDs[key]
+	if !ok {
+		c.firstIDs[key] = line.Id
+		return
+	}
+	values := strings.TrimRight(key, "\x00")
+	c.duplicates = append(c.duplicates, fmt.Sprintf("%q stored as ids %d and %d",
+		strings.Split(values, "\x00"), first, line.Id))
+}
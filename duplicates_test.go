@@ -0,0 +1,89 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDetectDuplicatesOnLoad(t *testing.T) {
+	rows := []*CasbinRule{
+		{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		{Id: 2, Ptype: "g", V0: "alice", V1: "admin"},
+		{Id: 3, Ptype: "p", V0: "alice", V1: "data1", V2: "read", V3: "deny"},
+		{Id: 4, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+	}
+
+	a, f := newTestAdapter(t, "pg", WithDetectDuplicatesOnLoad())
+	f.on("SELECT").returnsRules(rows...)
+	m := newTestModel(t)
+	err := a.LoadPolicy(m)
+	if !errors.Is(err, ErrDuplicateRows) {
+		t.Fatalf("got error %v, want ErrDuplicateRows", err)
+	}
+	if want := `["p" "alice" "data1" "read"] stored as ids 1 and 4`; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+	if strings.Contains(err.Error(), "ids 1 and 3") || strings.Contains(err.Error(), "admin") {
+		t.Errorf("got error %q, want only the duplicated rule", err)
+	}
+	if got := m.GetPolicy("g", "g"); len(got) != 1 {
+		t.Errorf("got roles %q, want the rules added to the model", got)
+	}
+
+	// Duplicates load silently by default.
+	a, f = newTestAdapter(t, "pg")
+	f.on("SELECT").returnsRules(rows...)
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Errorf("got error %v without WithDetectDuplicatesOnLoad", err)
+	}
+}
+
+func TestDetectDuplicatesOnLoadCursor(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithDetectDuplicatesOnLoad(), WithPreferServerSideCursor(2))
+	// The duplicate is fetched in another batch than the first row.
+	f.on("FETCH FORWARD").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "p", V0: "bob", V1: "data2", V2: "read"},
+	).times(1)
+	f.on("FETCH FORWARD").returnsRules(
+		&CasbinRule{Id: 7, Ptype: "p", V0: "bob", V1: "data2", V2: "read"},
+	)
+	err := a.LoadPolicy(newTestModel(t))
+	if !errors.Is(err, ErrDuplicateRows) || !strings.Contains(err.Error(), `["p" "bob" "data2" "read"] stored as ids 2 and 7`) {
+		t.Errorf("got error %v, want bob's rule reported as ids 2 and 7", err)
+	}
+}
+
+func TestDetectDuplicatesOnLoadStrict(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithDetectDuplicatesOnLoad(), WithStrictLoad())
+	f.on("SELECT").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "p"},
+		&CasbinRule{Id: 3, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+	)
+	// Invalid rows are reported first.
+	if err := a.LoadPolicy(newTestModel(t)); !errors.Is(err, ErrInvalidRows) {
+		t.Errorf("got error %v, want ErrInvalidRows", err)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrEmptySave is returned by SavePolicy with WithGuardEmptySave when the
+// model holds no policy rules.
+var ErrEmptySave = errors.New("refusing to save a model without policy rules")
+
+// WithGuardEmptySave makes SavePolicy fail with ErrEmptySave, leaving the
+// stored rules untouched, when the model holds no rules, which is more often
+// an enforcer that was never loaded than an intent to delete every rule. Use
+// Clear to empty the tables on purpose.
+func WithGuardEmptySave() Option {
+	return func(a *Adapter) error {
+		a.guardEmptySave = true
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestGuardEmptySave(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithGuardEmptySave())
+	f.on("SELECT").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "g", V0: "alice", V1: "admin"},
+	)
+
+	if err := a.SavePolicy(newTestModel(t)); !errors.Is(err, ErrEmptySave) {
+		t.Fatalf("got error %v, want ErrEmptySave", err)
+	}
+	for _, statement := range []string{"BEGIN", "TRUNCATE", "DELETE", "INSERT"} {
+		if n := f.count(statement); n != 0 {
+			t.Errorf("got %q, want no %s", f.queries(""), statement)
+		}
+	}
+
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 {
+		t.Errorf("got policies %q, want alice's rule kept", got)
+	}
+
+	if err := a.SavePolicy(newTestModel(t, []string{"p", "bob", "data2", "read"})); err != nil {
+		t.Errorf("got error %v saving a model with rules", err)
+	}
+	if n := f.count("TRUNCATE"); n != 1 {
+		t.Errorf("got %d truncates, want 1", n)
+	}
+}
+
+func TestGuardEmptySaveOff(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if err := a.SavePolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("TRUNCATE"); n != 1 {
+		t.Errorf("got %q, want the table truncated", f.queries(""))
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Encryptor encrypts and decrypts stored policy values.
+//
+// Matching rules and filters against encrypted columns is done by encrypting
+// the query values, so Encrypt must be deterministic: the same plaintext has
+// to encrypt to the same ciphertext every time.
+type Encryptor interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// WithFieldEncryptor encrypts the V columns at the given indices (0 for V0)
+// with enc on write and decrypts them on load. Empty values are left as is.
+func WithFieldEncryptor(columns []int, enc Encryptor) Option {
+	return func(a *Adapter) error {
+		for _, column := range columns {
+			if column < 0 || column >= maxFields {
+				return errors.Errorf("encrypted column index %d out of range", column)
+			}
+		}
+		a.encryptedColumns = columns
+		a.encryptor = enc
+		return nil
+	}
+}
+
+func (a *Adapter) isEncrypted(column int) bool {
+	if a.encryptor == nil {
+		return false
+	}
+	for _, c := range a.encryptedColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
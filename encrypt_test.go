@@ -0,0 +1,97 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// base64Encryptor is a deterministic Encryptor for tests.
+type base64Encryptor struct{}
+
+func (base64Encryptor) Encrypt(plaintext string) (string, error) {
+	return "enc:" + base64.StdEncoding.EncodeToString([]byte(plaintext)), nil
+}
+
+func (base64Encryptor) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, "enc:") {
+		return "", errors.Errorf("not encrypted: %q", ciphertext)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, "enc:"))
+	return string(b), err
+}
+
+func TestFieldEncryptor(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithFieldEncryptor([]int{0}, base64Encryptor{}))
+	email, _ := base64Encryptor{}.Encrypt("alice@example.com")
+	rule := []string{"alice@example.com", "data1", "read"}
+
+	if err := a.AddPolicy("p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	insert := f.queries("INSERT")
+	if len(insert) != 1 || !strings.Contains(insert[0], "'"+email+"'") || strings.Contains(insert[0], "alice@example.com") {
+		t.Errorf("got inserts %q, want V0 encrypted", insert)
+	}
+
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: email, V1: "data1", V2: "read"})
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 || strings.Join(got[0], ",") != "alice@example.com,data1,read" {
+		t.Errorf("loaded %q, want V0 decrypted", got)
+	}
+
+	if err := a.RemovePolicy("p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	want := `DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v0" = '` + email + `') AND ("v1" = 'data1')`
+	if got := f.queries("DELETE"); len(got) != 1 || !strings.HasPrefix(got[0], want) {
+		t.Errorf("got deletes %q, want one starting with %q", got, want)
+	}
+
+	f.reset()
+	if err := a.LoadFilteredPolicy(newTestModel(t), Filter{V0: []string{"alice@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.queries("SELECT"); len(got) != 1 || !strings.Contains(got[0], "'"+email+"'") {
+		t.Errorf("got selects %q, want the filter value encrypted", got)
+	}
+}
+
+func TestFieldEncryptorDecryptError(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithFieldEncryptor([]int{0}, base64Encryptor{}))
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+	if err := a.LoadPolicy(newTestModel(t)); err == nil || !strings.Contains(err.Error(), "not encrypted") {
+		t.Errorf("got error %v, want the decryption error", err)
+	}
+}
+
+func TestFieldEncryptorColumnRange(t *testing.T) {
+	_, db := newFakeDB(t, "pg")
+	if _, err := NewAdapterWithClient(db, WithFieldEncryptor([]int{8}, base64Encryptor{})); err == nil {
+		t.Error("got no error for column index 8")
+	}
+}
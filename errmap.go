@@ -0,0 +1,124 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDuplicate is reported by the built-in error mappers when a write
+	// violates a unique constraint.
+	ErrDuplicate = errors.New("casbinbunadapter: duplicate policy")
+	// ErrConstraint is reported by the built-in error mappers when a write
+	// violates any other integrity constraint.
+	ErrConstraint = errors.New("casbinbunadapter: constraint violation")
+)
+
+// WithErrorMapper passes every error returned by the adapter through fn, so
+// that driver errors can be translated into domain errors. fn must return
+// errors it does not recognize unchanged.
+func WithErrorMapper(fn func(error) error) Option {
+	return func(a *Adapter) error {
+		a.errorMapper = fn
+		return nil
+	}
+}
+
+func (a *Adapter) mapError(err *error) {
+	if *err != nil && a.errorMapper != nil {
+		*err = a.errorMapper(*err)
+	}
+	*err = redactError(*err)
+}
+
+// mappedError reports the kind it was mapped to while keeping the driver
+// error in the chain.
+type mappedError struct {
+	kind error
+	err  error
+}
+
+func (e *mappedError) Error() string        { return e.err.Error() }
+func (e *mappedError) Unwrap() error        { return e.err }
+func (e *mappedError) Is(target error) bool { return target == e.kind }
+
+func mapped(kind, err error) error {
+	return &mappedError{kind: kind, err: err}
+}
+
+// PostgresErrorMapper maps Postgres integrity constraint violations (SQLSTATE
+// class 23) to ErrDuplicate and ErrConstraint. It understands the errors of
+// both pgdriver and pgx.
+func PostgresErrorMapper(err error) error {
+	var code string
+	var pgdriverErr interface{ Field(byte) string }
+	var pgxErr interface{ SQLState() string }
+	switch {
+	case errors.As(err, &pgdriverErr):
+		code = pgdriverErr.Field('C')
+	case errors.As(err, &pgxErr):
+		code = pgxErr.SQLState()
+	default:
+		return err
+	}
+	switch {
+	case code == "23505":
+		return mapped(ErrDuplicate, err)
+	case strings.HasPrefix(code, "23"):
+		return mapped(ErrConstraint, err)
+	}
+	return err
+}
+
+// MySQLErrorMapper maps MySQL duplicate key and constraint errors to
+// ErrDuplicate and ErrConstraint.
+func MySQLErrorMapper(err error) error {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return err
+	}
+	switch myErr.Number {
+	case 1062, 1586:
+		return mapped(ErrDuplicate, err)
+	case 1048, 1451, 1452, 3819:
+		return mapped(ErrConstraint, err)
+	}
+	return err
+}
+
+// MSSQLErrorMapper maps SQL Server unique key, unique index and constraint
+// errors to ErrDuplicate and ErrConstraint.
+func MSSQLErrorMapper(err error) error {
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	if !errors.As(err, &mssqlErr) {
+		return err
+	}
+	switch mssqlErr.SQLErrorNumber() {
+	case 2601, 2627:
+		return mapped(ErrDuplicate, err)
+	case 515, 547:
+		return mapped(ErrConstraint, err)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorMappers(t *testing.T) {
+	tests := []struct {
+		dialect string
+		mapper  func(error) error
+		err     error
+		want    error
+	}{
+		{"pg", PostgresErrorMapper, pgError("23505", "duplicate key value violates unique constraint"), ErrDuplicate},
+		{"pg", PostgresErrorMapper, pgError("23502", "null value in column violates not-null constraint"), ErrConstraint},
+		{"pg", PostgresErrorMapper, pgError("40001", "could not serialize access"), nil},
+		{"mysql", MySQLErrorMapper, mysqlError(1062, "Duplicate entry 'p-alice' for key 'unique_index'"), ErrDuplicate},
+		{"mysql", MySQLErrorMapper, mysqlError(1452, "Cannot add or update a child row"), ErrConstraint},
+		{"mysql", MySQLErrorMapper, mysqlError(1146, "Table 'casbin_rule' doesn't exist"), nil},
+		{"mysql", MySQLErrorMapper, &fakeError{msg: "trigger failed: Error 1062: Duplicate entry"}, nil},
+		{"mssql", MSSQLErrorMapper, mssqlError(2627, "Violation of UNIQUE KEY constraint"), ErrDuplicate},
+		{"mssql", MSSQLErrorMapper, mssqlError(547, "The INSERT statement conflicted with the CHECK constraint"), ErrConstraint},
+		{"mssql", MSSQLErrorMapper, mssqlError(208, "Invalid object name"), nil},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, test.dialect, WithErrorMapper(test.mapper))
+		f.on("INSERT").fails(test.err)
+		err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+		if err == nil {
+			t.Fatalf("%s: got no error, want %v", test.dialect, test.err)
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: %v is not mapped to %v", test.dialect, err, test.want)
+		}
+		if test.want == nil && (errors.Is(err, ErrDuplicate) || errors.Is(err, ErrConstraint)) {
+			t.Errorf("%s: %v is mapped, want it unchanged", test.dialect, err)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: %v does not wrap the driver error", test.dialect, err)
+		}
+	}
+}
+
+func TestErrorMapperNotAppliedOnSuccess(t *testing.T) {
+	calls := 0
+	a, _ := newTestAdapter(t, "pg", WithErrorMapper(func(err error) error {
+		calls++
+		return err
+	}))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Errorf("the mapper ran %d times without an error", calls)
+	}
+}
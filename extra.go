@@ -0,0 +1,37 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+// WithExtraColumn stores an additional binary column alongside every rule,
+// e.g. serialized attributes that are not part of enforcement. serialize
+// produces the value written for a rule; the stored value is loaded back
+// into CasbinRule.Extra and can be read with WithAfterLoad.
+//
+// With an extra column, bulk writes insert rows one at a time.
+func WithExtraColumn(name string, serialize func(ptype string, rule []string) ([]byte, error)) Option {
+	return func(a *Adapter) error {
+		if err := validateIdentifier(name); err != nil {
+			return err
+		}
+		a.extraColumn = name
+		a.extraSerializer = serialize
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestExtraColumn(t *testing.T) {
+	blob := []byte(`{"owner":"ops"}`)
+	var loaded []byte
+	a, f := newTestAdapter(t, "pg",
+		WithExtraColumn("attrs", func(ptype string, rule []string) ([]byte, error) {
+			return blob, nil
+		}),
+		WithAfterLoad(func(line *CasbinRule) {
+			loaded = line.Extra
+		}))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	insert := f.queries("INSERT")
+	if len(insert) != 1 || !strings.Contains(insert[0], `"attrs") VALUES`) || !strings.Contains(insert[0], `'\x`+hex.EncodeToString(blob)+`'`) {
+		t.Errorf("got inserts %q, want the blob in attrs", insert)
+	}
+
+	f.on("SELECT").returns(
+		[]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7", "extra"},
+		[]driver.Value{int64(1), "p", "alice", "data1", "read", "", "", "", "", "", blob},
+	)
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if string(loaded) != string(blob) {
+		t.Errorf("loaded extra %q, want %q", loaded, blob)
+	}
+	if got := f.queries("SELECT"); len(got) != 1 || !strings.Contains(got[0], `"attrs" AS extra`) {
+		t.Errorf("got selects %q, want attrs read as extra", got)
+	}
+}
+
+func TestExtraColumnSerializeError(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithExtraColumn("attrs", func(ptype string, rule []string) ([]byte, error) {
+		return nil, errors.New("cannot serialize")
+	}))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err == nil || !strings.Contains(err.Error(), "cannot serialize") {
+		t.Errorf("got error %v, want the serializer error", err)
+	}
+	if n := f.count("INSERT"); n != 0 {
+		t.Errorf("got %d inserts, want none", n)
+	}
+}
+
+func TestExtraColumnPrepared(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithPreparedStatements(),
+		WithExtraColumn("attrs", func(ptype string, rule []string) ([]byte, error) {
+			return []byte("{}"), nil
+		}))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count(`"v7", "attrs") VALUES ($1`); n != 1 {
+		t.Errorf("got statements %q, want attrs quoted in the prepared insert", f.queries(""))
+	}
+}
+
+func TestExtraColumnInvalid(t *testing.T) {
+	for _, name := range []string{"", "extra; --", `attrs"`} {
+		_, db := newFakeDB(t, "pg")
+		_, err := NewAdapterWithClient(db, WithExtraColumn(name, func(ptype string, rule []string) ([]byte, error) {
+			return nil, nil
+		}))
+		if !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("got error %v for column %q, want ErrInvalidIdentifier", err, name)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mssqldialect"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// fakeDriverName is the database/sql driver of fakeDB, whose data source
+// names are the dsn fields of the fakeDBs.
+const fakeDriverName = "casbinbunadapter-fake"
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = make(map[string]*fakeDB)
+	fakeDBSeq int
+)
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver{})
+}
+
+// fakeDB is a database for tests that records the statements it runs and
+// answers them with scripted responses, so that the SQL the adapter builds
+// and its handling of results and errors can be checked without a database.
+type fakeDB struct {
+	dsn       string // data source name of the fakeDB for fakeDriverName
+	mu        sync.Mutex
+	calls     []fakeCall
+	responses []*fakeResponse
+	prepares  []string // statements prepared
+	open      int      // prepared statements not closed yet
+	conns     int      // connections opened, guarded by fakeDBsMu
+}
+
+// fakeCall is a statement run on a fakeDB. Transactions are recorded as the
+// statements BEGIN, followed by the isolation level and READ ONLY if set,
+// COMMIT and ROLLBACK, and pings as PING.
+type fakeCall struct {
+	query string
+	args  []driver.Value
+	conn  int // number of the connection running it
+}
+
+// fakeResponse answers the statements containing match.
+type fakeResponse struct {
+	match    string
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	lastID   int64
+	err      error
+	delay    time.Duration
+	left     int // answers left, or -1 for any number
+}
+
+// newFakeDB returns a fakeDB registered under a name derived from the name
+// of t, and a bun client of the dialect name ("pg", "mysql" or "mssql")
+// connected to it.
+func newFakeDB(t testing.TB, name string) (*fakeDB, *bun.DB) {
+	t.Helper()
+	fakeDBsMu.Lock()
+	fakeDBSeq++
+	dsn := fmt.Sprintf("%s#%d", t.Name(), fakeDBSeq)
+	f := &fakeDB{dsn: dsn}
+	fakeDBs[dsn] = f
+	fakeDBsMu.Unlock()
+	t.Cleanup(func() {
+		fakeDBsMu.Lock()
+		delete(fakeDBs, dsn)
+		fakeDBsMu.Unlock()
+	})
+	sqldb, err := sql.Open(fakeDriverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := bun.NewDB(sqldb, fakeDialect(t, name))
+	t.Cleanup(func() { _ = db.Close() })
+	f.reset()
+	return f, db
+}
+
+func fakeDialect(t testing.TB, name string) schema.Dialect {
+	switch name {
+	case "pg":
+		return pgdialect.New()
+	case "mysql":
+		return mysqldialect.New()
+	case "mssql":
+		return mssqldialect.New()
+	}
+	t.Fatalf("unknown dialect %q", name)
+	return nil
+}
+
+// newTestAdapter returns an adapter of the dialect name on a fakeDB.
+func newTestAdapter(t testing.TB, name string, options ...Option) (*Adapter, *fakeDB) {
+	t.Helper()
+	f, db := newFakeDB(t, name)
+	a, err := NewAdapterWithClient(db, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, f
+}
+
+// testModelText is an RBAC model with the ptypes p and g.
+const testModelText = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+`
+
+// newTestModel returns a model of testModelText holding rules, given as the
+// ptype followed by the fields.
+func newTestModel(t testing.TB, rules ...[]string) model.Model {
+	t.Helper()
+	m, err := model.NewModelFromString(testModelText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range rules {
+		m.AddPolicy(rule[0][:1], rule[0], rule[1:])
+	}
+	return m
+}
+
+// on adds a response to the statements containing match, tried after the
+// responses added before. It answers any number of statements with no rows,
+// one affected row and no error until configured otherwise.
+func (f *fakeDB) on(match string) *fakeResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := &fakeResponse{match: match, affected: 1, left: -1}
+	f.responses = append(f.responses, r)
+	return r
+}
+
+// returns makes r answer with rows of columns.
+func (r *fakeResponse) returns(columns []string, rows ...[]driver.Value) *fakeResponse {
+	r.columns, r.rows = columns, rows
+	return r
+}
+
+// returnsRules makes r answer with the rows of rules.
+func (r *fakeResponse) returnsRules(rules ...*CasbinRule) *fakeResponse {
+	columns := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7"}
+	rows := make([][]driver.Value, 0, len(rules))
+	for _, rule := range rules {
+		row := []driver.Value{rule.Id, rule.Ptype}
+		for _, field := range rule.fields() {
+			row = append(row, *field)
+		}
+		rows = append(rows, row)
+	}
+	return r.returns(columns, rows...)
+}
+
+// affects makes r report n affected rows.
+func (r *fakeResponse) affects(n int64) *fakeResponse {
+	r.affected = n
+	return r
+}
+
+// insertID makes r report id as the last inserted id.
+func (r *fakeResponse) insertID(id int64) *fakeResponse {
+	r.lastID = id
+	return r
+}
+
+// takes makes r answer after d, or with the error of the context of the
+// statement if it is done before.
+func (r *fakeResponse) takes(d time.Duration) *fakeResponse {
+	r.delay = d
+	return r
+}
+
+// wait waits for the delay of r within ctx.
+func (r fakeResponse) wait(ctx context.Context) error {
+	if r.delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(r.delay):
+		return nil
+	}
+}
+
+// fails makes r answer with err.
+func (r *fakeResponse) fails(err error) *fakeResponse {
+	r.err = err
+	return r
+}
+
+// times limits r to n answers.
+func (r *fakeResponse) times(n int) *fakeResponse {
+	r.left = n
+	return r
+}
+
+// reset forgets the recorded statements.
+func (f *fakeDB) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.prepares = nil
+}
+
+// queries returns the recorded statements containing match, or all of them
+// if match is empty.
+func (f *fakeDB) queries(match string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var queries []string
+	for _, call := range f.calls {
+		if strings.Contains(call.query, match) {
+			queries = append(queries, call.query)
+		}
+	}
+	return queries
+}
+
+// connOf returns the connection of the first recorded statement containing
+// match, or 0 if there is none.
+func (f *fakeDB) connOf(match string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, call := range f.calls {
+		if strings.Contains(call.query, match) {
+			return call.conn
+		}
+	}
+	return 0
+}
+
+// connQueries returns the recorded statements of connection conn.
+func (f *fakeDB) connQueries(conn int) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var queries []string
+	for _, call := range f.calls {
+		if call.conn == conn {
+			queries = append(queries, call.query)
+		}
+	}
+	return queries
+}
+
+// args returns the arguments of the recorded statements containing match.
+func (f *fakeDB) args(match string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var args [][]driver.Value
+	for _, call := range f.calls {
+		if strings.Contains(call.query, match) {
+			args = append(args, call.args)
+		}
+	}
+	return args
+}
+
+// count returns the number of recorded statements containing match.
+func (f *fakeDB) count(match string) int {
+	return len(f.queries(match))
+}
+
+// prepared returns the number of times a statement containing match was
+// prepared, and the number of prepared statements still open.
+func (f *fakeDB) prepared(match string) (n, open int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, query := range f.prepares {
+		if strings.Contains(query, match) {
+			n++
+		}
+	}
+	return n, f.open
+}
+
+// run records query and returns its response.
+func (f *fakeDB) run(conn int, query string, args []driver.Value) fakeResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fakeCall{query: query, args: args, conn: conn})
+	for _, r := range f.responses {
+		if r.left == 0 || !strings.Contains(query, r.match) {
+			continue
+		}
+		if r.left > 0 {
+			r.left--
+		}
+		return *r
+	}
+	switch query {
+	case "SELECT version()":
+		return fakeResponse{columns: []string{"version"}, rows: [][]driver.Value{{"8.0.30"}}}
+	case "SELECT @@VERSION":
+		return fakeResponse{columns: []string{"version"}, rows: [][]driver.Value{{"Microsoft SQL Server 2019 - 15.0.2000.5 (X64)"}}}
+	}
+	return fakeResponse{affected: 1}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	f, ok := fakeDBs[dsn]
+	if !ok {
+		return nil, fmt.Errorf("no fake database %q", dsn)
+	}
+	f.conns++
+	return &fakeConn{db: f, id: f.conns}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+	id int
+	tx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.prepares = append(c.db.prepares, query)
+	c.db.open++
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	begin := "BEGIN"
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		begin += " ISOLATION LEVEL " + strings.ToUpper(sql.IsolationLevel(opts.Isolation).String())
+	}
+	if opts.ReadOnly {
+		begin += " READ ONLY"
+	}
+	if r := c.db.run(c.id, begin, nil); r.err != nil {
+		return nil, r.err
+	}
+	c.tx = true
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.tx = false
+	return c.db.run(c.id, "COMMIT", nil).err
+}
+
+func (c *fakeConn) Rollback() error {
+	c.tx = false
+	return c.db.run(c.id, "ROLLBACK", nil).err
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	return c.db.run(c.id, "PING", nil).err
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.db.run(c.id, query, namedValues(args))
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return fakeResult{affected: r.affected, lastID: r.lastID}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.db.run(c.id, query, namedValues(args))
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &fakeRows{columns: r.columns, rows: r.rows}, nil
+}
+
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.conn.db.mu.Lock()
+	defer s.conn.db.mu.Unlock()
+	s.conn.db.open--
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, valuesNamed(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, valuesNamed(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func valuesNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type fakeResult struct {
+	affected int64
+	lastID   int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.lastID, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// fakeError is a driver error exposing its code only in its message, like
+// the errors of go-sql-driver/mysql.
+type fakeError struct {
+	msg string
+}
+
+func (e *fakeError) Error() string { return e.msg }
+
+// mysqlError returns the error of the MySQL driver for number.
+func mysqlError(number int, msg string) error {
+	return &mysql.MySQLError{Number: uint16(number), Message: msg}
+}
+
+// pgFakeError is an error of a Postgres driver, like pgconn.PgError.
+type pgFakeError struct {
+	fakeError
+	sqlState string
+}
+
+// SQLState returns the Postgres error code.
+func (e *pgFakeError) SQLState() string { return e.sqlState }
+
+// pgError returns an error of a Postgres driver with code.
+func pgError(code, msg string) error {
+	return &pgFakeError{fakeError: fakeError{msg: "ERROR: " + msg + " (SQLSTATE " + code + ")"}, sqlState: code}
+}
+
+// mssqlFakeError is an error of the SQL Server driver, like mssql.Error.
+type mssqlFakeError struct {
+	fakeError
+	number int32
+}
+
+// SQLErrorNumber returns the SQL Server error number.
+func (e *mssqlFakeError) SQLErrorNumber() int32 { return e.number }
+
+// mssqlError returns an error of the SQL Server driver for number.
+func mssqlError(number int32, msg string) error {
+	return &mssqlFakeError{fakeError: fakeError{msg: "mssql: " + msg}, number: number}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithForeignKeyChecksDisabled makes SavePolicy turn off FOREIGN_KEY_CHECKS
+// on MySQL while it empties and refills the tables, so that it doesn't fail
+// on tables referencing the policy table. The checks are turned back on
+// before the transaction ends. Other dialects ignore it.
+func WithForeignKeyChecksDisabled() Option {
+	return func(a *Adapter) error {
+		a.fkChecksDisabled = true
+		return nil
+	}
+}
+
+// withoutForeignKeyChecks runs fn in tx with the foreign key checks of the
+// session turned off, if asked for on MySQL.
+func (a *Adapter) withoutForeignKeyChecks(ctx context.Context, tx bun.Tx, fn func() error) (err error) {
+	if !a.fkChecksDisabled || a.client.Dialect().Name() != dialect.MySQL {
+		return fn()
+	}
+	if _, err := tx.NewRaw("SET FOREIGN_KEY_CHECKS = 0").Conn(a.queryConn(ctx, tx)).Exec(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		// The setting outlives the transaction on the pooled connection.
+		if _, rerr := tx.NewRaw("SET FOREIGN_KEY_CHECKS = 1").Conn(a.queryConn(ctx, tx)).Exec(ctx); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// errFKTruncate is what MySQL returns when truncating casbin_rule while a
+// child table references it and the foreign key checks are on.
+var errFKTruncate = mysqlError(1701, "Cannot truncate a table referenced in a foreign key constraint (`app`.`grants`, CONSTRAINT `grants_rule_fk`)")
+
+// statementKinds returns the first word of each statement f saw, or the whole
+// statement for SETs.
+func statementKinds(f *fakeDB) []string {
+	var kinds []string
+	for _, query := range f.queries("") {
+		if !strings.HasPrefix(query, "SET ") {
+			query = strings.Fields(query)[0]
+		}
+		kinds = append(kinds, query)
+	}
+	return kinds
+}
+
+func TestForeignKeyChecksDisabled(t *testing.T) {
+	m := newTestModel(t, []string{"p", "alice", "data1", "read"})
+
+	t.Run("without option", func(t *testing.T) {
+		a, f := newTestAdapter(t, "mysql")
+		f.on("TRUNCATE").fails(errFKTruncate)
+		if err := a.SavePolicy(m); err == nil {
+			t.Fatal("SavePolicy() succeeded on a referenced table")
+		}
+		if n := f.count("FOREIGN_KEY_CHECKS"); n != 0 {
+			t.Errorf("got %d FOREIGN_KEY_CHECKS statements, want 0", n)
+		}
+	})
+
+	t.Run("with option", func(t *testing.T) {
+		a, f := newTestAdapter(t, "mysql", WithForeignKeyChecksDisabled())
+		if err := a.SavePolicy(m); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"BEGIN", "SET FOREIGN_KEY_CHECKS = 0", "TRUNCATE", "INSERT", "SET FOREIGN_KEY_CHECKS = 1", "COMMIT"}
+		if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+			t.Errorf("got statements %q, want %q", got, want)
+		}
+	})
+
+	t.Run("reenabled on failure", func(t *testing.T) {
+		a, f := newTestAdapter(t, "mysql", WithForeignKeyChecksDisabled())
+		f.on("INSERT").fails(mysqlError(1406, "Data too long for column 'v0' at row 1"))
+		if err := a.SavePolicy(m); err == nil {
+			t.Fatal("SavePolicy() succeeded with a failing insert")
+		}
+		want := []string{"BEGIN", "SET FOREIGN_KEY_CHECKS = 0", "TRUNCATE", "INSERT", "SET FOREIGN_KEY_CHECKS = 1", "ROLLBACK"}
+		if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+			t.Errorf("got statements %q, want %q", got, want)
+		}
+	})
+
+	t.Run("commented and logged", func(t *testing.T) {
+		l := new(captureLogger)
+		a, f := newTestAdapter(t, "mysql", WithForeignKeyChecksDisabled(), WithQueryComments(), WithLogger(l))
+		if err := a.SavePolicy(m); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"SET FOREIGN_KEY_CHECKS = 0 /* casbin:SavePolicy */",
+			"SET FOREIGN_KEY_CHECKS = 1 /* casbin:SavePolicy */",
+		}
+		if got := f.queries("FOREIGN_KEY_CHECKS"); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		var logged int
+		for _, msg := range l.debugs {
+			if strings.Contains(msg, "SET FOREIGN_KEY_CHECKS") {
+				logged++
+			}
+		}
+		if logged != 2 {
+			t.Errorf("got debug messages %q, want both SETs logged", l.debugs)
+		}
+	})
+
+	t.Run("ignored on pg", func(t *testing.T) {
+		a, f := newTestAdapter(t, "pg", WithForeignKeyChecksDisabled())
+		if err := a.SavePolicy(m); err != nil {
+			t.Fatal(err)
+		}
+		if n := f.count("FOREIGN_KEY_CHECKS"); n != 0 {
+			t.Errorf("got %d FOREIGN_KEY_CHECKS statements on pg, want 0", n)
+		}
+	})
+}
@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/casbin/casbin/v2 v2.75.0
+	github.com/go-sql-driver/mysql v1.7.1
 	github.com/pkg/errors v0.9.1
 	github.com/uptrace/bun v1.1.14
 	github.com/uptrace/bun/dialect/mssqldialect v1.1.14
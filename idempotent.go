@@ -0,0 +1,95 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// ErrAddIdempotentDialect is returned when WithAddIdempotent is used with a
+// database other than Postgres, SQLite and MySQL.
+var ErrAddIdempotentDialect = errors.New("casbinbunadapter: WithAddIdempotent is not supported by this database")
+
+// WithAddIdempotent makes the adapter skip the rules that are already stored
+// when inserting, so that re-adding a rule, e.g. after a race between two
+// enforcers, neither fails nor creates a duplicate. It uses ON CONFLICT DO
+// NOTHING on Postgres and SQLite and INSERT IGNORE on MySQL; SQL Server and
+// Spanner have neither, and the adapter is not created there, use
+// WithSkipExistingOnAdd instead.
+//
+// A rule only conflicts with a stored one through a unique index over the
+// ptype and all V columns, as created by CreateUniqueConstraint without
+// columns; without it duplicates are inserted as usual. An index over fewer
+// columns skips the rules that merely agree on those.
+// AddPolicyReturningID returns 0 for a skipped rule.
+func WithAddIdempotent() Option {
+	return func(a *Adapter) error {
+		a.addIdempotent = true
+		return nil
+	}
+}
+
+// checkAddIdempotent returns ErrAddIdempotentDialect if WithAddIdempotent
+// is used with a database of name that cannot skip conflicting rows.
+func (a *Adapter) checkAddIdempotent(name dialect.Name) error {
+	if !a.addIdempotent {
+		return nil
+	}
+	switch name {
+	case dialect.PG, dialect.SQLite, dialect.MySQL:
+		if a.spannerDialect == nil {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrAddIdempotentDialect, "%s", name)
+}
+
+// ignoreDuplicates makes q skip the rows conflicting with stored ones, if
+// asked for.
+func (a *Adapter) ignoreDuplicates(q *bun.InsertQuery) *bun.InsertQuery {
+	if !a.addIdempotent {
+		return q
+	}
+	switch a.client.Dialect().Name() {
+	case dialect.PG, dialect.SQLite:
+		q.On("CONFLICT DO NOTHING")
+	case dialect.MySQL:
+		q.Ignore()
+	}
+	return q
+}
+
+// ignoreDuplicatesSQL returns the keyword following INSERT and the clause
+// ending a raw INSERT statement that make it skip conflicting rows, if asked
+// for.
+func (a *Adapter) ignoreDuplicatesSQL() (keyword, suffix string) {
+	if !a.addIdempotent {
+		return "", ""
+	}
+	switch a.client.Dialect().Name() {
+	case dialect.PG, dialect.SQLite:
+		return "", " ON CONFLICT DO NOTHING"
+	case dialect.MySQL:
+		return " IGNORE", ""
+	}
+	return "", ""
+}
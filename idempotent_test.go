@@ -0,0 +1,128 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAddIdempotent(t *testing.T) {
+	rule := []string{"alice", "data1", "read"}
+	tests := []struct {
+		name    string
+		dialect string
+		options []Option
+		want    string
+	}{
+		{"pg", "pg", nil, ") ON CONFLICT DO NOTHING"},
+		{"mysql", "mysql", nil, "INSERT IGNORE INTO "},
+		{"pg prepared", "pg", []Option{WithPreparedStatements()}, ") ON CONFLICT DO NOTHING"},
+		{"mysql prepared", "mysql", []Option{WithPreparedStatements()}, "INSERT IGNORE INTO "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, f := newTestAdapter(t, tt.dialect, append(tt.options, WithAddIdempotent())...)
+			if err := a.AddPolicy("p", "p", rule); err != nil {
+				t.Fatal(err)
+			}
+			// The stored rule conflicts with the re-added one, so the
+			// database inserts and returns nothing.
+			f.on("INSERT").returns([]string{"id"}).affects(0)
+			if err := a.AddPolicy("p", "p", rule); err != nil {
+				t.Fatalf("re-adding: %v", err)
+			}
+			if err := a.AddPolicies("p", "p", [][]string{rule}); err != nil {
+				t.Fatalf("re-adding in a batch: %v", err)
+			}
+			inserts := f.queries("INSERT")
+			if len(inserts) != 3 {
+				t.Fatalf("got %d inserts, want 3: %q", len(inserts), inserts)
+			}
+			for _, insert := range inserts {
+				if !strings.Contains(insert, tt.want) {
+					t.Errorf("got %q, want it to contain %q", insert, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddIdempotentReturningID(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithAddIdempotent())
+	f.on("INSERT").returns([]string{"id"})
+	id, err := a.AddPolicyReturningID(context.Background(), "p", []string{"alice", "data1", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 0 {
+		t.Errorf("got id %d for a skipped rule, want 0", id)
+	}
+}
+
+func TestAddNotIdempotentByDefault(t *testing.T) {
+	for _, dialect := range []string{"pg", "mysql"} {
+		a, f := newTestAdapter(t, dialect)
+		if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		if n := f.count("ON CONFLICT") + f.count("IGNORE"); n != 0 {
+			t.Errorf("%s: got %q, want a plain insert", dialect, f.queries("INSERT"))
+		}
+	}
+}
+
+func TestAddIdempotentUnsupported(t *testing.T) {
+	_, db := newFakeDB(t, "mssql")
+	if _, err := NewAdapterWithClient(db, WithAddIdempotent()); !errors.Is(err, ErrAddIdempotentDialect) {
+		t.Errorf("got error %v, want ErrAddIdempotentDialect", err)
+	}
+	if _, err := NewAdapterWithClient(db); err != nil {
+		t.Errorf("got error %v without WithAddIdempotent", err)
+	}
+}
+
+func TestAddIdempotentNeedsUniqueIndex(t *testing.T) {
+	// ON CONFLICT DO NOTHING only skips the rules the unique index of
+	// CreateUniqueConstraint rejects, so it has to cover every column the
+	// insert writes.
+	a, f := newTestAdapter(t, "pg", WithAddIdempotent())
+	if err := a.CreateUniqueConstraint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	columns := regexp.MustCompile(`\(([^()]*)\)`)
+	index := columns.FindStringSubmatch(f.queries("CREATE UNIQUE INDEX")[0])
+	insert := columns.FindStringSubmatch(f.queries("INSERT")[0])
+	if index == nil || insert == nil {
+		t.Fatalf("got no column lists in %q", f.queries(""))
+	}
+	// The id is generated, so it never conflicts.
+	inserted := strings.TrimPrefix(insert[1], `"id", `)
+	if index[1] != inserted {
+		t.Errorf("got index on (%s), want it on the inserted columns (%s)", index[1], inserted)
+	}
+}
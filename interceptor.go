@@ -0,0 +1,51 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql"
+)
+
+// ExecInterceptor runs a write of the adapter by calling next, and returns
+// its result. It may add behaviour around the call, or return an error
+// without calling it.
+type ExecInterceptor func(next func() (sql.Result, error)) (sql.Result, error)
+
+// WithExecInterceptor runs every insert, update, delete and truncate of the
+// adapter through fn, e.g. to wrap them in tracing spans or to inject faults
+// in tests. An error returned by fn fails the operation and rolls back its
+// transaction. Writes returning rows, such as inserts on Postgres, give fn a
+// nil Result.
+func WithExecInterceptor(fn ExecInterceptor) Option {
+	return func(a *Adapter) error {
+		a.execInterceptor = fn
+		return nil
+	}
+}
+
+// isWriteStatement reports whether query is a write run through the
+// interceptor of WithExecInterceptor.
+func isWriteStatement(query string) bool {
+	switch statementVerb(query) {
+	case "INSERT", "UPDATE", "DELETE", "TRUNCATE":
+		return true
+	}
+	return false
+}
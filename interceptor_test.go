@@ -0,0 +1,97 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// interceptRecorder is an ExecInterceptor counting its calls and failing
+// them with err instead of running the write, if set.
+type interceptRecorder struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (r *interceptRecorder) intercept(next func() (sql.Result, error)) (sql.Result, error) {
+	r.mu.Lock()
+	r.calls++
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return next()
+}
+
+func TestExecInterceptor(t *testing.T) {
+	for _, options := range [][]Option{nil, {WithPreparedStatements()}} {
+		r := new(interceptRecorder)
+		a, f := newTestAdapter(t, "mysql", append(options, WithExecInterceptor(r.intercept))...)
+		if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.LoadPolicy(newTestModel(t)); err != nil {
+			t.Fatal(err)
+		}
+		if r.calls != 2 {
+			t.Errorf("got %d intercepted writes, want 2", r.calls)
+		}
+		if f.count("INSERT") != 1 || f.count("DELETE") != 1 {
+			t.Errorf("got statements %q, want the insert and delete run", f.queries(""))
+		}
+	}
+}
+
+func TestExecInterceptorReturning(t *testing.T) {
+	r := new(interceptRecorder)
+	a, _ := newTestAdapter(t, "pg", WithExecInterceptor(r.intercept))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if r.calls != 1 {
+		t.Errorf("got %d intercepted writes for an insert returning rows, want 1", r.calls)
+	}
+}
+
+func TestExecInterceptorError(t *testing.T) {
+	injected := errors.New("injected fault")
+	for _, options := range [][]Option{nil, {WithPreparedStatements()}} {
+		r := &interceptRecorder{err: injected}
+		a, f := newTestAdapter(t, "mysql", append(options, WithExecInterceptor(r.intercept))...)
+		err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"})
+		if !errors.Is(err, injected) {
+			t.Fatalf("RemovePolicy() = %v, want the injected error", err)
+		}
+		want := []string{"BEGIN", "ROLLBACK"}
+		if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+			t.Errorf("got statements %q, want %q", got, want)
+		}
+	}
+}
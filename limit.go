@@ -0,0 +1,58 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// ErrTooManyRows is returned by LoadPolicy when the tables hold more rows
+// than allowed by WithMaxLoadRows.
+var ErrTooManyRows = errors.New("too many policy rows to load")
+
+// WithMaxLoadRows makes LoadPolicy fail with ErrTooManyRows instead of loading
+// more than n rows, to protect the memory of the process. At most n+1 rows
+// are read to find out.
+func WithMaxLoadRows(n int) Option {
+	return func(a *Adapter) error {
+		if n < 1 {
+			return errors.Errorf("invalid max load rows %d", n)
+		}
+		a.maxLoadRows = n
+		return nil
+	}
+}
+
+// limitLoad limits q to the rows LoadPolicy may still read after loaded
+// rows, one more than allowed to detect the excess.
+func (a *Adapter) limitLoad(q *bun.SelectQuery, loaded int) {
+	if a.maxLoadRows > 0 {
+		q.Limit(a.maxLoadRows - loaded + 1)
+	}
+}
+
+// checkLoadRows reports ErrTooManyRows if loaded exceeds WithMaxLoadRows.
+func (a *Adapter) checkLoadRows(loaded int) error {
+	if a.maxLoadRows > 0 && loaded > a.maxLoadRows {
+		return errors.Wrapf(ErrTooManyRows, "more than %d", a.maxLoadRows)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// fiftyRules returns the rows of a 50-row policy table.
+func fiftyRules() []*CasbinRule {
+	rules := make([]*CasbinRule, 50)
+	for i := range rules {
+		rules[i] = &CasbinRule{Id: int64(i + 1), Ptype: "p", V0: fmt.Sprintf("user%d", i), V1: "data1", V2: "read"}
+	}
+	return rules
+}
+
+func TestMaxLoadRows(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithMaxLoadRows(10))
+	f.on("SELECT").returnsRules(fiftyRules()...)
+	m := newTestModel(t)
+	err := a.LoadPolicy(m)
+	if !errors.Is(err, ErrTooManyRows) {
+		t.Fatalf("LoadPolicy() = %v, want ErrTooManyRows", err)
+	}
+	if n := f.count("LIMIT 11"); n != 1 {
+		t.Errorf("got %d selects with LIMIT 11, want 1: %q", n, f.queries("SELECT"))
+	}
+	if policy := m.GetPolicy("p", "p"); len(policy) != 0 {
+		t.Errorf("got %d rules loaded past the cap, want 0", len(policy))
+	}
+}
+
+func TestMaxLoadRowsWithinCap(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithMaxLoadRows(50))
+	f.on("SELECT").returnsRules(fiftyRules()...)
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if policy := m.GetPolicy("p", "p"); len(policy) != 50 {
+		t.Errorf("got %d rules, want 50", len(policy))
+	}
+}
+
+func TestMaxLoadRowsInvalid(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		_, db := newFakeDB(t, "pg")
+		if _, err := NewAdapterWithClient(db, WithMaxLoadRows(n)); err == nil {
+			t.Errorf("got no error for max load rows %d", n)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Logger is the minimal logging interface used by WithLogger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// WithLogger logs every statement at debug level and every failed statement
+// at error level through l. Bind arguments are redacted unless WithLogArgs is
+// also given.
+//
+// The logger is installed as a query hook on the bun client, so when the
+// client is shared it also sees queries that were not issued by the adapter.
+func WithLogger(l Logger) Option {
+	return func(a *Adapter) error {
+		a.logger = l
+		return nil
+	}
+}
+
+// WithLogArgs makes the logger installed by WithLogger print bind arguments.
+func WithLogArgs() Option {
+	return func(a *Adapter) error {
+		a.logArgs = true
+		return nil
+	}
+}
+
+type queryLogger struct {
+	logger Logger
+	args   bool
+}
+
+var _ bun.QueryHook = (*queryLogger)(nil)
+
+func (h *queryLogger) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *queryLogger) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	query := h.query(event)
+	elapsed := time.Since(event.StartTime)
+	if event.Err != nil && event.Err != sql.ErrNoRows {
+		h.logger.Errorf("%s (%s): %v", query, elapsed, event.Err)
+		return
+	}
+	h.logger.Debugf("%s (%s)", query, elapsed)
+}
+
+// query returns the statement of the event, with bind arguments replaced by
+// placeholders unless they were asked for.
+func (h *queryLogger) query(event *bun.QueryEvent) string {
+	if h.args {
+		return event.Query
+	}
+	if event.IQuery != nil {
+		if b, err := event.IQuery.AppendQuery(schema.NewNopFormatter(), nil); err == nil {
+			return string(b)
+		}
+	}
+	if event.QueryTemplate != "" {
+		return event.QueryTemplate
+	}
+	return event.Operation()
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// captureLogger records the messages logged through it.
+type captureLogger struct {
+	mu     sync.Mutex
+	debugs []string
+	errors []string
+}
+
+func (l *captureLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Errorf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func TestLoggerLogsLoadPolicy(t *testing.T) {
+	l := new(captureLogger)
+	a, _ := newTestAdapter(t, "pg", WithLogger(l))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.debugs) != 1 || !strings.HasPrefix(l.debugs[0], "SELECT ") {
+		t.Errorf("got debug messages %q, want one SELECT", l.debugs)
+	}
+	if len(l.errors) != 0 {
+		t.Errorf("got error messages %q, want none", l.errors)
+	}
+}
+
+func TestLoggerRedactsArgs(t *testing.T) {
+	filter := Filter{Ptype: []string{"p"}, V0: []string{"alice"}}
+	for _, logArgs := range []bool{false, true} {
+		l := new(captureLogger)
+		options := []Option{WithLogger(l)}
+		if logArgs {
+			options = append(options, WithLogArgs())
+		}
+		a, _ := newTestAdapter(t, "pg", options...)
+		if err := a.LoadFilteredPolicy(newTestModel(t), filter); err != nil {
+			t.Fatal(err)
+		}
+		if len(l.debugs) != 1 {
+			t.Fatalf("got debug messages %q, want one", l.debugs)
+		}
+		if got := strings.Contains(l.debugs[0], "'alice'"); got != logArgs {
+			t.Errorf("with WithLogArgs %v, logged %q", logArgs, l.debugs[0])
+		}
+	}
+}
+
+func TestLoggerLogsErrors(t *testing.T) {
+	l := new(captureLogger)
+	a, f := newTestAdapter(t, "pg", WithLogger(l))
+	f.on("SELECT").fails(errors.New("connection reset"))
+	if err := a.LoadPolicy(newTestModel(t)); err == nil {
+		t.Fatal("got no error, want the load to fail")
+	}
+	if len(l.errors) != 1 || !strings.HasSuffix(l.errors[0], ": connection reset") {
+		t.Errorf("got error messages %q, want the failed SELECT", l.errors)
+	}
+}
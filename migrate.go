@@ -0,0 +1,170 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// metaTableSuffix is appended to the policy table name to get the table
+// Migrate records the schema version in, e.g. casbin_rule_meta.
+const metaTableSuffix = "_meta"
+
+// migration is a schema change applied to each policy table. Steps must be
+// idempotent, as a failure between the change and the recording of its
+// version makes Migrate apply it again.
+type migration struct {
+	version int
+	apply   func(a *Adapter, ctx context.Context, table string) error
+}
+
+// migrations are the schema versions of the policy tables, in order.
+var migrations = []migration{
+	{1, func(a *Adapter, ctx context.Context, table string) error {
+		return a.createTable(ctx, table)
+	}},
+	{2, (*Adapter).addTimestampColumn},
+}
+
+// LatestMigrationVersion is the schema version Migrate upgrades to by default,
+// the version of the last of migrations.
+const LatestMigrationVersion = 2
+
+type migrationRow struct {
+	Version int `bun:"version,notnull"`
+}
+
+// WithMigrationVersion makes Migrate stop at version instead of
+// LatestMigrationVersion.
+func WithMigrationVersion(version int) Option {
+	return func(a *Adapter) error {
+		if version < 1 || version > LatestMigrationVersion {
+			return errors.Errorf("invalid migration version %d", version)
+		}
+		a.migrationVersion = version
+		return nil
+	}
+}
+
+// Migrate upgrades the policy tables of ctx to the latest schema version,
+// recording the version in the meta table next to the default table.
+// Version 1 creates the tables, version 2 adds the column of
+// WithTimestampColumn, maintained by the database. Already applied versions
+// are skipped, so Migrate can run on every startup.
+func (a *Adapter) Migrate(ctx context.Context) (err error) {
+	ctx, endOp := a.startOp(ctx, "Migrate")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	schema, table, err := a.resolveTable(ctx)
+	if err != nil {
+		return err
+	}
+	meta := a.joinTableName(schema, table+metaTableSuffix)
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = a.client.NewCreateTable().
+		Conn(a.queryConn(ctx, a.client)).
+		Model((*migrationRow)(nil)).
+		ModelTableExpr(meta).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	var current sql.NullInt64
+	err = a.client.NewSelect().
+		Conn(a.queryConn(ctx, a.client)).
+		TableExpr(meta).
+		ColumnExpr("MAX(version)").
+		Scan(ctx, &current)
+	if err != nil {
+		return err
+	}
+
+	target := a.migrationVersion
+	if target == 0 {
+		target = LatestMigrationVersion
+	}
+	for _, m := range migrations {
+		if int64(m.version) <= current.Int64 || m.version > target {
+			continue
+		}
+		for _, t := range tables {
+			if err := m.apply(a, ctx, t); err != nil {
+				return errors.Wrapf(err, "migrating %s to version %d", t, m.version)
+			}
+		}
+		_, err := a.client.NewInsert().
+			Conn(a.queryConn(ctx, a.client)).
+			Model(&migrationRow{Version: m.version}).
+			ModelTableExpr(meta).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if a.logger != nil {
+			a.logger.Debugf("migrated policy tables to version %d", m.version)
+		}
+	}
+	return nil
+}
+
+// addTimestampColumn adds the column of WithTimestampColumn to table unless
+// it exists.
+func (a *Adapter) addTimestampColumn(ctx context.Context, table string) error {
+	column := a.timestampColumn
+	if column == "" {
+		column = DefaultTimestampColumn
+	}
+	if a.hasColumn(ctx, table, column) {
+		return nil
+	}
+	var typ string
+	switch a.client.Dialect().Name() {
+	case dialect.PG:
+		typ = "TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	case dialect.MySQL:
+		typ = "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"
+	case dialect.MSSQL:
+		typ = "DATETIME2 NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	default:
+		// SQLite cannot add a column with a non-constant default.
+		typ = "TIMESTAMP"
+	}
+	q := "ALTER TABLE ? ADD ? ?"
+	if a.client.Dialect().Name() != dialect.MSSQL {
+		q = "ALTER TABLE ? ADD COLUMN ? ?"
+	}
+	_, err := a.client.NewRaw(q, bun.Safe(table), bun.Ident(column), bun.Safe(typ)).Conn(a.queryConn(ctx, a.client)).Exec(ctx)
+	return err
+}
+
+// hasColumn reports whether column can be selected from table.
+func (a *Adapter) hasColumn(ctx context.Context, table, column string) bool {
+	_, err := a.client.NewRaw("SELECT ? FROM ? WHERE 1 = 0", bun.Ident(column), bun.Safe(table)).Conn(a.queryConn(ctx, a.client)).Exec(ctx)
+	return err == nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	// The table is at version 1, without the timestamp column.
+	f.on("MAX(version)").returns([]string{"max"}, []driver.Value{int64(1)})
+	f.on(`SELECT "updated_at" FROM public.casbin_rule WHERE 1 = 0`).
+		fails(pgError("42703", `column "updated_at" does not exist`))
+
+	if err := a.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("CREATE TABLE IF NOT EXISTS public.casbin_rule_meta "); n != 1 {
+		t.Errorf("got statements %q, want the meta table created", f.queries(""))
+	}
+	if n := f.count("CREATE TABLE IF NOT EXISTS public.casbin_rule "); n != 0 {
+		t.Errorf("got statements %q, want version 1 skipped", f.queries(""))
+	}
+	alters := f.queries("ALTER TABLE")
+	if len(alters) != 1 || !strings.HasPrefix(alters[0], `ALTER TABLE public.casbin_rule ADD COLUMN "updated_at" TIMESTAMPTZ`) {
+		t.Errorf("got %q, want the timestamp column added", alters)
+	}
+	if got := f.queries("INSERT"); len(got) != 1 || !strings.Contains(got[0], "INSERT INTO public.casbin_rule_meta") || !strings.Contains(got[0], "VALUES (2)") {
+		t.Errorf("got inserts %q, want version 2 recorded", got)
+	}
+}
+
+func TestMigrateUpToDate(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("MAX(version)").returns([]string{"max"}, []driver.Value{int64(LatestMigrationVersion)})
+	if err := a.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("ALTER") + f.count("INSERT") + f.count("public.casbin_rule "); n != 0 {
+		t.Errorf("got statements %q, want nothing applied", f.queries(""))
+	}
+}
+
+func TestMigrateNewTable(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithMigrationVersion(1))
+	f.on("MAX(version)").returns([]string{"max"}, []driver.Value{nil})
+	if err := a.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("CREATE TABLE IF NOT EXISTS public.casbin_rule "); n != 1 {
+		t.Errorf("got statements %q, want the policy table created", f.queries(""))
+	}
+	if n := f.count("ALTER"); n != 0 {
+		t.Errorf("got statements %q, want version 2 skipped", f.queries(""))
+	}
+	if got := f.queries("INSERT"); len(got) != 1 || !strings.Contains(got[0], "VALUES (1)") {
+		t.Errorf("got inserts %q, want version 1 recorded", got)
+	}
+}
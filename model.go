@@ -0,0 +1,113 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+var casbinRuleType = reflect.TypeOf(CasbinRule{})
+
+// WithModel makes the adapter write rules through model, a pointer to a
+// struct that embeds CasbinRule and adds columns of its own, e.g.
+//
+//	type Rule struct {
+//		casbinbunadapter.CasbinRule
+//		CreatedBy string
+//	}
+//
+// CreateTable creates the added columns, and every inserted row starts as a
+// copy of *model with the rule's fields set, so the added fields are written
+// with the values of model, or the ones set by the struct's bun hooks such
+// as BeforeAppendModel. Loads, prepared statements, CopyFrom and
+// RestorePolicies only read and write the policy columns.
+func WithModel(model interface{}) Option {
+	return func(a *Adapter) error {
+		v := reflect.ValueOf(model)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return errors.Errorf("model must be a non-nil pointer to a struct, got %T", model)
+		}
+		field, ok := v.Elem().Type().FieldByName(casbinRuleType.Name())
+		if !ok || !field.Anonymous || field.Type != casbinRuleType || len(field.Index) != 1 {
+			return errors.Errorf("model %T does not embed CasbinRule", model)
+		}
+		a.model = v.Elem()
+		a.modelRuleIndex = field.Index[0]
+		return nil
+	}
+}
+
+// tableModel returns the model CreateTable creates tables from.
+func (a *Adapter) tableModel() interface{} {
+	if !a.model.IsValid() {
+		return (*CasbinRule)(nil)
+	}
+	return reflect.Zero(reflect.PtrTo(a.model.Type())).Interface()
+}
+
+// rowModel returns the model inserting line: line itself, or a copy of the
+// WithModel model holding it.
+func (a *Adapter) rowModel(line *CasbinRule) interface{} {
+	if !a.model.IsValid() {
+		return line
+	}
+	v := reflect.New(a.model.Type())
+	v.Elem().Set(a.model)
+	v.Elem().Field(a.modelRuleIndex).Set(reflect.ValueOf(*line))
+	return v.Interface()
+}
+
+// rowModels returns the model inserting lines, like rowModel.
+func (a *Adapter) rowModels(lines []*CasbinRule) interface{} {
+	if !a.model.IsValid() {
+		return &lines
+	}
+	rows := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(a.model.Type())), 0, len(lines))
+	for _, line := range lines {
+		rows = reflect.Append(rows, reflect.ValueOf(a.rowModel(line)))
+	}
+	ptr := reflect.New(rows.Type())
+	ptr.Elem().Set(rows)
+	return ptr.Interface()
+}
+
+// modelRule returns the rule held by a model returned by rowModel.
+func (a *Adapter) modelRule(model interface{}) *CasbinRule {
+	if line, ok := model.(*CasbinRule); ok {
+		return line
+	}
+	return reflect.ValueOf(model).Elem().Field(a.modelRuleIndex).Addr().Interface().(*CasbinRule)
+}
+
+// modelColumns returns the columns WithModel adds to the policy columns.
+func (a *Adapter) modelColumns() []string {
+	if !a.model.IsValid() {
+		return nil
+	}
+	var columns []string
+	for _, field := range a.client.Table(a.model.Type()).Fields {
+		if field.Index[0] != a.modelRuleIndex {
+			columns = append(columns, field.Name)
+		}
+	}
+	return columns
+}
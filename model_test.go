@@ -0,0 +1,160 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ownedRule is a policy row recording who added it.
+type ownedRule struct {
+	CasbinRule
+	CreatedBy string
+}
+
+// stampedRule is a policy row whose author is set by a bun hook.
+type stampedRule struct {
+	CasbinRule
+	CreatedBy string
+}
+
+var _ bun.BeforeAppendModelHook = (*stampedRule)(nil)
+
+func (r *stampedRule) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	if _, ok := query.(*bun.InsertQuery); ok {
+		r.CreatedBy = "hook:" + r.V0
+	}
+	return nil
+}
+
+func TestWithModel(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithModel(&ownedRule{CreatedBy: "sync-job"}))
+	if err := a.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"bob", "data2", "read"}, {"carol", "data2", "write"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := f.count(`"v7" VARCHAR NOT NULL DEFAULT '', "created_by" VARCHAR,`); n != 1 {
+		t.Errorf("got %q, want the table created with created_by", f.queries("CREATE TABLE"))
+	}
+	inserts := f.queries("INSERT")
+	if len(inserts) != 2 {
+		t.Fatalf("got inserts %q, want 2", inserts)
+	}
+	for _, insert := range inserts {
+		if !strings.Contains(insert, `"v7", "created_by") VALUES`) {
+			t.Errorf("got %q, want created_by inserted", insert)
+		}
+	}
+	if n := strings.Count(inserts[0], "'sync-job')"); n != 1 {
+		t.Errorf("got %q, want alice's rule created by sync-job", inserts[0])
+	}
+	if n := strings.Count(inserts[1], "'sync-job')"); n != 2 {
+		t.Errorf("got %q, want both rules created by sync-job", inserts[1])
+	}
+}
+
+func TestWithModelHook(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithModel(&stampedRule{}))
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "read"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"'hook:alice')", "'hook:bob')"} {
+		if n := f.count(want); n != 1 {
+			t.Errorf("got inserts %q, want %s", f.queries("INSERT"), want)
+		}
+	}
+}
+
+func TestWithModelReturningID(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithModel(&ownedRule{CreatedBy: "sync-job"}))
+	f.on(`RETURNING "id" AS id`).returns([]string{"id"}, []driver.Value{int64(9)})
+	id, err := a.AddPolicyReturningID(context.Background(), "p", []string{"alice", "data1", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 9 {
+		t.Errorf("got id %d, want 9", id)
+	}
+}
+
+func TestWithModelInvalid(t *testing.T) {
+	for _, model := range []interface{}{
+		nil,
+		ownedRule{},
+		(*ownedRule)(nil),
+		&struct{ CreatedBy string }{},
+		&struct{ Rule CasbinRule }{},
+		&struct{ *CasbinRule }{},
+	} {
+		_, db := newFakeDB(t, "pg")
+		if _, err := NewAdapterWithClient(db, WithModel(model)); err == nil {
+			t.Errorf("got no error for model %T", model)
+		}
+	}
+}
+
+// timedRule is a policy row with timestamps set by the database.
+type timedRule struct {
+	CasbinRule
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+func TestWithModelTimestampsNotMatched(t *testing.T) {
+	stamp := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	a, f := newTestAdapter(t, "pg",
+		WithTimestampColumn("updated_at"),
+		WithModel(&timedRule{CreatedAt: stamp, UpdatedAt: stamp}))
+	where := `WHERE ("ptype" = 'p') AND ("v0" = 'alice') AND ("v1" = 'data1') AND ("v2" = 'read') AND ("v3" = '') AND ("v4" = '') AND ("v5" = '')`
+
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicies("p", "p", [][]string{{"alice", "data1", "read"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	// The stored row has other timestamps than the model, so only
+	// statements matching on the rule alone find it.
+	if got := f.count(where); got != 3 {
+		t.Errorf("got statements %q, want 3 matching the rule alone", f.queries(""))
+	}
+	for _, statement := range append(f.queries("DELETE"), f.queries("UPDATE")...) {
+		for _, column := range []string{"created_at", "updated_at", "2024-03-01"} {
+			if strings.Contains(statement, column) {
+				t.Errorf("got %q, want no match on %s", statement, column)
+			}
+		}
+	}
+}
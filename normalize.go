@@ -0,0 +1,123 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// NormalizeRows repairs rows whose V columns have gaps, e.g. an empty v0
+// before a set v1, by moving the set values to the first columns in order
+// and clearing the rest. Such rows would otherwise be loaded truncated at the
+// first empty column. It returns the number of rows changed.
+func (a *Adapter) NormalizeRows(ctx context.Context) (_ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "NormalizeRows")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		n = 0
+		for _, table := range tables {
+			var lines []*CasbinRule
+			if err := a.newSelect(ctx, tx, &lines, table).OrderExpr("? ASC", bun.Ident(a.idColumnName())).Scan(ctx); err != nil {
+				return err
+			}
+			for _, line := range lines {
+				changed, err := a.normalizeRow(ctx, tx, table, line)
+				if err != nil {
+					return err
+				}
+				if changed {
+					n++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// normalizeRow compacts the V columns of the stored line, reporting whether
+// it had gaps.
+func (a *Adapter) normalizeRow(ctx context.Context, tx bun.Tx, table string, line *CasbinRule) (bool, error) {
+	if err := a.decodeRule(line); err != nil {
+		return false, err
+	}
+	fields := line.fields()[:a.fieldCount()]
+	// before keeps the stored values, gaps included, for the outbox.
+	before := make([]string, 0, len(fields))
+	for _, field := range fields {
+		before = append(before, *field)
+	}
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if *field != "" {
+			values = append(values, *field)
+		}
+	}
+	changed := false
+	for i, field := range fields {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		if *field != value {
+			*field = value
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	// Values are encoded for the column they end up in.
+	if err := a.encodeRule(line); err != nil {
+		return false, err
+	}
+	q := tx.NewUpdate().
+		Conn(a.queryConn(ctx, tx)).
+		Model((*CasbinRule)(nil)).
+		ModelTableExpr(table).
+		Where("? = ?", bun.Ident(a.idColumnName()), line.Id)
+	for i, field := range fields {
+		q.Set("? = ?", bun.Ident(fmt.Sprintf("v%d", i)), a.storedValue(*field))
+	}
+	if _, err := q.Exec(ctx); err != nil {
+		return false, err
+	}
+	for len(before) > 0 && before[len(before)-1] == "" {
+		before = before[:len(before)-1]
+	}
+	if err := a.publish(ctx, tx, "RemovePolicy", line.Ptype, before); err != nil {
+		return false, err
+	}
+	return true, a.publish(ctx, tx, "AddPolicy", line.Ptype, values)
+}
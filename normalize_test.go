@@ -0,0 +1,55 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRows(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("SELECT").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "p", V1: "bob", V3: "data2", V5: "write"},
+		&CasbinRule{Id: 3, Ptype: "g", V0: "carol", V2: "admin"},
+	)
+	n, err := a.NormalizeRows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d rows changed, want 2", n)
+	}
+	updates := f.queries("UPDATE")
+	want := []string{
+		`SET "v0" = 'bob', "v1" = 'data2', "v2" = 'write', "v3" = '', "v4" = '', "v5" = '', "v6" = '', "v7" = '' WHERE ("id" = 2)`,
+		`SET "v0" = 'carol', "v1" = 'admin', "v2" = '', "v3" = '', "v4" = '', "v5" = '', "v6" = '', "v7" = '' WHERE ("id" = 3)`,
+	}
+	if len(updates) != len(want) {
+		t.Fatalf("got updates %q, want %d", updates, len(want))
+	}
+	for i := range want {
+		if !strings.HasSuffix(updates[i], want[i]) {
+			t.Errorf("got update %q, want %q", updates[i], want[i])
+		}
+	}
+}
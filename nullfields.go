@@ -0,0 +1,73 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// WithNullEmptyFields chooses how the unused V columns of a rule are stored:
+// as NULL when null is true, or as empty strings, the default. Either way
+// they are loaded as empty fields. Storing NULL turns on
+// WithNullSafeMatching, so that removes and updates match the rows whatever
+// representation they were written with, and inserts rows one at a time.
+// The V columns have to be nullable, unlike the ones created by CreateTable.
+func WithNullEmptyFields(null bool) Option {
+	return func(a *Adapter) error {
+		a.nullEmpty = null
+		if null {
+			a.nullSafe = true
+		}
+		return nil
+	}
+}
+
+// storedValue returns the value written for the V column value.
+func (a *Adapter) storedValue(value string) interface{} {
+	if a.nullEmpty && value == "" {
+		return nil
+	}
+	return value
+}
+
+// insertValues returns the values of line for policyColumns as written by
+// inserts.
+func (a *Adapter) insertValues(line *CasbinRule) []interface{} {
+	values := a.policyValues(line)
+	for i := 1; i < len(values); i++ {
+		values[i] = a.storedValue(values[i].(string))
+	}
+	return values
+}
+
+// setNullFields makes q insert NULL instead of the default value for the
+// empty V columns of line, if asked for.
+func (a *Adapter) setNullFields(q *bun.InsertQuery, line *CasbinRule) {
+	if !a.nullEmpty {
+		return
+	}
+	for i, field := range line.fields()[:a.fieldCount()] {
+		if *field == "" {
+			q.Value(fmt.Sprintf("v%d", i), "NULL")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestNullEmptyFields(t *testing.T) {
+	columns := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7"}
+	tests := []struct {
+		name    string
+		null    bool
+		stored  driver.Value
+		insert  string
+		inserts int
+		match   string
+	}{
+		{"empty strings", false, "", "'admin', DEFAULT, DEFAULT", 2, `("v2" = '')`},
+		// Rows with NULLs are inserted one at a time.
+		{"nulls", true, nil, "'admin', NULL, NULL", 3, `(("v2" = '' OR "v2" IS NULL))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, f := newTestAdapter(t, "pg", WithNullEmptyFields(tt.null))
+			if err := a.AddPolicy("g", "g", []string{"alice", "admin"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.AddPolicies("g", "g", [][]string{{"bob", "admin"}, {"carol", "admin"}}); err != nil {
+				t.Fatal(err)
+			}
+			if inserts := f.queries("INSERT"); len(inserts) != tt.inserts || f.count(tt.insert) != tt.inserts {
+				t.Errorf("got inserts %q, want %d with the unused columns as %s", inserts, tt.inserts, tt.insert)
+			}
+
+			row := []driver.Value{int64(1), "g", "alice", "admin"}
+			for len(row) < len(columns) {
+				row = append(row, tt.stored)
+			}
+			f.on("SELECT").returns(columns, row)
+			m := newTestModel(t)
+			if err := a.LoadPolicy(m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetPolicy("g", "g"); len(got) != 1 || strings.Join(got[0], ",") != "alice,admin" {
+				t.Errorf("loaded %q, want the short rule", got)
+			}
+
+			if err := a.RemovePolicy("g", "g", []string{"alice", "admin"}); err != nil {
+				t.Fatal(err)
+			}
+			if deletes := f.queries("DELETE"); len(deletes) != 1 || !strings.Contains(deletes[0], tt.match) {
+				t.Errorf("got deletes %q, want them to match %s", deletes, tt.match)
+			}
+		})
+	}
+}
+
+func TestNullEmptyFieldsUpdate(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithNullEmptyFields(true))
+	if err := a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count(`SET "v2" = NULL`); n != 1 {
+		t.Errorf("got updates %q, want v2 set to NULL", f.queries("UPDATE"))
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// OpLogger receives one call per adapter operation, with the name of the
+// public method, the rows it loaded or wrote, how long it took and the
+// error it returned.
+type OpLogger func(ctx context.Context, op string, rows int64, d time.Duration, err error)
+
+// WithOpLogger calls fn at the end of each public method of the adapter, with
+// the ctx it was called with, for structured logs correlated with a request.
+// Loads report the rows added to the model, writes the rows inserted, updated
+// or deleted.
+func WithOpLogger(fn OpLogger) Option {
+	return func(a *Adapter) error {
+		a.opLogger = fn
+		return nil
+	}
+}
+
+type opStatsKey struct{}
+
+// opCountedKey marks the context of a query whose rows were counted by
+// countExec, so that opCounter does not count them again.
+type opCountedKey struct{}
+
+// opStats accumulates the rows of the operation of an adapter.
+type opStats struct {
+	adapter *Adapter
+	rows    int64
+}
+
+// startOp starts the operation op, if an OpLogger or WithQueryComments is
+// set and ctx is not already within an operation. The returned function
+// reports it; it is meant to be deferred before mapError, so that it sees the
+// mapped error.
+func (a *Adapter) startOp(ctx context.Context, op string) (context.Context, func(err *error)) {
+	if (a.opLogger == nil && !a.queryComments) || ctx.Value(opNameKey{}) != nil {
+		return ctx, func(*error) {}
+	}
+	opCtx := context.WithValue(ctx, opNameKey{}, op)
+	if a.opLogger == nil {
+		return opCtx, func(*error) {}
+	}
+	start := time.Now()
+	stats := &opStats{adapter: a}
+	opCtx = context.WithValue(opCtx, opStatsKey{}, stats)
+	return opCtx, func(err *error) {
+		a.opLogger(ctx, op, atomic.LoadInt64(&stats.rows), time.Since(start), *err)
+	}
+}
+
+// addOpRows adds n rows to the operation of ctx, if any.
+func (a *Adapter) addOpRows(ctx context.Context, n int64) {
+	if stats, ok := ctx.Value(opStatsKey{}).(*opStats); ok && stats != nil && stats.adapter == a {
+		atomic.AddInt64(&stats.rows, n)
+	}
+}
+
+// countExec adds the rows affected by res to the operation of ctx. bun
+// reports no result to query hooks for the statements of its query builders,
+// so their rows are counted here.
+func (a *Adapter) countExec(ctx context.Context, res sql.Result) {
+	if res == nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	a.addOpRows(ctx, n)
+	if counted, ok := ctx.Value(opCountedKey{}).(*bool); ok {
+		*counted = true
+	}
+}
+
+// uncounted returns ctx for queries whose rows are not part of the count of
+// the operation, such as the copies made by WithAuditTable.
+func uncounted(ctx context.Context) context.Context {
+	if ctx.Value(opStatsKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, opStatsKey{}, (*opStats)(nil))
+}
+
+// opCounter is the query hook counting the rows written by the operations
+// of an adapter.
+type opCounter struct {
+	adapter *Adapter
+}
+
+var _ bun.QueryHook = (*opCounter)(nil)
+
+func (h *opCounter) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return context.WithValue(ctx, opCountedKey{}, new(bool))
+}
+
+func (h *opCounter) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Err != nil || event.Result == nil {
+		return
+	}
+	if counted, ok := ctx.Value(opCountedKey{}).(*bool); ok && *counted {
+		return
+	}
+	// Raw queries always report SELECT as their operation, so look at the
+	// statement itself.
+	switch statementVerb(event.Query) {
+	case "INSERT", "UPDATE", "DELETE":
+		if n, err := event.Result.RowsAffected(); err == nil {
+			h.adapter.addOpRows(ctx, n)
+		}
+	}
+}
+
+// statementVerb returns the first keyword of query, in upper case.
+func statementVerb(query string) string {
+	verb := strings.TrimLeft(query, " \t\r\n")
+	if i := strings.IndexAny(verb, " \t\r\n"); i > 0 {
+		verb = verb[:i]
+	}
+	return strings.ToUpper(verb)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+)
+
+type requestKey struct{}
+
+// opEntry is a call of an OpLogger.
+type opEntry struct {
+	request interface{}
+	op      string
+	rows    int64
+	d       time.Duration
+	err     error
+}
+
+// opLog records the calls of its logger.
+type opLog struct {
+	mu      sync.Mutex
+	entries []opEntry
+}
+
+func (l *opLog) logger() OpLogger {
+	return func(ctx context.Context, op string, rows int64, d time.Duration, err error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.entries = append(l.entries, opEntry{ctx.Value(requestKey{}), op, rows, d, err})
+	}
+}
+
+func TestOpLogger(t *testing.T) {
+	log := new(opLog)
+	a, f := newTestAdapter(t, "mysql", WithOpLogger(log.logger()))
+	f.on("SELECT").returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 2, Ptype: "g", V0: "alice", V1: "admin"},
+	)
+	f.on("'mallory'").fails(mysqlError(1062, "Duplicate entry"))
+	f.on("INSERT").affects(1)
+
+	ctx := context.WithValue(context.Background(), requestKey{}, "req-1")
+	if err := a.LoadPolicyCtx(ctx, newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicyCtx(ctx, "p", "p", []string{"bob", "data2", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicyCtx(ctx, "p", "p", []string{"mallory", "data2", "write"}); err == nil {
+		t.Fatal("got no error")
+	}
+
+	if len(log.entries) != 3 {
+		t.Fatalf("got entries %+v, want one per operation", log.entries)
+	}
+	for i, want := range []opEntry{
+		{request: "req-1", op: "LoadPolicy", rows: 2},
+		{request: "req-1", op: "AddPolicy", rows: 1},
+		{request: "req-1", op: "AddPolicy", rows: 0},
+	} {
+		got := log.entries[i]
+		if got.request != want.request || got.op != want.op || got.rows != want.rows {
+			t.Errorf("entry %d: got %+v, want %+v", i, got, want)
+		}
+		if got.d <= 0 {
+			t.Errorf("entry %d: got duration %s, want the elapsed time", i, got.d)
+		}
+	}
+	if log.entries[0].err != nil || log.entries[1].err != nil || log.entries[2].err == nil {
+		t.Errorf("got entries %+v, want the error of the failed add only", log.entries)
+	}
+}
+
+func TestOpLoggerCountsWritesOnce(t *testing.T) {
+	log := new(opLog)
+	a, f := newTestAdapter(t, "pg", WithOpLogger(log.logger()), WithExecInterceptor(func(next func() (sql.Result, error)) (sql.Result, error) {
+		return next()
+	}))
+	// Inserts with RETURNING are run as queries, deletes as statements.
+	f.on("INSERT").returns([]string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+	f.on("DELETE").affects(2)
+
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data1", "read"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.entries) != 2 || log.entries[0].rows != 2 || log.entries[1].rows != 2 {
+		t.Errorf("got entries %+v, want 2 rows added and 2 removed", log.entries)
+	}
+}
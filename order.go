@@ -0,0 +1,73 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"sort"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// WithInsertOrder sets the order in which SavePolicy inserts the rules of each
+// ptype, e.g. when foreign keys link the rules of one ptype to another.
+// Ptypes not listed follow in the default order: the policy ptypes, then the
+// role ptypes, each sorted by name.
+func WithInsertOrder(ptypes []string) Option {
+	return func(a *Adapter) error {
+		a.insertOrder = ptypes
+		return nil
+	}
+}
+
+// modelLines returns the rows of all rules of model, in insert order.
+func (a *Adapter) modelLines(model model.Model) ([]*CasbinRule, error) {
+	var order []string
+	sections := make(map[string]string)
+	for _, sec := range []string{"p", "g"} {
+		ptypes := make([]string, 0, len(model[sec]))
+		for ptype := range model[sec] {
+			ptypes = append(ptypes, ptype)
+			sections[ptype] = sec
+		}
+		sort.Strings(ptypes)
+		order = append(order, ptypes...)
+	}
+	if len(a.insertOrder) > 0 {
+		order = append(append([]string(nil), a.insertOrder...), order...)
+	}
+
+	lines := make([]*CasbinRule, 0)
+	done := make(map[string]bool)
+	for _, ptype := range order {
+		sec, ok := sections[ptype]
+		if !ok || done[ptype] {
+			continue
+		}
+		done[ptype] = true
+		for _, policy := range model[sec][ptype].Policy {
+			line, err := a.savePolicyLine(ptype, policy)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// WithOutbox makes every method writing policy rows also insert one event row
+// per change into the table name, within the same transaction as the change.
+// The outbox table is in the schema of the table storing the rules of the
+// event's ptype, see WithPtypeTable. It needs op, ptype and payload columns;
+// any other columns, such as an id or a creation time, must have defaults.
+//
+// marshal encodes each change into the payload column. op is one of
+// "AddPolicy", "RemovePolicy", "RemoveFilteredPolicy", "UpsertPolicy" and
+// "SavePolicy":
+//   - an update is recorded as a RemovePolicy event for the old rule
+//     followed by an AddPolicy event for the new one, as are the rows
+//     NormalizeRows repairs;
+//   - for RemoveFilteredPolicy, rule holds the field values behind
+//     fieldIndex empty strings; ReplaceAllForPtype and SavePartial record
+//     the rows they replace this way, before AddPolicy events for the
+//     rules they write;
+//   - UpsertPolicy carries the upserted rule, which may have replaced a
+//     stored rule conflicting with it;
+//   - SavePolicy means all rules were replaced, so ptype is empty and rule
+//     is nil. Clear, and ImportCSV and RestorePolicies when replacing,
+//     record it too, followed by AddPolicy events for the rows they write.
+func WithOutbox(name string, marshal func(op, ptype string, rule []string) ([]byte, error)) Option {
+	return func(a *Adapter) error {
+		if err := validateIdentifier(name); err != nil {
+			return err
+		}
+		if marshal == nil {
+			return errors.New("outbox marshal function must not be nil")
+		}
+		a.outboxTable = name
+		a.outboxMarshal = marshal
+		return nil
+	}
+}
+
+// publish inserts an event row for the change into the outbox table, if
+// there is one.
+func (a *Adapter) publish(ctx context.Context, tx bun.Tx, op, ptype string, rule []string) error {
+	if a.outboxTable == "" {
+		return nil
+	}
+	payload, err := a.outboxMarshal(op, ptype, rule)
+	if err != nil {
+		return errors.Wrapf(err, "marshaling %s event", op)
+	}
+	schema, err := a.ptypeSchema(ctx, ptype)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewRaw("INSERT INTO ? (op, ptype, payload) VALUES (?, ?, ?)",
+		bun.Safe(a.joinTableName(schema, a.outboxTable)),
+		op, ptype, payload).Conn(a.queryConn(ctx, tx)).Exec(uncounted(ctx))
+	return err
+}
+
+// publishRules publishes one event per rule of ptype.
+func (a *Adapter) publishRules(ctx context.Context, tx bun.Tx, op, ptype string, rules [][]string) error {
+	if a.outboxTable == "" {
+		return nil
+	}
+	for _, rule := range rules {
+		if err := a.publish(ctx, tx, op, ptype, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishLines publishes one event per line, with its values as loaded.
+func (a *Adapter) publishLines(ctx context.Context, tx bun.Tx, op string, lines []*CasbinRule) error {
+	if a.outboxTable == "" {
+		return nil
+	}
+	for _, line := range lines {
+		if err := a.publish(ctx, tx, op, line.Ptype, CasbinRuleToStringArray(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishFiltered publishes the RemoveFilteredPolicy event of a filtered
+// remove.
+func (a *Adapter) publishFiltered(ctx context.Context, tx bun.Tx, ptype string, fieldIndex int, fieldValues []string) error {
+	if a.outboxTable == "" {
+		return nil
+	}
+	return a.publish(ctx, tx, "RemoveFilteredPolicy", ptype, append(make([]string, fieldIndex), fieldValues...))
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// marshalEvent encodes an outbox event as the JSON array of its rule.
+func marshalEvent(op, ptype string, rule []string) ([]byte, error) {
+	return json.Marshal(rule)
+}
+
+// outboxInsert returns the insert of an event into the outbox table of pg.
+func outboxInsert(op, ptype string, rule []string) string {
+	payload, _ := marshalEvent(op, ptype, rule)
+	return fmt.Sprintf(`INSERT INTO public.casbin_outbox (op, ptype, payload) VALUES ('%s', '%s', '\x%x')`, op, ptype, payload)
+}
+
+func TestOutbox(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithOutbox("casbin_outbox", marshalEvent))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "INSERT", "INSERT", "COMMIT"}
+	if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got statements %q, want %q", got, want)
+	}
+	if got := f.queries("casbin_outbox"); len(got) != 1 || got[0] != outboxInsert("AddPolicy", "p", []string{"alice", "data1", "read"}) {
+		t.Errorf("got outbox inserts %q, want the AddPolicy event", got)
+	}
+
+	f.reset()
+	if err := a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data2", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	events := []string{
+		outboxInsert("RemovePolicy", "p", []string{"alice", "data1", "read"}),
+		outboxInsert("AddPolicy", "p", []string{"alice", "data2", "read"}),
+	}
+	if got := f.queries("casbin_outbox"); !reflect.DeepEqual(got, events) {
+		t.Errorf("got outbox inserts %q, want %q", got, events)
+	}
+
+	f.reset()
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.queries("casbin_outbox"); len(got) != 1 || got[0] != outboxInsert("RemoveFilteredPolicy", "p", []string{"", "data2"}) {
+		t.Errorf("got outbox inserts %q, want the RemoveFilteredPolicy event", got)
+	}
+}
+
+func TestOutboxRolledBack(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithOutbox("casbin_outbox", marshalEvent))
+	f.on("INSERT INTO public.casbin_rule").fails(pgError("23505", "duplicate key value violates unique constraint"))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err == nil {
+		t.Fatal("AddPolicy() succeeded with a failing insert")
+	}
+	// The event was inserted in the transaction that is rolled back.
+	want := []string{"BEGIN", "INSERT", "INSERT", "ROLLBACK"}
+	if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+	if n := f.count("casbin_outbox"); n != 1 {
+		t.Errorf("got %d outbox inserts, want 1", n)
+	}
+}
+
+func TestOutboxMarshalError(t *testing.T) {
+	marshal := func(op, ptype string, rule []string) ([]byte, error) {
+		return nil, errors.New("unsupported rule")
+	}
+	a, f := newTestAdapter(t, "pg", WithOutbox("casbin_outbox", marshal))
+	err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	if err == nil || !strings.Contains(err.Error(), "marshaling AddPolicy event") {
+		t.Fatalf("AddPolicy() = %v, want the marshal error", err)
+	}
+	if n := f.count("INSERT"); n != 0 {
+		t.Errorf("got %d inserts, want none", n)
+	}
+}
+
+func TestOutboxWritePaths(t *testing.T) {
+	alice := []string{"alice", "data1", "read"}
+	tests := []struct {
+		name  string
+		setup func(f *fakeDB)
+		call  func(a *Adapter) error
+		want  []string
+	}{
+		{
+			name: "UpsertPolicy",
+			call: func(a *Adapter) error {
+				return a.UpsertPolicy(context.Background(), "p", alice)
+			},
+			want: []string{outboxInsert("UpsertPolicy", "p", alice)},
+		},
+		{
+			name: "ReplaceAllForPtype",
+			call: func(a *Adapter) error {
+				return a.ReplaceAllForPtype(context.Background(), "p", [][]string{alice})
+			},
+			want: []string{
+				outboxInsert("RemoveFilteredPolicy", "p", []string{}),
+				outboxInsert("AddPolicy", "p", alice),
+			},
+		},
+		{
+			name: "ImportCSV",
+			call: func(a *Adapter) error {
+				return a.ImportCSV(context.Background(), strings.NewReader("p, alice, data1, read\ng, alice, admin\n"), true)
+			},
+			want: []string{
+				outboxInsert("SavePolicy", "", nil),
+				outboxInsert("AddPolicy", "p", alice),
+				outboxInsert("AddPolicy", "g", []string{"alice", "admin"}),
+			},
+		},
+		{
+			name: "ImportCSVAppend",
+			call: func(a *Adapter) error {
+				return a.ImportCSV(context.Background(), strings.NewReader("p, alice, data1, read\n"), false)
+			},
+			want: []string{outboxInsert("AddPolicy", "p", alice)},
+		},
+		{
+			name: "RestorePolicies",
+			call: func(a *Adapter) error {
+				return a.RestorePolicies(context.Background(), []*CasbinRule{
+					{Id: 3, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+					{Ptype: "g", V0: "alice", V1: "admin"},
+				}, true)
+			},
+			want: []string{
+				outboxInsert("SavePolicy", "", nil),
+				outboxInsert("AddPolicy", "p", alice),
+				outboxInsert("AddPolicy", "g", []string{"alice", "admin"}),
+			},
+		},
+		{
+			name: "Clear",
+			call: func(a *Adapter) error {
+				return a.Clear(context.Background())
+			},
+			want: []string{outboxInsert("SavePolicy", "", nil)},
+		},
+		{
+			name: "NormalizeRows",
+			setup: func(f *fakeDB) {
+				f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V2: "read"})
+			},
+			call: func(a *Adapter) error {
+				_, err := a.NormalizeRows(context.Background())
+				return err
+			},
+			want: []string{
+				outboxInsert("RemovePolicy", "p", []string{"alice", "", "read"}),
+				outboxInsert("AddPolicy", "p", []string{"alice", "read"}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, f := newTestAdapter(t, "pg", WithOutbox("casbin_outbox", marshalEvent))
+			if test.setup != nil {
+				test.setup(f)
+			}
+			if err := test.call(a); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.queries("casbin_outbox"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got outbox inserts %q, want %q", got, test.want)
+			}
+			// The events are written in the transaction of the change.
+			if kinds := statementKinds(f); kinds[0] != "BEGIN" || kinds[len(kinds)-1] != "COMMIT" {
+				t.Errorf("got statements %q, want one transaction", kinds)
+			}
+		})
+	}
+}
+
+func TestOutboxPtypeTableSchema(t *testing.T) {
+	a, f := newTestAdapter(t, "pg",
+		WithPtypeTable("g", "auth", "casbin_roles"),
+		WithOutbox("casbin_outbox", marshalEvent),
+		WithAuditTable("casbin_audit"))
+	if err := a.AddPolicy("g", "g", []string{"alice", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicy("g", "g", []string{"alice", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("INSERT INTO auth.casbin_outbox "); n != 2 {
+		t.Errorf("got outbox inserts %q, want the g events in the auth schema", f.queries("casbin_outbox"))
+	}
+	if n := f.count("INSERT INTO public.casbin_outbox "); n != 1 {
+		t.Errorf("got outbox inserts %q, want the p event in the public schema", f.queries("casbin_outbox"))
+	}
+	if n := f.count("INSERT INTO auth.casbin_audit "); n != 1 {
+		t.Errorf("got audit inserts %q, want the removed role in the auth schema", f.queries("casbin_audit"))
+	}
+}
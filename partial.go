@@ -0,0 +1,114 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"sort"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pkg/errors"
+
+	"github.com/uptrace/bun"
+)
+
+// SavePartial replaces only the stored policy rules whose fields starting at
+// fieldIndex equal fieldValues with the matching rules of the model, in one
+// transaction. Rules that don't match the filter, and rules of ptypes the
+// model does not define, are left untouched, which allows syncing e.g. the
+// rules of a single subject without a full SavePolicy. fieldValues must not
+// be empty. The replaced rows are audited and published like those of
+// RemoveFilteredPolicy, and the written rules like those of AddPolicy.
+func (a *Adapter) SavePartial(model model.Model, fieldIndex int, fieldValues ...string) error {
+	return a.SavePartialCtx(a.ctx, model, fieldIndex, fieldValues...)
+}
+
+// SavePartialCtx is SavePartial with context.
+func (a *Adapter) SavePartialCtx(ctx context.Context, model model.Model, fieldIndex int, fieldValues ...string) (err error) {
+	ctx, endOp := a.startOp(ctx, "SavePartial")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	if len(fieldValues) == 0 {
+		return errors.New("SavePartial needs field values to select the rules to replace")
+	}
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		for _, sec := range []string{"p", "g"} {
+			ptypes := make([]string, 0, len(model[sec]))
+			for ptype := range model[sec] {
+				ptypes = append(ptypes, ptype)
+			}
+			sort.Strings(ptypes)
+			for _, ptype := range ptypes {
+				if err := a.savePartialPtype(ctx, tx, ptype, model[sec][ptype].Policy, fieldIndex, fieldValues); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// savePartialPtype replaces the stored rules of ptype matching the filter
+// with the matching rules of policies.
+func (a *Adapter) savePartialPtype(ctx context.Context, tx bun.Tx, ptype string, policies [][]string, fieldIndex int, fieldValues []string) error {
+	tables, err := a.ptypeTableNames(ctx, ptype)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if _, err := a.removeFiltered(ctx, tx, table, ptype, fieldIndex, fieldValues); err != nil {
+			return err
+		}
+	}
+	if err := a.publishFiltered(ctx, tx, ptype, fieldIndex, fieldValues); err != nil {
+		return err
+	}
+	matching := make([][]string, 0)
+	for _, policy := range policies {
+		if matchFieldValues(policy, fieldIndex, fieldValues) {
+			matching = append(matching, policy)
+		}
+	}
+	if err := a.createPolicies(ctx, tx, ptype, matching); err != nil {
+		return err
+	}
+	return a.publishRules(ctx, tx, "AddPolicy", ptype, matching)
+}
+
+// matchFieldValues reports whether rule matches the filter the same way
+// whereFieldValues does in SQL.
+func matchFieldValues(rule []string, fieldIndex int, fieldValues []string) bool {
+	for i, value := range fieldValues {
+		j := fieldIndex + i
+		if j < 0 || j >= maxFields {
+			continue
+		}
+		field := ""
+		if j < len(rule) {
+			field = rule[j]
+		}
+		if field != value {
+			return false
+		}
+	}
+	return true
+}
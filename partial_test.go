@@ -0,0 +1,144 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+func TestSavePartial(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	m := newTestModel(t,
+		[]string{"p", "alice", "data1", "read"},
+		[]string{"p", "alice", "data2", "write"},
+		[]string{"p", "bob", "data1", "read"},
+		[]string{"g", "alice", "admin"},
+	)
+	if err := a.SavePartial(m, 0, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("TRUNCATE"); n != 0 {
+		t.Errorf("got %d truncates, want none", n)
+	}
+	want := []string{
+		`DELETE FROM public.casbin_rule WHERE ("ptype" = 'p') AND ("v0" = 'alice')`,
+		`DELETE FROM public.casbin_rule WHERE ("ptype" = 'g') AND ("v0" = 'alice')`,
+	}
+	if got := f.queries("DELETE"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got deletes %q, want %q", got, want)
+	}
+	inserts := strings.Join(f.queries("INSERT"), "\n")
+	for _, value := range []string{"'data1', 'read'", "'data2', 'write'", "'admin'"} {
+		if !strings.Contains(inserts, "'alice', "+value) {
+			t.Errorf("the rule of alice with %s is not inserted:\n%s", value, inserts)
+		}
+	}
+	if strings.Contains(inserts, "'bob'") {
+		t.Errorf("the rules of bob are inserted:\n%s", inserts)
+	}
+	if queries := f.queries(""); queries[0] != "BEGIN" || queries[len(queries)-1] != "COMMIT" {
+		t.Errorf("got statements %q, want a single transaction", queries)
+	}
+}
+
+func TestSavePartialPtypesOfModel(t *testing.T) {
+	// The model has no roles, so the stored g rules of alice stay.
+	m, err := model.NewModelFromString(`
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = r.sub == p.sub && r.obj == p.obj && r.act == p.act
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	a, f := newTestAdapter(t, "pg", WithPtypeTable("p", "auth", "casbin_policy"))
+	if err := a.SavePartial(m, 0, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`DELETE FROM auth.casbin_policy WHERE ("ptype" = 'p') AND ("v0" = 'alice')`}
+	if got := f.queries("DELETE"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got deletes %q, want %q", got, want)
+	}
+}
+
+func TestSavePartialAuditOutbox(t *testing.T) {
+	a, f := newTestAdapter(t, "pg",
+		WithAuditTable("casbin_rule_audit"),
+		WithOutbox("casbin_outbox", marshalEvent))
+	m := newTestModel(t, []string{"p", "alice", "data1", "read"})
+	if err := a.SavePartial(m, 0, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	audits := f.queries("INSERT INTO public.casbin_rule_audit")
+	if len(audits) != 2 || !strings.HasSuffix(audits[0], `WHERE ("ptype" = 'p') AND ("v0" = 'alice')`) {
+		t.Errorf("got audit inserts %q, want the replaced rows of p and g", audits)
+	}
+	events := []string{
+		outboxInsert("RemoveFilteredPolicy", "p", []string{"alice"}),
+		outboxInsert("AddPolicy", "p", []string{"alice", "data1", "read"}),
+		outboxInsert("RemoveFilteredPolicy", "g", []string{"alice"}),
+	}
+	if got := f.queries("casbin_outbox"); !reflect.DeepEqual(got, events) {
+		t.Errorf("got outbox inserts %q, want %q", got, events)
+	}
+}
+
+func TestSavePartialWithoutFieldValues(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	if err := a.SavePartial(newTestModel(t), 0); err == nil {
+		t.Error("got no error saving without field values")
+	}
+	if n := len(f.queries("")); n != 0 {
+		t.Errorf("got statements %q, want none", f.queries(""))
+	}
+}
+
+func TestMatchFieldValues(t *testing.T) {
+	tests := []struct {
+		rule        []string
+		fieldIndex  int
+		fieldValues []string
+		want        bool
+	}{
+		{[]string{"alice", "data1", "read"}, 0, []string{"alice"}, true},
+		{[]string{"alice", "data1", "read"}, 1, []string{"data1", "read"}, true},
+		{[]string{"alice", "data1", "read"}, 1, []string{"data2"}, false},
+		{[]string{"alice", "data1"}, 2, []string{""}, true},
+		{[]string{"alice", "data1"}, 2, []string{"read"}, false},
+	}
+	for _, test := range tests {
+		if got := matchFieldValues(test.rule, test.fieldIndex, test.fieldValues); got != test.want {
+			t.Errorf("matchFieldValues(%q, %d, %q) = %v, want %v", test.rule, test.fieldIndex, test.fieldValues, got, test.want)
+		}
+	}
+}
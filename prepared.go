@@ -0,0 +1,225 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// maxCachedStmts is the number of prepared statements kept by
+// WithPreparedStatements; the least recently used one is closed beyond it.
+const maxCachedStmts = 64
+
+// WithPreparedStatements makes AddPolicy and RemovePolicy run prepared
+// statements that are cached per table and rule shape instead of building the
+// SQL on every call. The cache keeps the 64 most recently used statements,
+// so that tables of WithTableNameFunc don't grow it without bound, and the
+// rest are released by Close.
+//
+// Prepared statements bypass bun's query builder, so they are not seen by
+// bun query hooks such as the one installed by WithLogger.
+func WithPreparedStatements() Option {
+	return func(a *Adapter) error {
+		a.stmts = newStmtCache(maxCachedStmts)
+		return nil
+	}
+}
+
+// stmtCache is an LRU cache of prepared statements.
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	stmts map[string]*list.Element
+	order *list.List // of *cachedStmt, most recently used first
+}
+
+// cachedStmt is a statement of stmtCache. An evicted statement is closed
+// once its last user releases it.
+type cachedStmt struct {
+	key     string
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{size: size, stmts: make(map[string]*list.Element), order: list.New()}
+}
+
+// get returns the statement cached under key, preparing query on a miss, and
+// the function releasing it, to be called once the statement is no longer
+// used.
+func (c *stmtCache) get(ctx context.Context, db *bun.DB, key, query string) (*sql.Stmt, func(), error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.stmts[key]; ok {
+		c.order.MoveToFront(e)
+		return c.use(e.Value.(*cachedStmt))
+	}
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	cs := &cachedStmt{key: key, stmt: stmt}
+	c.stmts[key] = c.order.PushFront(cs)
+	for c.order.Len() > c.size {
+		old := c.order.Remove(c.order.Back()).(*cachedStmt)
+		delete(c.stmts, old.key)
+		old.evicted = true
+		if old.users == 0 {
+			_ = old.stmt.Close()
+		}
+	}
+	return c.use(cs)
+}
+
+// use marks cs as used until the returned function is called. c.mu is held.
+func (c *stmtCache) use(cs *cachedStmt) (*sql.Stmt, func(), error) {
+	cs.users++
+	release := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		cs.users--
+		if cs.evicted && cs.users == 0 {
+			_ = cs.stmt.Close()
+		}
+	}
+	return cs.stmt, release, nil
+}
+
+func (c *stmtCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	for key, e := range c.stmts {
+		if err := e.Value.(*cachedStmt).stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.stmts, key)
+	}
+	c.order.Init()
+	return firstErr
+}
+
+// placeholders returns n bind placeholders in the syntax of the dialect.
+func (a *Adapter) placeholders(n int) []string {
+	ps := make([]string, n)
+	for i := range ps {
+		switch a.client.Dialect().Name() {
+		case dialect.PG:
+			ps[i] = fmt.Sprintf("$%d", i+1)
+		case dialect.MSSQL:
+			ps[i] = fmt.Sprintf("@p%d", i+1)
+		default:
+			ps[i] = "?"
+		}
+	}
+	return ps
+}
+
+// execPreparedInsert inserts line with a cached INSERT statement.
+func (a *Adapter) execPreparedInsert(ctx context.Context, tx bun.Tx, table string, line *CasbinRule) error {
+	columns := a.insertColumns()
+	args := a.insertValues(line)
+	if a.spannerDialect != nil {
+		args = append([]interface{}{line.Id}, args...)
+	}
+	if a.extraColumn != "" {
+		columns = append(columns, a.extraColumn)
+		args = append(args, line.Extra)
+	}
+
+	keyword, suffix := a.ignoreDuplicatesSQL()
+	query := fmt.Sprintf("INSERT%s INTO %s (%s) VALUES (%s)%s",
+		keyword, table, a.identList(columns), strings.Join(a.placeholders(len(args)), ", "), suffix)
+	stmt, release, err := a.stmts.get(ctx, a.client, "insert:"+table, query)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return a.execCounted(ctx, tx.StmtContext(ctx, stmt), args)
+}
+
+// execPreparedRemove deletes the rows matching line with a cached DELETE
+// statement, comparing the same columns as wherePolicy.
+func (a *Adapter) execPreparedRemove(ctx context.Context, tx bun.Tx, table string, line *CasbinRule) error {
+	columns := a.policyColumns()
+	args := a.policyValues(line)
+	key := "remove:" + table
+
+	ps := a.placeholders(len(args))
+	conds := make([]string, len(columns))
+	bound := make([]interface{}, 0, len(args))
+	for i, column := range columns {
+		ident := a.identList([]string{column})
+		// v6 and v7, the columns after ptype and v0 to v5, may be NULL
+		// in rows written before they were added, see wherePolicy.
+		if (a.nullSafe || i > 6) && i > 0 && args[i] == "" {
+			// The NULL-safe comparison has no bind argument, so the
+			// statement depends on which values are empty.
+			conds[i] = fmt.Sprintf("(%s = '' OR %s IS NULL)", ident, ident)
+			key += ":" + column
+			continue
+		}
+		if i > 0 {
+			// The V columns, not ptype, are compared with the collation.
+			ident += a.collateSQL()
+		}
+		conds[i] = ident + " = " + ps[len(bound)]
+		bound = append(bound, args[i])
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", table, strings.Join(conds, " AND "))
+	stmt, release, err := a.stmts.get(ctx, a.client, key, query)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return a.execCounted(ctx, tx.StmtContext(ctx, stmt), bound)
+}
+
+// execCounted runs stmt, adding the rows it affected to the operation of ctx,
+// since prepared statements are not seen by the counting query hook.
+func (a *Adapter) execCounted(ctx context.Context, stmt *sql.Stmt, args []interface{}) error {
+	exec := func() (sql.Result, error) {
+		return stmt.ExecContext(ctx, args...)
+	}
+	var res sql.Result
+	var err error
+	if a.execInterceptor != nil {
+		res, err = a.execInterceptor(exec)
+	} else {
+		res, err = exec()
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		a.addOpRows(ctx, n)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestPreparedStatementsReused(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithPreparedStatements())
+	run := func(n int) {
+		for i := 0; i < n; i++ {
+			rule := []string{fmt.Sprintf("user%d", i), "data1", "read"}
+			if err := a.AddPolicy("p", "p", rule); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.RemovePolicy("p", "p", rule); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.RemovePolicy("p", "p", append(rule, "", "", "", "eu")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// database/sql prepares a statement once per connection it runs on.
+	run(1)
+	if n, _ := f.prepared("INSERT"); n == 0 {
+		t.Fatal("the insert is not prepared")
+	}
+	f.reset()
+
+	run(10)
+	if n, _ := f.prepared(""); n != 0 {
+		t.Errorf("prepared %d statements again, want them reused", n)
+	}
+	if n := f.count("INSERT"); n != 10 {
+		t.Errorf("got %d inserts, want 10", n)
+	}
+	deletes := f.args("DELETE")
+	if len(deletes) != 20 || len(deletes[0]) != 7 || len(deletes[1]) != 8 || deletes[0][1] != "user0" {
+		t.Errorf("got delete arguments %q, want the rules bound", deletes)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, open := f.prepared(""); open != 0 {
+		t.Errorf("%d statements left open by Close, want none", open)
+	}
+}
+
+func TestPreparedStatementsBounded(t *testing.T) {
+	// Every tenant table gets its own statement; the cache keeps the most
+	// recent ones and closes the others.
+	tables := 4 * maxCachedStmts
+	a, f := newTestAdapter(t, "pg", WithPreparedStatements(), WithTableNameFunc(func(ctx context.Context) (string, string, error) {
+		return "public", ctx.Value(tenantKey{}).(string), nil
+	}))
+	for i := 0; i < tables; i++ {
+		ctx := context.WithValue(context.Background(), tenantKey{}, fmt.Sprintf("tenant%d", i))
+		if err := a.AddPolicyCtx(ctx, "p", "p", []string{"alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := f.prepared("INSERT"); n < tables {
+		t.Errorf("prepared %d inserts, want one per table", n)
+	}
+	// database/sql prepares a statement once per connection it runs on;
+	// the transactions and the cache use two.
+	if _, open := f.prepared(""); open > 2*maxCachedStmts {
+		t.Errorf("%d statements open, want at most %d", open, 2*maxCachedStmts)
+	}
+	if n := len(a.stmts.stmts); n != maxCachedStmts {
+		t.Errorf("cached %d statements, want %d", n, maxCachedStmts)
+	}
+}
+
+func TestStmtCacheEvictsAfterRelease(t *testing.T) {
+	f, db := newFakeDB(t, "pg")
+	c := newStmtCache(1)
+	ctx := context.Background()
+	_, release, err := c.get(ctx, db, "a", "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseB, err := c.get(ctx, db, "b", "SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseB()
+	// a is evicted by b but still in use.
+	if _, open := f.prepared(""); open != 2 {
+		t.Errorf("%d statements open, want the evicted one kept while used", open)
+	}
+	release()
+	if _, open := f.prepared(""); open != 1 {
+		t.Errorf("%d statements open after the release, want 1", open)
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, open := f.prepared(""); open != 0 {
+		t.Errorf("%d statements left open by close, want none", open)
+	}
+}
+
+func BenchmarkAddPolicy(b *testing.B) {
+	for _, prepared := range []bool{false, true} {
+		b.Run(fmt.Sprintf("prepared=%v", prepared), func(b *testing.B) {
+			var options []Option
+			if prepared {
+				options = append(options, WithPreparedStatements())
+			}
+			a, _ := newTestAdapter(b, "pg", options...)
+			rule := []string{"alice", "data1", "read"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := a.AddPolicy("p", "p", rule); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"sort"
+
+	"github.com/uptrace/bun"
+)
+
+// WithPtypeTable stores the rules of ptype in schema.table instead of the
+// table set by WithTableName, e.g. to keep large role relations apart. Every
+// operation on ptype goes to that table, and the operations on the whole
+// policy, such as LoadPolicy and SavePolicy, cover all configured tables.
+func WithPtypeTable(ptype, schema, table string) Option {
+	return func(a *Adapter) error {
+		if err := a.validateTableName(schema, table); err != nil {
+			return err
+		}
+		if a.ptypeTables == nil {
+			a.ptypeTables = make(map[string][2]string)
+		}
+		a.ptypeTables[ptype] = [2]string{schema, table}
+		return nil
+	}
+}
+
+// getPtypeTableName returns the table storing the rules of ptype.
+func (a *Adapter) getPtypeTableName(ctx context.Context, ptype string) (string, error) {
+	if name, ok := a.ptypeTables[ptype]; ok {
+		return a.joinTableName(name[0], name[1]), nil
+	}
+	return a.getFullTableName(ctx)
+}
+
+// ptypeSchema returns the schema of the table storing the rules of ptype,
+// which the audit and outbox tables of their changes are in.
+func (a *Adapter) ptypeSchema(ctx context.Context, ptype string) (string, error) {
+	if name, ok := a.ptypeTables[ptype]; ok {
+		return name[0], nil
+	}
+	schema, _, err := a.resolveTable(ctx)
+	return schema, err
+}
+
+// getTableNames returns the default table followed by the distinct tables
+// set by WithPtypeTable, or the shards of WithHashSharding.
+func (a *Adapter) getTableNames(ctx context.Context) ([]string, error) {
+	if a.sharded() {
+		return a.shardTableNames(), nil
+	}
+	table, err := a.getFullTableName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.withPtypeTables(table), nil
+}
+
+// getReadTableNames is getTableNames for loads, starting with the read table.
+func (a *Adapter) getReadTableNames(ctx context.Context) ([]string, error) {
+	if a.sharded() {
+		return a.shardTableNames(), nil
+	}
+	table, err := a.getReadTableName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.withPtypeTables(table), nil
+}
+
+func (a *Adapter) withPtypeTables(table string) []string {
+	tables := []string{table}
+	seen := map[string]bool{table: true}
+	for _, ptype := range sortedKeys(a.ptypeTables) {
+		name := a.ptypeTables[ptype]
+		t := a.joinTableName(name[0], name[1])
+		if !seen[t] {
+			seen[t] = true
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// insertByTable inserts every line into its table.
+func (a *Adapter) insertByTable(ctx context.Context, tx bun.Tx, lines []*CasbinRule) (int64, error) {
+	order, byTable, err := a.groupByTable(ctx, lines)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, table := range order {
+		var affected int64
+		var err error
+		if a.bulkSave {
+			affected, err = a.bulkInsertRules(ctx, tx, table, byTable[table])
+		} else {
+			affected, err = a.insertRules(ctx, tx, table, byTable[table])
+		}
+		if err != nil {
+			return 0, err
+		}
+		n += affected
+	}
+	return n, nil
+}
+
+// groupByTable groups lines by the table storing them, returning the tables
+// in the order they were first seen.
+func (a *Adapter) groupByTable(ctx context.Context, lines []*CasbinRule) ([]string, map[string][]*CasbinRule, error) {
+	var order []string
+	byTable := make(map[string][]*CasbinRule)
+	for _, line := range lines {
+		table, err := a.lineTableName(ctx, line)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := byTable[table]; !ok {
+			order = append(order, table)
+		}
+		byTable[table] = append(byTable[table], line)
+	}
+	return order, byTable, nil
+}
+
+func sortedKeys(m map[string][2]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
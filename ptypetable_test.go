@@ -0,0 +1,62 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPtypeTable(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithPtypeTable("g", "public", "casbin_grouping"))
+	f.on("FROM public.casbin_rule AS").
+		returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "admin", V1: "data1", V2: "read"})
+	f.on("FROM public.casbin_grouping AS").
+		returnsRules(&CasbinRule{Id: 1, Ptype: "g", V0: "alice", V1: "admin"})
+
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if p, g := m.GetPolicy("p", "p"), m.GetPolicy("g", "g"); len(p) != 1 || len(g) != 1 {
+		t.Errorf("got rules %q and roles %q, want both tables merged", p, g)
+	}
+
+	if err := a.RemovePolicy("g", "g", []string{"alice", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemovePolicy("p", "p", []string{"admin", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	deletes := f.queries("DELETE")
+	if len(deletes) != 2 ||
+		!strings.HasPrefix(deletes[0], "DELETE FROM public.casbin_grouping WHERE") ||
+		!strings.HasPrefix(deletes[1], "DELETE FROM public.casbin_rule WHERE") {
+		t.Errorf("got deletes %q, want each on the table of its ptype", deletes)
+	}
+
+	f.reset()
+	if err := a.AddPolicy("g", "g", []string{"bob", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("INSERT INTO public.casbin_grouping "); n != 1 {
+		t.Errorf("got inserts %q, want the role in casbin_grouping", f.queries("INSERT"))
+	}
+}
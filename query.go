@@ -0,0 +1,258 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// ErrPolicyNotFound is returned when no stored row matches a policy rule.
+var ErrPolicyNotFound = errors.New("policy not found")
+
+// ErrMultipleTables is returned by QueryPolicies when the rows to list are
+// stored in several tables by WithPtypeTable.
+var ErrMultipleTables = errors.New("query spans several tables")
+
+// GetPolicy returns the stored row of the given rule, including its id, or
+// ErrPolicyNotFound.
+func (a *Adapter) GetPolicy(ctx context.Context, ptype string, rule []string) (_ *CasbinRule, err error) {
+	ctx, endOp := a.startOp(ctx, "GetPolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+	table, err := a.ruleTableName(ctx, ptype, rule)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := a.toInstance(ptype, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	line := new(CasbinRule)
+	q := a.newSelect(ctx, a.client, line, table).OrderExpr("? ASC", bun.Ident(a.idColumnName())).Limit(1)
+	a.wherePolicy(q.QueryBuilder(), instance)
+	if err := q.Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPolicyNotFound
+		}
+		return nil, err
+	}
+	if err := a.decodeRule(line); err != nil {
+		return nil, err
+	}
+	a.addOpRows(ctx, 1)
+	return line, nil
+}
+
+// GetPoliciesByField returns the stored rules of ptype whose V column at
+// fieldIndex equals value, e.g. all the permissions of a subject with
+// fieldIndex 0.
+func (a *Adapter) GetPoliciesByField(ctx context.Context, ptype string, fieldIndex int, value string) (_ [][]string, err error) {
+	ctx, endOp := a.startOp(ctx, "GetPoliciesByField")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+	if fieldIndex < 0 || fieldIndex >= a.fieldCount() {
+		return nil, errors.Errorf("field index %d out of range", fieldIndex)
+	}
+	tables, err := a.ptypeTableNames(ctx, ptype)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([][]string, 0)
+	for _, table := range tables {
+		var lines []*CasbinRule
+		q := a.newSelect(ctx, a.client, &lines, table).
+			Where("? = ?", bun.Ident(a.ptypeColumnName()), ptype).
+			OrderExpr("? ASC", bun.Ident(a.idColumnName()))
+		if err := a.whereFieldValues(q.QueryBuilder(), fieldIndex, []string{value}); err != nil {
+			return nil, err
+		}
+		if err := q.Scan(ctx); err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if err := a.decodeRule(line); err != nil {
+				return nil, err
+			}
+			rules = append(rules, CasbinRuleToStringArray(line))
+		}
+		a.addOpRows(ctx, int64(len(lines)))
+	}
+	return rules, nil
+}
+
+// StatsTotal is the key of the total row count in the result of Stats.
+const StatsTotal = "*"
+
+// Stats returns the number of stored rows per ptype, and the total under
+// StatsTotal.
+func (a *Adapter) Stats(ctx context.Context) (_ map[string]int64, err error) {
+	ctx, endOp := a.startOp(ctx, "Stats")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]int64{StatsTotal: 0}
+	for _, table := range tables {
+		var rows []struct {
+			Ptype string `bun:"ptype"`
+			Count int64  `bun:"n"`
+		}
+		err = a.client.NewSelect().
+			Conn(a.queryConn(ctx, a.client)).
+			TableExpr(table).
+			ColumnExpr("? AS ptype", bun.Ident(a.ptypeColumnName())).
+			ColumnExpr("COUNT(*) AS n").
+			GroupExpr("?", bun.Ident(a.ptypeColumnName())).
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			stats[row.Ptype] += row.Count
+			stats[StatsTotal] += row.Count
+		}
+	}
+	return stats, nil
+}
+
+// QueryPolicies returns a page of the stored rows matching f, sorted by
+// orderBy, and the number of matching rows in total, e.g. for admin listings.
+// orderBy is a column among id, ptype and the V columns, optionally followed
+// by ASC or DESC; it defaults to id. A limit of 0 returns all the rows from
+// offset. With WithPtypeTable f must select a single ptype, or it fails with
+// ErrMultipleTables; with WithHashSharding it fails with ErrSharded.
+func (a *Adapter) QueryPolicies(ctx context.Context, f Filter, limit, offset int, orderBy string) (_ []*CasbinRule, _ int64, err error) {
+	ctx, endOp := a.startOp(ctx, "QueryPolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.readContext(ctx)
+	defer cancel()
+	if limit < 0 || offset < 0 {
+		return nil, 0, errors.Errorf("invalid limit %d or offset %d", limit, offset)
+	}
+	column, direction, err := a.orderExpr(orderBy)
+	if err != nil {
+		return nil, 0, err
+	}
+	table, err := a.queryTableName(ctx, f.Ptype)
+	if err != nil {
+		return nil, 0, err
+	}
+	conds, empty, err := a.filterConditions(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var lines []*CasbinRule
+	q := a.newSelect(ctx, a.client, &lines, table)
+	for _, cond := range conds {
+		q.Where(fmt.Sprintf("%s in (?)", cond.column), bun.In(cond.values))
+	}
+	for _, column := range empty {
+		q.Where("(? = '' OR ? IS NULL)", column, column)
+	}
+	q.OrderExpr("? "+direction, bun.Ident(column))
+	// Break ties by id so that pages don't overlap.
+	if column != a.idColumnName() {
+		q.OrderExpr("? ASC", bun.Ident(a.idColumnName()))
+	}
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Offset(offset)
+	}
+	total, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, line := range lines {
+		if err := a.decodeRule(line); err != nil {
+			return nil, 0, err
+		}
+	}
+	a.addOpRows(ctx, int64(len(lines)))
+	return lines, int64(total), nil
+}
+
+// queryTableName returns the table QueryPolicies lists the rows of ptypes
+// from.
+func (a *Adapter) queryTableName(ctx context.Context, ptypes []string) (string, error) {
+	if a.sharded() {
+		return "", ErrSharded
+	}
+	if len(ptypes) == 1 {
+		if _, ok := a.ptypeTables[ptypes[0]]; ok {
+			return a.getPtypeTableName(ctx, ptypes[0])
+		}
+	} else if len(a.ptypeTables) > 0 {
+		return "", ErrMultipleTables
+	}
+	return a.getReadTableName(ctx)
+}
+
+// orderExpr validates orderBy for QueryPolicies and returns the column and
+// direction to sort by.
+func (a *Adapter) orderExpr(orderBy string) (column, direction string, err error) {
+	fields := strings.Fields(orderBy)
+	if len(fields) == 0 {
+		return a.idColumnName(), "ASC", nil
+	}
+	column, direction = strings.ToLower(fields[0]), "ASC"
+	if len(fields) > 2 {
+		return "", "", errors.Errorf("invalid order %q", orderBy)
+	}
+	if len(fields) == 2 {
+		direction = strings.ToUpper(fields[1])
+		if direction != "ASC" && direction != "DESC" {
+			return "", "", errors.Errorf("invalid order direction %q", fields[1])
+		}
+	}
+	allowed := column == "id" || column == "ptype"
+	for i := 0; i < a.fieldCount() && !allowed; i++ {
+		allowed = column == fmt.Sprintf("v%d", i)
+	}
+	if !allowed {
+		return "", "", errors.Errorf("invalid order column %q", fields[0])
+	}
+	switch column {
+	case "id":
+		column = a.idColumnName()
+	case "ptype":
+		column = a.ptypeColumnName()
+	}
+	return column, direction, nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestQueryPoliciesOrder(t *testing.T) {
+	tests := []struct {
+		orderBy string
+		want    string
+	}{
+		{"", `ORDER BY "id" ASC LIMIT 10`},
+		{"id DESC", `ORDER BY "id" DESC LIMIT 10`},
+		{"v1 desc", `ORDER BY "v1" DESC, "id" ASC LIMIT 10`},
+		{"Ptype", `ORDER BY "ptype" ASC, "id" ASC LIMIT 10`},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, "pg")
+		f.on("count(*)").returns([]string{"count"}, []driver.Value{int64(0)})
+		if _, _, err := a.QueryPolicies(context.Background(), Filter{}, 10, 0, test.orderBy); err != nil {
+			t.Fatal(err)
+		}
+		queries := f.queries("ORDER BY")
+		if len(queries) != 1 || !strings.HasSuffix(queries[0], test.want) {
+			t.Errorf("QueryPolicies ordered by %q ran %q, want a query ending with %q", test.orderBy, queries, test.want)
+		}
+	}
+}
+
+func TestQueryPoliciesInvalidOrder(t *testing.T) {
+	for _, orderBy := range []string{"v9", "v1 sideways", "v1 ASC id", "v1; DROP TABLE casbin_rule"} {
+		a, f := newTestAdapter(t, "pg", WithColumnCount(3))
+		if _, _, err := a.QueryPolicies(context.Background(), Filter{}, 10, 0, orderBy); err == nil {
+			t.Errorf("QueryPolicies ordered by %q succeeded, want an error", orderBy)
+		}
+		if n := f.count("SELECT"); n != 0 {
+			t.Errorf("QueryPolicies ordered by %q ran %d queries, want none", orderBy, n)
+		}
+	}
+}
+
+func TestQueryPoliciesPtypeTable(t *testing.T) {
+	tests := []struct {
+		ptypes []string
+		table  string
+		err    error
+	}{
+		{[]string{"g"}, "public.casbin_role", nil},
+		{[]string{"p"}, "public.casbin_rule", nil},
+		{nil, "", ErrMultipleTables},
+		{[]string{"p", "g"}, "", ErrMultipleTables},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, "pg", WithPtypeTable("g", "public", "casbin_role"))
+		f.on("count(*)").returns([]string{"count"}, []driver.Value{int64(0)})
+		_, _, err := a.QueryPolicies(context.Background(), Filter{Ptype: test.ptypes}, 10, 0, "")
+		if !errors.Is(err, test.err) {
+			t.Errorf("QueryPolicies of %q failed with %v, want %v", test.ptypes, err, test.err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		if n := f.count("FROM " + test.table + " AS"); n != 2 {
+			t.Errorf("QueryPolicies of %q ran %d queries on %s, want 2", test.ptypes, n, test.table)
+		}
+	}
+}
+
+func TestQueryPoliciesSharded(t *testing.T) {
+	a, _ := newTestAdapter(t, "pg", WithHashSharding(2, 0, func(shard int) string {
+		return fmt.Sprintf("casbin_rule_%d", shard)
+	}))
+	_, _, err := a.QueryPolicies(context.Background(), Filter{Ptype: []string{"p"}}, 10, 0, "")
+	if !errors.Is(err, ErrSharded) {
+		t.Errorf("got error %v, want ErrSharded", err)
+	}
+}
+
+func TestGetPolicy(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on(`WHERE ("ptype" = 'p') AND ("v0" = 'alice') AND ("v1" = 'data1') AND ("v2" = 'read') AND ("v3" = '')`).
+		returnsRules(&CasbinRule{Id: 7, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+
+	line, err := a.GetPolicy(context.Background(), "p", []string{"alice", "data1", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.Id != 7 || line.Ptype != "p" || line.V0 != "alice" || line.V2 != "read" {
+		t.Errorf("got %+v, want the stored row 7", line)
+	}
+	if got := f.queries("SELECT"); len(got) != 1 || !strings.HasSuffix(got[0], `ORDER BY "id" ASC LIMIT 1`) {
+		t.Errorf("got selects %q, want one of the first matching row", got)
+	}
+
+	for _, rule := range [][]string{{"alice", "data1"}, {"alice", "data1", "read", "extra"}, {"bob", "data1", "read"}} {
+		if _, err := a.GetPolicy(context.Background(), "p", rule); !errors.Is(err, ErrPolicyNotFound) {
+			t.Errorf("GetPolicy(%q) got error %v, want ErrPolicyNotFound", rule, err)
+		}
+	}
+}
+
+func TestGetPoliciesByField(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on(`WHERE ("ptype" = 'p') AND ("v0" = 'alice')`).returnsRules(
+		&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		&CasbinRule{Id: 4, Ptype: "p", V0: "alice", V1: "data2", V2: "write"},
+	)
+
+	rules, err := a.GetPoliciesByField(context.Background(), "p", 0, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"alice", "data1", "read"}, {"alice", "data2", "write"}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("got rules %q, want %q", rules, want)
+	}
+	if got := f.queries("SELECT"); len(got) != 1 || !strings.HasSuffix(got[0], `ORDER BY "id" ASC`) {
+		t.Errorf("got selects %q, want one ordered by id", got)
+	}
+
+	rules, err = a.GetPoliciesByField(context.Background(), "p", 0, "bob")
+	if err != nil || rules == nil || len(rules) != 0 {
+		t.Errorf("got %q, %v for a subject without rules, want an empty slice", rules, err)
+	}
+	for _, index := range []int{-1, maxFields} {
+		if _, err := a.GetPoliciesByField(context.Background(), "p", index, "alice"); err == nil {
+			t.Errorf("got no error for field index %d", index)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("COUNT(*) AS n").returns([]string{"ptype", "n"},
+		[]driver.Value{"p", int64(3)},
+		[]driver.Value{"g", int64(2)},
+		[]driver.Value{"g2", int64(1)},
+	)
+	stats, err := a.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"p": 3, "g": 2, "g2": 1, StatsTotal: 6}
+	if fmt.Sprint(stats) != fmt.Sprint(want) {
+		t.Errorf("got stats %v, want %v", stats, want)
+	}
+	if n := f.count(`GROUP BY "ptype"`); n != 1 {
+		t.Errorf("got queries %q, want one grouped by ptype", f.queries(""))
+	}
+}
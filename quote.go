@@ -0,0 +1,56 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithQuotedIdentifiers quotes the schema and table names in every query,
+// for mixed-case names that would otherwise be folded by the database. The
+// quotes are double quotes on Postgres, backticks on MySQL and brackets on
+// SQL Server.
+func WithQuotedIdentifiers() Option {
+	return func(a *Adapter) error {
+		a.quoteIdents = true
+		return nil
+	}
+}
+
+// quoteIdent quotes name in the syntax of the dialect. Identifiers are
+// validated before, so they never contain quote characters.
+func (a *Adapter) quoteIdent(name string) string {
+	switch a.client.Dialect().Name() {
+	case dialect.MySQL:
+		return "`" + name + "`"
+	case dialect.MSSQL:
+		return "[" + name + "]"
+	default:
+		return `"` + name + `"`
+	}
+}
+
+// quoteTableIdent quotes name if WithQuotedIdentifiers is set.
+func (a *Adapter) quoteTableIdent(name string) string {
+	if !a.quoteIdents {
+		return name
+	}
+	return a.quoteIdent(name)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"testing"
+)
+
+func TestQuotedIdentifiers(t *testing.T) {
+	for _, test := range []struct {
+		dialect string
+		table   string
+	}{
+		{dialect: "pg", table: `"Auth"."MyCasbinRules"`},
+		{dialect: "mysql", table: "`Auth`.`MyCasbinRules`"},
+		{dialect: "mssql", table: "[Auth].[MyCasbinRules]"},
+	} {
+		t.Run(test.dialect, func(t *testing.T) {
+			a, f := newTestAdapter(t, test.dialect, WithQuotedIdentifiers(), WithTableName("Auth", "MyCasbinRules"))
+			if err := a.LoadPolicy(newTestModel(t)); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+				t.Fatal(err)
+			}
+			for _, query := range []string{
+				"FROM " + test.table + " AS",
+				"INSERT INTO " + test.table + " (",
+				"DELETE FROM " + test.table + " WHERE",
+			} {
+				if n := f.count(query); n != 1 {
+					t.Errorf("got statements %q, want %q", f.queries(""), query)
+				}
+			}
+		})
+	}
+
+	// Without the option the names are left bare.
+	a, f := newTestAdapter(t, "pg", WithTableName("Auth", "MyCasbinRules"))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("FROM Auth.MyCasbinRules AS"); n != 1 {
+		t.Errorf("got selects %q, want the table unquoted", f.queries("SELECT"))
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// No Postgres driver is imported by the tests; the fake stands in for
+	// one that quotes the data source name in its errors.
+	sql.Register("postgres", fakeDriver{})
+}
+
+func TestRedactDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"postgres://casbin:s3cret@db:5432/casbin?sslmode=disable",
+		"host=db user=casbin password=s3cret dbname=casbin",
+		"host=db user=casbin password='s3cret' dbname=casbin",
+		"sqlserver://casbin:s3cret@db?database=casbin",
+		"server=db;user id=casbin;pwd=s3cret;database=casbin",
+		"casbin:s3cret@tcp(db:3306)/casbin?parseTime=true",
+		"casbin:s3cret@/casbin",
+	} {
+		if got := redactDSN("cannot connect to " + dsn); strings.Contains(got, "s3cret") {
+			t.Errorf("redactDSN(%q) = %q, want the password removed", dsn, got)
+		}
+	}
+	if got := redactDSN("table casbin_rule: 3 rows at 12:30"); got != "table casbin_rule: 3 rows at 12:30" {
+		t.Errorf("got %q, want a message without credentials unchanged", got)
+	}
+}
+
+func TestConnectionErrorRedacted(t *testing.T) {
+	dsn := "postgres://casbin:s3cret@db:5432/casbin?sslmode=disable"
+	_, err := NewAdapter("postgres", dsn, WithConnectRetry(1, time.Millisecond))
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("got error %q, want the password redacted", err)
+	}
+	if !strings.Contains(err.Error(), "casbin:xxxxx@db") {
+		t.Errorf("got error %q, want the redacted DSN kept", err)
+	}
+}
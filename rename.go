@@ -0,0 +1,93 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// RenameTable renames the policy table to newSchema.newTable and makes the
+// adapter use the new name. It must not run concurrently with other calls on
+// the adapter, and cannot be used with WithTableNameFunc or
+// WithTableAlreadyPrefixed.
+func (a *Adapter) RenameTable(ctx context.Context, newSchema, newTable string) (err error) {
+	ctx, endOp := a.startOp(ctx, "RenameTable")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	if a.tableNameFunc != nil || a.tablePrefixed {
+		return errors.New("cannot rename a dynamic or prefixed table")
+	}
+	if err := a.validateTableName(newSchema, newTable); err != nil {
+		return err
+	}
+	oldSchema, oldTable := a.schemaName, a.tableName
+	oldName := a.joinTableName(oldSchema, oldTable)
+	err = a.withTx(ctx, func(tx bun.Tx) error {
+		var queries []*bun.RawQuery
+		switch a.client.Dialect().Name() {
+		case dialect.MySQL:
+			queries = append(queries, tx.NewRaw("RENAME TABLE ? TO ?",
+				bun.Safe(oldName), bun.Safe(a.joinTableName(newSchema, newTable))))
+		case dialect.MSSQL:
+			name := oldName
+			if newTable != oldTable {
+				queries = append(queries, tx.NewRaw("EXEC sp_rename ?, ?", oldName, newTable))
+				name = a.joinTableName(oldSchema, newTable)
+			}
+			if newSchema != oldSchema {
+				queries = append(queries, tx.NewRaw("ALTER SCHEMA ? TRANSFER ?",
+					bun.Safe(a.quoteTableIdent(schemaOrDefault(newSchema, "dbo"))), bun.Safe(name)))
+			}
+		default:
+			name := oldName
+			if newTable != oldTable {
+				queries = append(queries, tx.NewRaw("ALTER TABLE ? RENAME TO ?",
+					bun.Safe(oldName), bun.Safe(a.quoteTableIdent(newTable))))
+				name = a.joinTableName(oldSchema, newTable)
+			}
+			if newSchema != oldSchema {
+				queries = append(queries, tx.NewRaw("ALTER TABLE ? SET SCHEMA ?",
+					bun.Safe(name), bun.Safe(a.quoteTableIdent(schemaOrDefault(newSchema, "public")))))
+			}
+		}
+		for _, q := range queries {
+			if _, err := q.Conn(a.queryConn(ctx, tx)).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	a.schemaName, a.tableName = newSchema, newTable
+	return nil
+}
+
+func schemaOrDefault(schema, def string) string {
+	if schema == "" {
+		return def
+	}
+	return schema
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRenameTable(t *testing.T) {
+	ctx := context.Background()
+	a, f := newTestAdapter(t, "pg")
+	if err := a.CreateTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RenameTable(ctx, "auth", "casbin_rule_v2"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ALTER TABLE public.casbin_rule RENAME TO casbin_rule_v2",
+		"ALTER TABLE public.casbin_rule_v2 SET SCHEMA auth",
+	}
+	for _, query := range want {
+		if n := f.count(query); n != 1 {
+			t.Errorf("got statements %q, want %q", f.queries("ALTER"), query)
+		}
+	}
+
+	f.reset()
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("FROM auth.casbin_rule_v2"); n != 1 {
+		t.Errorf("got selects %q, want them on the renamed table", f.queries("SELECT"))
+	}
+}
+
+func TestRenameTableMySQL(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql", WithTableName("", "casbin_rule"))
+	if err := a.RenameTable(context.Background(), "", "app_casbin_rule"); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("RENAME TABLE casbin_rule TO app_casbin_rule"); n != 1 {
+		t.Errorf("got statements %q, want a RENAME TABLE", f.queries(""))
+	}
+}
+
+func TestRenameTableFails(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("ALTER TABLE").fails(pgError("42P07", `relation "casbin_rule_v2" already exists`))
+	if err := a.RenameTable(context.Background(), "public", "casbin_rule_v2"); err == nil {
+		t.Fatal("got no error")
+	}
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("FROM public.casbin_rule AS"); n != 1 {
+		t.Errorf("got selects %q, want them on the old table", f.queries("SELECT"))
+	}
+
+	a, _ = newTestAdapter(t, "pg", WithTableAlreadyPrefixed())
+	if err := a.RenameTable(context.Background(), "public", "casbin_rule_v2"); err == nil {
+		t.Error("got no error renaming a prefixed table")
+	}
+}
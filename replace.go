@@ -0,0 +1,56 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// ReplaceAllForPtype replaces the stored rules of ptype with rules in a single
+// transaction, leaving the rules of other ptypes untouched. It is meant for
+// syncing one ptype from an external source, where SavePolicy would rewrite
+// the whole table.
+func (a *Adapter) ReplaceAllForPtype(ctx context.Context, ptype string, rules [][]string) (err error) {
+	ctx, endOp := a.startOp(ctx, "ReplaceAllForPtype")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	tables, err := a.ptypeTableNames(ctx, ptype)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		for _, table := range tables {
+			if _, err := a.removeFiltered(ctx, tx, table, ptype, 0, nil); err != nil {
+				return err
+			}
+		}
+		if err := a.publishFiltered(ctx, tx, ptype, 0, nil); err != nil {
+			return err
+		}
+		if err := a.createPolicies(ctx, tx, ptype, rules); err != nil {
+			return err
+		}
+		return a.publishRules(ctx, tx, "AddPolicy", ptype, rules)
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplaceAllForPtype(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	rules := [][]string{{"alice", "admin"}, {"bob", "reader"}}
+	if err := a.ReplaceAllForPtype(context.Background(), "g", rules); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"BEGIN", "DELETE", "INSERT", "COMMIT"}
+	if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got statements %q, want %q", got, want)
+	}
+	if del := f.queries("DELETE")[0]; !strings.HasSuffix(del, `WHERE ("ptype" = 'g')`) {
+		t.Errorf("got %q, want a delete of the g rules only", del)
+	}
+	insert := f.queries("INSERT")[0]
+	for _, want := range []string{"'g', 'alice', 'admin'", "'g', 'bob', 'reader'"} {
+		if !strings.Contains(insert, want) {
+			t.Errorf("got %q, want it to insert %s", insert, want)
+		}
+	}
+	if n := f.count("'p'"); n != 0 {
+		t.Errorf("got statements %q touching the p rules", f.queries(""))
+	}
+}
+
+func TestReplaceAllForPtypeRollsBack(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("INSERT").fails(pgError("23505", "duplicate key value violates unique constraint"))
+	if err := a.ReplaceAllForPtype(context.Background(), "g", [][]string{{"alice", "admin"}}); err == nil {
+		t.Fatal("ReplaceAllForPtype() succeeded with a failing insert")
+	}
+	want := []string{"BEGIN", "DELETE", "INSERT", "ROLLBACK"}
+	if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+}
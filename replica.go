@@ -0,0 +1,110 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithReplica makes LoadPolicy, LoadFilteredPolicy and LoadPolicySince read
+// from replica. All writes still go to the primary client. The adapter never
+// closes replica.
+func WithReplica(replica *bun.DB) Option {
+	return func(a *Adapter) error {
+		a.replica = replica
+		return nil
+	}
+}
+
+// WithReplicaMaxLag checks the replication lag of the replica set by
+// WithReplica before every load, and loads from the primary instead when the
+// lag exceeds d or cannot be determined.
+//
+// The lag is measured on Postgres only, unless WithReplicaLagFunc is given.
+func WithReplicaMaxLag(d time.Duration) Option {
+	return func(a *Adapter) error {
+		a.replicaMaxLag = d
+		return nil
+	}
+}
+
+// WithReplicaLagFunc replaces the built-in replication lag measurement used by
+// WithReplicaMaxLag.
+func WithReplicaLagFunc(fn func(ctx context.Context, replica *bun.DB) (time.Duration, error)) Option {
+	return func(a *Adapter) error {
+		a.replicaLagFunc = fn
+		return nil
+	}
+}
+
+// readClient returns the client a load reads from. It measures the lag of
+// the replica with WithReplicaMaxLag, so each load calls it once and reads
+// everything from the client returned.
+func (a *Adapter) readClient(ctx context.Context) *bun.DB {
+	if a.replica == nil {
+		return a.client
+	}
+	if a.replicaMaxLag <= 0 {
+		return a.replica
+	}
+	lagFunc := a.replicaLagFunc
+	if lagFunc == nil {
+		lagFunc = replicaLag
+	}
+	lag, err := lagFunc(ctx, a.replica)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Errorf("measuring replica lag, loading from primary: %v", err)
+		}
+		return a.client
+	}
+	if lag > a.replicaMaxLag {
+		if a.logger != nil {
+			a.logger.Debugf("replica lag %s exceeds %s, loading from primary", lag, a.replicaMaxLag)
+		}
+		return a.client
+	}
+	return a.replica
+}
+
+// replicaLag measures how far the replica is behind its primary.
+func replicaLag(ctx context.Context, replica *bun.DB) (time.Duration, error) {
+	switch replica.Dialect().Name() {
+	case dialect.PG:
+		// A replica that has replayed everything it received is up to date
+		// even if the last replayed transaction is old.
+		var seconds float64
+		err := replica.NewRaw(`SELECT CASE
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`).Scan(ctx, &seconds)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	default:
+		return 0, errors.Errorf("replica lag is not supported on %s", replica.Dialect().Name())
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+func TestReplicaMaxLag(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		lag       time.Duration
+		err       error
+		onReplica bool
+	}{
+		{name: "low lag", lag: 100 * time.Millisecond, onReplica: true},
+		{name: "high lag", lag: time.Minute},
+		{name: "unknown lag", err: errors.New("no lag")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			replica, replicaDB := newFakeDB(t, "pg")
+			lagFunc := func(ctx context.Context, db *bun.DB) (time.Duration, error) {
+				if db != replicaDB {
+					t.Error("the lag is not measured on the replica")
+				}
+				return test.lag, test.err
+			}
+			a, primary := newTestAdapter(t, "pg",
+				WithReplica(replicaDB), WithReplicaMaxLag(time.Second), WithReplicaLagFunc(lagFunc))
+
+			if err := a.LoadPolicy(newTestModel(t)); err != nil {
+				t.Fatal(err)
+			}
+			onReplica, onPrimary := replica.count("SELECT"), primary.count("SELECT")
+			if test.onReplica && (onReplica != 1 || onPrimary != 0) {
+				t.Errorf("got %d selects on the replica and %d on the primary, want the replica", onReplica, onPrimary)
+			}
+			if !test.onReplica && (onReplica != 0 || onPrimary != 1) {
+				t.Errorf("got %d selects on the replica and %d on the primary, want the primary", onReplica, onPrimary)
+			}
+		})
+	}
+}
+
+func TestReplicaLag(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		seconds   float64
+		onReplica bool
+	}{
+		{name: "caught up", seconds: 0, onReplica: true},
+		{name: "behind", seconds: 30},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			replica, replicaDB := newFakeDB(t, "pg")
+			replica.on("pg_last_wal_replay_lsn()").returns([]string{"case"}, []driver.Value{test.seconds})
+			a, primary := newTestAdapter(t, "pg", WithReplica(replicaDB), WithReplicaMaxLag(time.Second))
+
+			if err := a.LoadPolicy(newTestModel(t)); err != nil {
+				t.Fatal(err)
+			}
+			if n := replica.count("pg_last_wal_replay_lsn()"); n != 1 {
+				t.Errorf("got %d lag queries, want 1", n)
+			}
+			onReplica := replica.count(`FROM public.casbin_rule`) == 1
+			onPrimary := primary.count(`FROM public.casbin_rule`) == 1
+			if onReplica != test.onReplica || onPrimary == test.onReplica {
+				t.Errorf("loaded from the replica %t and the primary %t, want the replica %t",
+					onReplica, onPrimary, test.onReplica)
+			}
+		})
+	}
+
+	// The lag of other dialects is unknown without WithReplicaLagFunc.
+	replica, replicaDB := newFakeDB(t, "mysql")
+	a, primary := newTestAdapter(t, "mysql", WithReplica(replicaDB), WithReplicaMaxLag(time.Second))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if onReplica, onPrimary := replica.count("SELECT"), primary.count("SELECT"); onReplica != 0 || onPrimary != 1 {
+		t.Errorf("got %d selects on the replica and %d on the primary, want the primary", onReplica, onPrimary)
+	}
+}
+
+func TestReplicaChosenOncePerLoad(t *testing.T) {
+	// The replica falls behind after the first measurement; the load must
+	// still read everything, counting, cursor and rows, from the replica.
+	replica, replicaDB := newFakeDB(t, "pg")
+	replica.on("count(*)").returns([]string{"count"}, []driver.Value{int64(0)})
+	var measured int
+	lagFunc := func(ctx context.Context, db *bun.DB) (time.Duration, error) {
+		measured++
+		if measured > 1 {
+			return time.Minute, nil
+		}
+		return 0, nil
+	}
+	a, primary := newTestAdapter(t, "pg",
+		WithReplica(replicaDB), WithReplicaMaxLag(time.Second), WithReplicaLagFunc(lagFunc),
+		WithPreferServerSideCursor(1000), WithDeadlineFromModelSize(time.Second, time.Second))
+
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if measured != 1 {
+		t.Errorf("measured the lag %d times, want once", measured)
+	}
+	if n := replica.count("count(*)"); n != 1 {
+		t.Errorf("got %d counts on the replica, want 1", n)
+	}
+	if n := replica.count("FETCH FORWARD"); n != 1 {
+		t.Errorf("got %d fetches on the replica, want 1", n)
+	}
+	if got := primary.queries(""); len(got) != 0 {
+		t.Errorf("got statements %q on the primary, want none", got)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// restoreChunkSize bounds the rows of each INSERT of RestorePolicies, so that
+// the bind arguments stay below the 2100 allowed by SQL Server.
+const restoreChunkSize = 100
+
+// RestorePolicies inserts rows, as returned by QueryPolicies, with their
+// ptype and V values, e.g. to restore a backup. Rows with an id keep it, and
+// the sequences generating ids are moved past the restored ids; rows without
+// one get a new id. When replace is true the tables are emptied first; both
+// steps run in one transaction.
+func (a *Adapter) RestorePolicies(ctx context.Context, rows []*CasbinRule, replace bool) (err error) {
+	ctx, endOp := a.startOp(ctx, "RestorePolicies")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+
+	var withIDs, fresh []*CasbinRule
+	for i, row := range rows {
+		if err := a.checkRestoreRow(row); err != nil {
+			return errors.Wrapf(err, "row %d", i)
+		}
+		// Values are encoded for the column they end up in, on a copy so
+		// that rows are left as given.
+		line := *row
+		if err := a.encodeRule(&line); err != nil {
+			return err
+		}
+		if line.Id == 0 && a.spannerDialect != nil {
+			if line.Id, err = newRuleID(); err != nil {
+				return err
+			}
+		}
+		if line.Id == 0 {
+			fresh = append(fresh, &line)
+		} else {
+			withIDs = append(withIDs, &line)
+		}
+	}
+	order, byTable, err := a.groupByTable(ctx, withIDs)
+	if err != nil {
+		return err
+	}
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		if replace {
+			for _, table := range tables {
+				if err := a.truncateTable(ctx, tx, table); err != nil {
+					return err
+				}
+			}
+			if err := a.publish(ctx, tx, "SavePolicy", "", nil); err != nil {
+				return err
+			}
+		}
+		for _, table := range order {
+			if err := a.insertRulesWithIDs(ctx, tx, table, byTable[table]); err != nil {
+				return err
+			}
+		}
+		// Rows without id come last, so that their new ids cannot
+		// collide with the restored ones.
+		if _, err := a.insertByTable(ctx, tx, fresh); err != nil {
+			return err
+		}
+		return a.publishLines(ctx, tx, "AddPolicy", rows)
+	})
+}
+
+// checkRestoreRow reports an error if row cannot be restored as is.
+func (a *Adapter) checkRestoreRow(row *CasbinRule) error {
+	if row == nil {
+		return errors.New("nil rule")
+	}
+	if row.Ptype == "" {
+		return errors.New("rule without ptype")
+	}
+	for i, field := range row.fields() {
+		if err := a.checkFieldIndex(i, *field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRulesWithIDs inserts lines into table with the ids they carry, and
+// moves the sequence of the id column past them.
+func (a *Adapter) insertRulesWithIDs(ctx context.Context, tx bun.Tx, table string, lines []*CasbinRule) (err error) {
+	columns := append([]string{a.idColumnName()}, a.policyColumns()...)
+	if a.extraColumn != "" {
+		columns = append(columns, a.extraColumn)
+	}
+	idents := make([]bun.Ident, len(columns))
+	for i, column := range columns {
+		idents[i] = bun.Ident(column)
+	}
+
+	if a.client.Dialect().Name() == dialect.MSSQL {
+		// SQL Server refuses explicit values for an identity column
+		// unless asked to take them, which lasts for the session.
+		if _, err := tx.NewRaw("SET IDENTITY_INSERT ? ON", bun.Safe(table)).Conn(a.queryConn(ctx, tx)).Exec(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			_, offErr := tx.NewRaw("SET IDENTITY_INSERT ? OFF", bun.Safe(table)).Conn(a.queryConn(ctx, tx)).Exec(ctx)
+			if err == nil {
+				err = offErr
+			}
+		}()
+	}
+	for start := 0; start < len(lines); start += restoreChunkSize {
+		end := start + restoreChunkSize
+		if end > len(lines) {
+			end = len(lines)
+		}
+		args := []interface{}{bun.Safe(table), bun.In(idents)}
+		for _, line := range lines[start:end] {
+			values := append([]interface{}{line.Id}, a.insertValues(line)...)
+			if a.extraColumn != "" {
+				values = append(values, line.Extra)
+			}
+			args = append(args, bun.In(values))
+		}
+		query := "INSERT INTO ? (?) VALUES " + strings.TrimSuffix(strings.Repeat("(?), ", end-start), ", ")
+		if _, err := tx.NewRaw(query, args...).Conn(a.queryConn(ctx, tx)).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return a.resetIDSequence(ctx, tx, table)
+}
+
+// resetIDSequence makes the next generated id of table follow the highest
+// stored one. MySQL, SQLite and SQL Server already do so when explicit ids
+// are inserted; Spanner ids are generated by the adapter.
+func (a *Adapter) resetIDSequence(ctx context.Context, tx bun.Tx, table string) error {
+	if a.spannerDialect != nil || a.client.Dialect().Name() != dialect.PG {
+		return nil
+	}
+	_, err := tx.NewRaw("SELECT setval(pg_get_serial_sequence(?, ?), COALESCE(MAX(?), 0) + 1, false) FROM ?",
+		table, a.idColumnName(), bun.Ident(a.idColumnName()), bun.Safe(table)).Conn(a.queryConn(ctx, tx)).Exec(ctx)
+	return err
+}
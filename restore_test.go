@@ -0,0 +1,153 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// restoreRows returns two rows with ids and one without.
+func restoreRows() []*CasbinRule {
+	return []*CasbinRule{
+		{Id: 42, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		{Id: 7, Ptype: "g", V0: "alice", V1: "admin"},
+		{Ptype: "p", V0: "bob", V1: "data2", V2: "read"},
+	}
+}
+
+func TestRestorePolicies(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    []string
+		ids     []string
+	}{
+		{
+			"pg",
+			[]string{"BEGIN", "TRUNCATE", "INSERT", "SELECT", "INSERT", "COMMIT"},
+			[]string{"(42, 'p', 'alice', 'data1', 'read'", "(7, 'g', 'alice', 'admin'"},
+		},
+		{
+			"mysql",
+			[]string{"BEGIN", "TRUNCATE", "INSERT", "INSERT", "COMMIT"},
+			[]string{"(42, 'p', 'alice', 'data1', 'read'", "(7, 'g', 'alice', 'admin'"},
+		},
+		{
+			"mssql",
+			[]string{"BEGIN", "DELETE", "SET IDENTITY_INSERT public.casbin_rule ON", "INSERT",
+				"SET IDENTITY_INSERT public.casbin_rule OFF", "INSERT", "COMMIT"},
+			[]string{"(42, N'p', N'alice', N'data1', N'read'", "(7, N'g', N'alice', N'admin'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			a, f := newTestAdapter(t, tt.dialect)
+			if err := a.RestorePolicies(context.Background(), restoreRows(), true); err != nil {
+				t.Fatal(err)
+			}
+			if got := statementKinds(f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got statements %q, want %q", got, tt.want)
+			}
+			inserts := f.queries("INSERT INTO ")
+			if len(inserts) != 2 {
+				t.Fatalf("got inserts %q, want 2", inserts)
+			}
+			for _, id := range tt.ids {
+				if !strings.Contains(inserts[0], id) {
+					t.Errorf("got %q, want it to restore %s", inserts[0], id)
+				}
+			}
+			// The row without id is inserted after the restored ids.
+			if !strings.Contains(inserts[1], "'bob'") || strings.Contains(inserts[1], "42") {
+				t.Errorf("got %q, want bob's rule with a generated id", inserts[1])
+			}
+		})
+	}
+}
+
+func TestRestorePoliciesResetsSequence(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithIDColumn("rule_id"))
+	if err := a.RestorePolicies(context.Background(), restoreRows()[:1], false); err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT setval(pg_get_serial_sequence('public.casbin_rule', 'rule_id'), COALESCE(MAX("rule_id"), 0) + 1, false) FROM public.casbin_rule`
+	if selects := f.queries("SELECT"); len(selects) != 1 || selects[0] != want {
+		t.Errorf("got %q, want %q", selects, want)
+	}
+	if n := f.count("TRUNCATE"); n != 0 {
+		t.Errorf("got %d truncates without replace, want none", n)
+	}
+}
+
+func TestRestorePoliciesInvalidRow(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	rows := append(restoreRows(), &CasbinRule{Id: 8, V0: "carol"})
+	err := a.RestorePolicies(context.Background(), rows, true)
+	if err == nil || !strings.Contains(err.Error(), "row 3") {
+		t.Fatalf("RestorePolicies() = %v, want an error on row 3", err)
+	}
+	if queries := f.queries(""); len(queries) != 0 {
+		t.Errorf("got statements %q, want none", queries)
+	}
+}
+
+func TestRestorePoliciesRoundTrip(t *testing.T) {
+	stored := []*CasbinRule{
+		{Id: 3, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		{Id: 5, Ptype: "p", V0: "bob", V1: "data2", V2: "write", V3: "allow", V4: "tenant1", V5: "x", V6: "y", V7: "z"},
+		{Id: 9, Ptype: "g", V0: "alice", V1: "admin", V2: "domain1"},
+	}
+	a, f := newTestAdapter(t, "mysql")
+	f.on("count(*)").returns([]string{"count"}, []driver.Value{int64(len(stored))})
+	f.on("SELECT").returnsRules(stored...)
+	exported, _, err := a.QueryPolicies(context.Background(), Filter{}, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != len(stored) {
+		t.Fatalf("exported %d rows, want %d", len(exported), len(stored))
+	}
+	if err := a.Clear(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f.reset()
+	if err := a.RestorePolicies(context.Background(), exported, true); err != nil {
+		t.Fatal(err)
+	}
+	inserts := f.queries("INSERT INTO ")
+	if len(inserts) != 1 {
+		t.Fatalf("got inserts %q, want 1", inserts)
+	}
+	for _, row := range stored {
+		values := []string{fmt.Sprint(row.Id), "'" + row.Ptype + "'"}
+		for _, field := range row.fields() {
+			values = append(values, "'"+*field+"'")
+		}
+		want := "(" + strings.Join(values, ", ") + ")"
+		if !strings.Contains(inserts[0], want) {
+			t.Errorf("got %q, want it to restore %s", inserts[0], want)
+		}
+	}
+}
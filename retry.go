@@ -0,0 +1,215 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// loadRetryBaseDelay is the first pause between the attempts of
+	// WithLoadRetry.
+	loadRetryBaseDelay = 200 * time.Millisecond
+	// txRetryBaseDelay is the first pause between the attempts of
+	// WithTxRetry.
+	txRetryBaseDelay = 10 * time.Millisecond
+	// retryMaxDelay caps the pause between two attempts.
+	retryMaxDelay = 5 * time.Second
+)
+
+// WithLoadRetry makes LoadPolicy load again, up to attempts times in total,
+// until predicate accepts the loaded model, e.g. to wait for a replica to
+// catch up with a migration at startup. The model is cleared before every
+// new attempt; the last attempt is kept even if predicate rejects it.
+func WithLoadRetry(attempts int, predicate func(model.Model) bool) Option {
+	return func(a *Adapter) error {
+		if attempts < 1 {
+			return errors.Errorf("invalid load attempts %d", attempts)
+		}
+		a.loadRetryAttempts = attempts
+		a.loadRetryPredicate = predicate
+		return nil
+	}
+}
+
+// WithTxRetry runs the transactions of the adapter up to attempts times in
+// total while they fail with a serialization failure or deadlock. The whole
+// transaction is run again, including the function passed to WithTx.
+func WithTxRetry(attempts int) Option {
+	return func(a *Adapter) error {
+		if attempts < 1 {
+			return errors.Errorf("invalid transaction attempts %d", attempts)
+		}
+		a.txRetryAttempts = attempts
+		return nil
+	}
+}
+
+// WithMaxRetriesOnDeadlock runs the transactions of the adapter again, up to
+// n more times, while they fail on MySQL with a deadlock (error 1213) or a
+// lock wait timeout (error 1205). These retries are counted apart from the
+// ones of WithTxRetry.
+func WithMaxRetriesOnDeadlock(n int) Option {
+	return func(a *Adapter) error {
+		if n < 0 {
+			return errors.Errorf("invalid deadlock retries %d", n)
+		}
+		a.deadlockRetries = n
+		return nil
+	}
+}
+
+// WithRetryMaxElapsed stops the retries of WithLoadRetry, WithTxRetry and
+// WithMaxRetriesOnDeadlock once d has elapsed since the first attempt,
+// whatever the number of attempts left.
+func WithRetryMaxElapsed(d time.Duration) Option {
+	return func(a *Adapter) error {
+		a.retryMaxElapsed = d
+		return nil
+	}
+}
+
+// WithConnectRetry makes NewAdapter ping the database once opened, up to
+// attempts times in total with exponential backoff starting at backoff, and
+// fail if it is still unreachable, e.g. when the database starts alongside
+// the application.
+func WithConnectRetry(attempts int, backoff time.Duration) Option {
+	return func(a *Adapter) error {
+		if attempts < 1 {
+			return errors.Errorf("invalid connect attempts %d", attempts)
+		}
+		if backoff <= 0 {
+			return errors.Errorf("invalid connect backoff %s", backoff)
+		}
+		a.connectAttempts = attempts
+		a.connectBackoff = backoff
+		return nil
+	}
+}
+
+// ping waits for the database of client to be reachable, as asked by
+// WithConnectRetry.
+func (a *Adapter) ping(client *bun.DB) error {
+	attempt := 0
+	return a.retry(a.ctx, a.connectAttempts, a.connectBackoff, func() (bool, error) {
+		attempt++
+		err := client.PingContext(a.ctx)
+		if err != nil && a.logger != nil {
+			a.logger.Debugf("database unreachable (attempt %d of %d): %v", attempt, a.connectAttempts, redactError(err))
+		}
+		return err != nil, err
+	})
+}
+
+func (a *Adapter) loadPolicyRetry(ctx context.Context, db *bun.DB, model model.Model) error {
+	attempt := 0
+	return a.retry(ctx, a.loadRetryAttempts, loadRetryBaseDelay, func() (bool, error) {
+		attempt++
+		if attempt > 1 {
+			model.ClearPolicy()
+		}
+		if err := a.loadPolicy(ctx, db, model); err != nil {
+			return false, err
+		}
+		if a.loadRetryPredicate(model) {
+			return false, nil
+		}
+		if a.logger != nil {
+			a.logger.Debugf("loaded policy rejected (attempt %d of %d)", attempt, a.loadRetryAttempts)
+		}
+		return true, nil
+	})
+}
+
+// retry calls fn up to attempts times while it asks to be retried, with
+// exponential backoff and full jitter between the attempts. It returns the
+// error of the last attempt.
+func (a *Adapter) retry(ctx context.Context, attempts int, base time.Duration, fn func() (retry bool, err error)) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		again, err := fn()
+		if !again || attempt >= attempts {
+			return err
+		}
+		delay := backoff(base, attempt)
+		if a.retryMaxElapsed > 0 && time.Since(start)+delay > a.retryMaxElapsed {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				return ctx.Err()
+			}
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+var (
+	jitterMu sync.Mutex
+	jitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// backoff returns a random pause before attempt+1, up to base doubled for
+// every attempt made.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := retryMaxDelay
+	if attempt < 30 && base<<(attempt-1) < retryMaxDelay {
+		d = base << (attempt - 1)
+	}
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return time.Duration(jitter.Int63n(int64(d))) + 1
+}
+
+// isMySQLDeadlock reports whether err is a MySQL deadlock or lock wait
+// timeout.
+func isMySQLDeadlock(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && (myErr.Number == 1213 || myErr.Number == 1205)
+}
+
+// isRetryable reports whether err is a serialization failure or deadlock
+// after which the transaction can be run again.
+func isRetryable(err error) bool {
+	var pgdriverErr interface{ Field(byte) string }
+	var pgxErr interface{ SQLState() string }
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	switch {
+	case errors.As(err, &pgdriverErr):
+		code := pgdriverErr.Field('C')
+		return code == "40001" || code == "40P01"
+	case errors.As(err, &pgxErr):
+		code := pgxErr.SQLState()
+		return code == "40001" || code == "40P01"
+	case errors.As(err, &mssqlErr):
+		return mssqlErr.SQLErrorNumber() == 1205
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pkg/errors"
+)
+
+func TestMaxRetriesOnDeadlock(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql", WithMaxRetriesOnDeadlock(2))
+	f.on("INSERT INTO").fails(mysqlError(1213, "Deadlock found when trying to get lock")).times(2)
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("INSERT INTO"); n != 3 {
+		t.Errorf("got %d inserts, want 3", n)
+	}
+	if n := f.count("ROLLBACK"); n != 2 {
+		t.Errorf("got %d rollbacks, want 2", n)
+	}
+}
+
+func TestMaxRetriesOnDeadlockExhausted(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql", WithMaxRetriesOnDeadlock(1))
+	f.on("INSERT INTO").fails(mysqlError(1205, "Lock wait timeout exceeded"))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := f.count("INSERT INTO"); n != 2 {
+		t.Errorf("got %d inserts, want 2", n)
+	}
+}
+
+func TestMaxRetriesOnDeadlockMessage(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql", WithMaxRetriesOnDeadlock(2))
+	// Only the number of the driver error counts, not a look-alike message.
+	f.on("INSERT INTO").fails(errors.New("value 'Error 1213' rejected by trigger"))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := f.count("INSERT INTO"); n != 1 {
+		t.Errorf("got %d inserts, want 1", n)
+	}
+}
+
+func TestTxRetryWithDeadlockRetries(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTxRetry(2), WithMaxRetriesOnDeadlock(1))
+	f.on("INSERT INTO").fails(pgError("40001", "could not serialize access")).times(1)
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("INSERT INTO"); n != 2 {
+		t.Errorf("got %d inserts, want 2", n)
+	}
+}
+
+func TestLoadRetry(t *testing.T) {
+	hasRules := func(m model.Model) bool { return len(m.GetPolicy("p", "p")) > 0 }
+	a, f := newTestAdapter(t, "pg", WithLoadRetry(3, hasRules))
+	f.on("SELECT").returnsRules().times(2)
+	f.on("SELECT").returnsRules(&CasbinRule{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"})
+
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("SELECT"); n != 3 {
+		t.Errorf("got %d loads, want 3", n)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 {
+		t.Errorf("got rules %q, want the rule of the last load", got)
+	}
+}
+
+func TestLoadRetryExhausted(t *testing.T) {
+	hasRules := func(m model.Model) bool { return len(m.GetPolicy("p", "p")) > 0 }
+	a, f := newTestAdapter(t, "pg", WithLoadRetry(2, hasRules))
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("SELECT"); n != 2 {
+		t.Errorf("got %d loads, want 2", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	for attempt := 1; attempt <= 12; attempt++ {
+		limit := base << (attempt - 1)
+		if limit > retryMaxDelay {
+			limit = retryMaxDelay
+		}
+		var longest time.Duration
+		for i := 0; i < 200; i++ {
+			d := backoff(base, attempt)
+			if d <= 0 || d > limit {
+				t.Fatalf("attempt %d: got backoff %s, want up to %s", attempt, d, limit)
+			}
+			if d > longest {
+				longest = d
+			}
+		}
+		// With full jitter the longest of 200 pauses is close to the limit.
+		if longest < limit/2 {
+			t.Errorf("attempt %d: got pauses up to %s, want them to grow to %s", attempt, longest, limit)
+		}
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTxRetry(1000), WithRetryMaxElapsed(100*time.Millisecond))
+	f.on("INSERT INTO").fails(pgError("40001", "could not serialize access"))
+	start := time.Now()
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err == nil {
+		t.Fatal("got no error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retried for %s, want to stop at the elapsed cap", elapsed)
+	}
+	if n := f.count("INSERT INTO"); n < 2 || n >= 1000 {
+		t.Errorf("got %d attempts, want retries stopped by the elapsed cap", n)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTxRetry(1000))
+	f.on("INSERT INTO").fails(pgError("40001", "could not serialize access"))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := a.AddPolicyCtx(ctx, "p", "p", []string{"alice", "data1", "read"}); err == nil {
+		t.Fatal("got no error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retried for %s, want to stop when the context is done", elapsed)
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	f, _ := newFakeDB(t, "pg")
+	// The database accepts connections from the third attempt on.
+	f.on("PING").fails(errors.New("dial tcp 10.0.0.5:5432: connect: connection refused")).times(2)
+	a, err := NewAdapter("postgres", f.dsn, WithConnectRetry(5, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if n := f.count("PING"); n != 3 {
+		t.Errorf("got %d pings, want 3", n)
+	}
+}
+
+func TestConnectRetryExhausted(t *testing.T) {
+	f, _ := newFakeDB(t, "pg")
+	f.on("PING").fails(errors.New("dial tcp 10.0.0.5:5432: connect: connection refused"))
+	if _, err := NewAdapter("postgres", f.dsn, WithConnectRetry(3, time.Millisecond)); err == nil {
+		t.Fatal("got no error for an unreachable database")
+	}
+	if n := f.count("PING"); n != 3 {
+		t.Errorf("got %d pings, want 3", n)
+	}
+}
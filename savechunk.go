@@ -0,0 +1,80 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// WithSaveChunkTransactions makes SavePolicy write the rules in transactions
+// of at most rows rows each, to keep locks and WAL small on huge saves. The
+// first transaction empties the tables.
+//
+// This gives up the atomicity of SavePolicy: readers may see the tables
+// empty or partially saved, and if a transaction fails the rules of the
+// earlier ones stay saved while the others are missing. The error then tells
+// from which row the save failed.
+func WithSaveChunkTransactions(rows int) Option {
+	return func(a *Adapter) error {
+		if rows < 1 {
+			return errors.Errorf("invalid save chunk size %d", rows)
+		}
+		a.saveChunkRows = rows
+		return nil
+	}
+}
+
+// savePolicyChunked empties tables and inserts lines in transactions of
+// WithSaveChunkTransactions rows, and returns the number of rows written.
+func (a *Adapter) savePolicyChunked(ctx context.Context, tables []string, lines []*CasbinRule) (int64, error) {
+	var n int64
+	for start := 0; start == 0 || start < len(lines); start += a.saveChunkRows {
+		end := start + a.saveChunkRows
+		if end > len(lines) {
+			end = len(lines)
+		}
+		var affected int64
+		err := a.withTx(ctx, func(tx bun.Tx) error {
+			return a.withoutForeignKeyChecks(ctx, tx, func() error {
+				if start == 0 {
+					for _, table := range tables {
+						if err := a.truncateTable(ctx, tx, table); err != nil {
+							return err
+						}
+					}
+					if err := a.publish(ctx, tx, "SavePolicy", "", nil); err != nil {
+						return err
+					}
+				}
+				var err error
+				affected, err = a.insertByTable(ctx, tx, lines[start:end])
+				return err
+			})
+		})
+		if err != nil {
+			return n, errors.Wrapf(err, "saving policy from row %d", start)
+		}
+		n += affected
+	}
+	return n, nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// insertedUser matches the subjects of the rules of an INSERT.
+var insertedUser = regexp.MustCompile(`'p', '(user\d+)'`)
+
+func TestSaveChunkTransactions(t *testing.T) {
+	rules := make([][]string, 10000)
+	for i := range rules {
+		rules[i] = []string{"p", fmt.Sprintf("user%d", i), "data1", "read"}
+	}
+	m := newTestModel(t, rules...)
+	a, f := newTestAdapter(t, "mysql", WithSaveChunkTransactions(1000))
+	f.on("INSERT").affects(1000)
+	n, err := a.SavePolicyN(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10000 {
+		t.Errorf("got %d rows saved, want 10000", n)
+	}
+
+	want := []string{"BEGIN", "TRUNCATE", "INSERT", "COMMIT"}
+	for i := 1; i < 10; i++ {
+		want = append(want, "BEGIN", "INSERT", "COMMIT")
+	}
+	if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+	inserted := make(map[string]int)
+	for _, insert := range f.queries("INSERT") {
+		for _, match := range insertedUser.FindAllStringSubmatch(insert, -1) {
+			inserted[match[1]]++
+		}
+	}
+	for _, rule := range rules {
+		if n := inserted[rule[1]]; n != 1 {
+			t.Fatalf("%s inserted %d times, want once", rule[1], n)
+		}
+	}
+}
+
+func TestSaveChunkTransactionsFailure(t *testing.T) {
+	rules := make([][]string, 50)
+	for i := range rules {
+		rules[i] = []string{"p", fmt.Sprintf("user%d", i), "data1", "read"}
+	}
+	a, f := newTestAdapter(t, "mysql", WithSaveChunkTransactions(10))
+	f.on("INSERT").times(3)
+	f.on("INSERT").fails(mysqlError(1205, "Lock wait timeout exceeded"))
+	err := a.SavePolicy(newTestModel(t, rules...))
+	if err == nil || !strings.Contains(err.Error(), "from row 30") {
+		t.Fatalf("SavePolicy() = %v, want it to fail from row 30", err)
+	}
+	// The first three chunks stay committed.
+	if commits := f.count("COMMIT"); commits != 3 || f.count("ROLLBACK") != 1 {
+		t.Errorf("got statements %q, want 3 commits and a rollback", statementKinds(f))
+	}
+}
+
+func TestSaveChunkTransactionsEmpty(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql", WithSaveChunkTransactions(1000))
+	if err := a.SavePolicy(newTestModel(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "TRUNCATE", "COMMIT"}
+	if got := statementKinds(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+}
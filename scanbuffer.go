@@ -0,0 +1,68 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// ScanBufferCount makes WithScanBufferSize size the buffer of LoadPolicy by
+// counting the rows first.
+const ScanBufferCount = -1
+
+// WithScanBufferSize makes LoadPolicy scan the rows into a buffer allocated
+// for rows rules up front, instead of growing it while scanning, which saves
+// the copies and garbage of the intermediate buffers on large loads. rows is
+// a hint; loads of more rows still succeed. With ScanBufferCount the buffer
+// is sized by a COUNT query run before the load.
+func WithScanBufferSize(rows int) Option {
+	return func(a *Adapter) error {
+		if rows < 1 && rows != ScanBufferCount {
+			return errors.Errorf("invalid scan buffer size %d", rows)
+		}
+		a.scanBufferRows = rows
+		return nil
+	}
+}
+
+// scanBuffer returns the buffer LoadPolicy scans the rows of tables into.
+func (a *Adapter) scanBuffer(ctx context.Context, db bun.IDB, tables []string) ([]*CasbinRule, error) {
+	rows := a.scanBufferRows
+	if rows == ScanBufferCount {
+		rows = 0
+		for _, table := range tables {
+			n, err := a.newSelect(ctx, db, (*CasbinRule)(nil), table).Count(ctx)
+			if err != nil {
+				return nil, err
+			}
+			rows += n
+		}
+	}
+	if a.maxLoadRows > 0 && rows > a.maxLoadRows+1 {
+		rows = a.maxLoadRows + 1
+	}
+	if rows <= 0 {
+		return nil, nil
+	}
+	return make([]*CasbinRule, 0, rows), nil
+}
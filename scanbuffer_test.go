@@ -0,0 +1,127 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// manyRules returns n distinct policy rules.
+func manyRules(n int) []*CasbinRule {
+	rules := make([]*CasbinRule, n)
+	for i := range rules {
+		rules[i] = &CasbinRule{Id: int64(i + 1), Ptype: "p", V0: fmt.Sprintf("user%d", i), V1: "data1", V2: "read"}
+	}
+	return rules
+}
+
+// newScanBufferAdapter returns an adapter loading rules, with the scan
+// buffer sized by rows, none if 0.
+func newScanBufferAdapter(tb testing.TB, rows int, rules []*CasbinRule) (*Adapter, *fakeDB) {
+	var options []Option
+	if rows != 0 {
+		options = append(options, WithScanBufferSize(rows))
+	}
+	a, f := newTestAdapter(tb, "pg", options...)
+	f.on("count(*)").returns([]string{"count"}, []driver.Value{int64(len(rules))})
+	f.on("SELECT").returnsRules(rules...)
+	return a, f
+}
+
+func TestScanBufferSize(t *testing.T) {
+	rules := manyRules(50)
+	for _, rows := range []int{0, 10, 50, 100, ScanBufferCount} {
+		t.Run(fmt.Sprint(rows), func(t *testing.T) {
+			a, f := newScanBufferAdapter(t, rows, rules)
+			m := newTestModel(t)
+			if err := a.LoadPolicy(m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetPolicy("p", "p"); len(got) != 50 || got[49][0] != "user49" {
+				t.Errorf("got %d policies, want 50", len(got))
+			}
+			want := 0
+			if rows == ScanBufferCount {
+				want = 1
+			}
+			if n := f.count("count(*)"); n != want {
+				t.Errorf("got %d count queries, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestScanBufferSizeInvalid(t *testing.T) {
+	for _, rows := range []int{0, -2} {
+		_, db := newFakeDB(t, "pg")
+		if _, err := NewAdapterWithClient(db, WithScanBufferSize(rows)); err == nil {
+			t.Errorf("got no error for size %d", rows)
+		}
+	}
+}
+
+// loadAllocs returns the bytes allocated by one LoadPolicy of rules, with
+// the scan buffer sized by rows.
+func loadAllocs(t *testing.T, rows int, rules []*CasbinRule) uint64 {
+	a, _ := newScanBufferAdapter(t, rows, rules)
+	m := newTestModel(t)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestScanBufferSizeAllocs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("loads 100k rows")
+	}
+	rules := manyRules(100000)
+	without := loadAllocs(t, 0, rules)
+	with := loadAllocs(t, len(rules), rules)
+	t.Logf("%d bytes allocated without a hint, %d with", without, with)
+	// Growing the buffer to 100k pointers allocates about twice its final
+	// 800kB along the way.
+	if without < with+800<<10 {
+		t.Errorf("got %d bytes allocated with a hint, want at least 800kB less than %d", with, without)
+	}
+}
+
+func BenchmarkLoadPolicy(b *testing.B) {
+	rules := manyRules(100000)
+	for _, rows := range []int{0, len(rules), ScanBufferCount} {
+		b.Run(fmt.Sprintf("buffer=%d", rows), func(b *testing.B) {
+			a, _ := newScanBufferAdapter(b, rows, rules)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := a.LoadPolicy(newTestModel(b)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
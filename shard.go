@@ -0,0 +1,130 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// ErrSharded is returned by the methods that work on a single table when
+// WithHashSharding spreads the rules over several.
+var ErrSharded = errors.New("not supported with hash sharding")
+
+// WithHashSharding spreads the rules over shards tables, named by tableFunc
+// for shards 0 to shards-1. A rule is stored in the shard given by the hash
+// of its value at keyFieldIndex, e.g. 0 for the subject, so that adds,
+// removes and updates of a rule only touch its shard, while loads, saves and
+// filtered removes cover all shards. It cannot be combined with
+// WithPtypeTable.
+func WithHashSharding(shards int, keyFieldIndex int, tableFunc func(shard int) string) Option {
+	return func(a *Adapter) error {
+		if shards < 1 {
+			return errors.Errorf("invalid shard count %d", shards)
+		}
+		if keyFieldIndex < 0 || keyFieldIndex >= maxFields {
+			return errors.Errorf("invalid shard key field index %d", keyFieldIndex)
+		}
+		tables := make([]string, shards)
+		for i := range tables {
+			tables[i] = tableFunc(i)
+			if err := validateQualifiedName(tables[i]); err != nil {
+				return err
+			}
+		}
+		a.shardTables = tables
+		a.shardKeyIndex = keyFieldIndex
+		return nil
+	}
+}
+
+// sharded reports whether WithHashSharding is set.
+func (a *Adapter) sharded() bool {
+	return len(a.shardTables) > 0
+}
+
+// shardTableNames returns the tables of all shards.
+func (a *Adapter) shardTableNames() []string {
+	tables := make([]string, len(a.shardTables))
+	for i, table := range a.shardTables {
+		tables[i] = a.joinTableName("", table)
+	}
+	return tables
+}
+
+// lineTableName returns the table storing line: its shard, or else the table
+// of its ptype.
+func (a *Adapter) lineTableName(ctx context.Context, line *CasbinRule) (string, error) {
+	if !a.sharded() {
+		return a.getPtypeTableName(ctx, line.Ptype)
+	}
+	// Hash the value as given rather than as stored, so that the shard of
+	// a rule does not change with the key of WithFieldEncryptor or the
+	// codec of the column.
+	decoded := *line
+	if err := a.decodeRule(&decoded); err != nil {
+		return "", err
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(*decoded.fields()[a.shardKeyIndex]))
+	shard := int(h.Sum32() % uint32(len(a.shardTables)))
+	return a.joinTableName("", a.shardTables[shard]), nil
+}
+
+// ruleTableName returns the table storing the given rule.
+func (a *Adapter) ruleTableName(ctx context.Context, ptype string, rule []string) (string, error) {
+	line, err := a.toInstance(ptype, rule)
+	if err != nil {
+		return "", err
+	}
+	return a.lineTableName(ctx, line)
+}
+
+// ptypeTableNames returns the tables that may store rules of ptype.
+func (a *Adapter) ptypeTableNames(ctx context.Context, ptype string) ([]string, error) {
+	if a.sharded() {
+		return a.shardTableNames(), nil
+	}
+	table, err := a.getPtypeTableName(ctx, ptype)
+	if err != nil {
+		return nil, err
+	}
+	return []string{table}, nil
+}
+
+// movePolicy replaces the rows of table matching oldRule with newRule in the
+// shard of newRule, and returns the number of rows removed.
+func (a *Adapter) movePolicy(ctx context.Context, tx bun.Tx, table, ptype string, oldRule, newRule []string) (int64, error) {
+	res, err := a.buildRemoveQuery(ctx, tx, table, ptype, oldRule).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	if err := a.createPolicies(ctx, tx, ptype, [][]string{newRule}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
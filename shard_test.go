@@ -0,0 +1,113 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// shardTable names the shards of the sharding tests.
+func shardTable(shard int) string {
+	return fmt.Sprintf("casbin_rule_%d", shard)
+}
+
+// expectedShard returns the shard of key among 4 shards.
+func expectedShard(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % 4)
+}
+
+func TestHashShardingWrites(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithHashSharding(4, 0, shardTable))
+	used := make(map[int]bool)
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		f.reset()
+		shard := expectedShard(user)
+		used[shard] = true
+		if err := a.AddPolicy("p", "p", []string{user, "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.RemovePolicy("p", "p", []string{user, "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, prefix := range []string{"INSERT INTO ", "DELETE FROM "} {
+			queries := f.queries(prefix)
+			want := prefix + shardTable(shard) + " "
+			if len(queries) != 1 || !strings.HasPrefix(queries[0], want) {
+				t.Errorf("%s: got %q, want one statement on %s", user, queries, shardTable(shard))
+			}
+		}
+	}
+	if len(used) != 4 {
+		t.Errorf("the test rules cover shards %v, want all 4", used)
+	}
+}
+
+func TestHashShardingLoad(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithHashSharding(4, 0, shardTable))
+	for shard := 0; shard < 4; shard++ {
+		f.on("FROM " + shardTable(shard) + " ").returnsRules(
+			&CasbinRule{Id: 1, Ptype: "p", V0: fmt.Sprintf("user%d", shard), V1: "data1", V2: "read"})
+	}
+	m := newTestModel(t)
+	if err := a.LoadPolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rule := range m.GetPolicy("p", "p") {
+		got = append(got, rule[0])
+	}
+	sort.Strings(got)
+	want := []string{"user0", "user1", "user2", "user3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got subjects %q, want %q", got, want)
+	}
+}
+
+func TestHashShardingFilteredRemove(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithHashSharding(4, 0, shardTable))
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data1"); err != nil {
+		t.Fatal(err)
+	}
+	for shard := 0; shard < 4; shard++ {
+		if n := f.count("DELETE FROM " + shardTable(shard) + " "); n != 1 {
+			t.Errorf("got %d deletes on %s, want 1", n, shardTable(shard))
+		}
+	}
+}
+
+func TestHashShardingInvalid(t *testing.T) {
+	for _, option := range []Option{
+		WithHashSharding(0, 0, shardTable),
+		WithHashSharding(4, -1, shardTable),
+		WithHashSharding(4, maxFields, shardTable),
+		WithHashSharding(4, 0, func(int) string { return "casbin_rule; --" }),
+	} {
+		_, db := newFakeDB(t, "pg")
+		if _, err := NewAdapterWithClient(db, option); err == nil {
+			t.Error("got no error for an invalid sharding")
+		}
+	}
+}
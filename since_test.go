@@ -0,0 +1,91 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestLoadPolicySince(t *testing.T) {
+	since := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	a, f := newTestAdapter(t, "pg")
+	// Only the rule written after since matches the condition.
+	f.on(`WHERE ("updated_at" > '2024-03-01 12:00:00+00:00')`).
+		returnsRules(&CasbinRule{Id: 2, Ptype: "p", V0: "bob", V1: "data2", V2: "write"})
+
+	m := newTestModel(t, []string{"p", "alice", "data1", "read"})
+	if err := a.LoadPolicySince(context.Background(), m, since); err != nil {
+		t.Fatal(err)
+	}
+	got := m.GetPolicy("p", "p")
+	if len(got) != 2 || strings.Join(got[1], ",") != "bob,data2,write" {
+		t.Errorf("got rules %q, want the newer rule added to the model", got)
+	}
+	if !a.IsFiltered() {
+		t.Error("the adapter is not filtered after LoadPolicySince")
+	}
+	if queries := f.queries("SELECT"); len(queries) != 1 {
+		t.Errorf("got selects %q, want one", queries)
+	}
+}
+
+func TestLoadPolicySinceTimestampColumn(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithTimestampColumn("created_at"))
+	if err := a.LoadPolicySince(context.Background(), newTestModel(t), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count(`WHERE ("created_at" > '`); n != 1 {
+		t.Errorf("got %d selects on created_at, want 1", n)
+	}
+
+	_, db := newFakeDB(t, "pg")
+	if _, err := NewAdapterWithClient(db, WithTimestampColumn("updated_at; --")); err == nil {
+		t.Error("got no error for an invalid timestamp column")
+	}
+}
+
+func TestLoadPolicyLineByID(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on(`WHERE ("id" = 42)`).
+		returnsRules(&CasbinRule{Id: 42, Ptype: "g", V0: "bob", V1: "admin"})
+
+	m := newTestModel(t,
+		[]string{"p", "admin", "data1", "read"},
+		[]string{"g", "alice", "admin"},
+	)
+	if err := a.LoadPolicyLineByID(context.Background(), m, 42); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetPolicy("g", "g"); len(got) != 2 || strings.Join(got[1], ",") != "bob,admin" {
+		t.Errorf("got roles %q, want the loaded rule appended", got)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 {
+		t.Errorf("got rules %q, want the existing rule kept", got)
+	}
+
+	if err := a.LoadPolicyLineByID(context.Background(), m, 7); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got error %v for a missing id, want ErrPolicyNotFound", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrSpannerDialect is returned by NewAdapter for the "spanner" driver when no
+// dialect was given with WithSpannerDialect.
+var ErrSpannerDialect = errors.New("casbinbunadapter: spanner requires WithSpannerDialect")
+
+// WithSpannerDialect sets the bun dialect used for Cloud Spanner and switches
+// the adapter to Spanner-safe statements: SavePolicy empties the table with
+// DELETE instead of TRUNCATE, and rule ids are generated by the adapter as
+// random positive integers since Spanner has no auto-increment. NewAdapter
+// needs it for the "spanner" driver; with NewAdapterWithClient pass the same
+// dialect the client was created with.
+func WithSpannerDialect(d schema.Dialect) Option {
+	return func(a *Adapter) error {
+		a.spannerDialect = d
+		return nil
+	}
+}
+
+// newRuleID returns a random positive id. Random keys also spread the writes
+// across Spanner splits, unlike sequential ones.
+func newRuleID() (int64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b[:])>>1) | 1, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func init() {
+	// Spanner drivers are not imported by the tests; NewAdapter only needs
+	// the name.
+	sql.Register("spanner", fakeDriver{})
+}
+
+func TestSpannerSavePolicy(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithSpannerDialect(pgdialect.New()))
+	m := newTestModel(t,
+		[]string{"p", "alice", "data1", "read"},
+		[]string{"g", "alice", "admin"},
+	)
+	if err := a.SavePolicy(m); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("TRUNCATE"); n != 0 {
+		t.Errorf("got statements %q, want no TRUNCATE", f.queries(""))
+	}
+	if n := f.count("DELETE FROM public.casbin_rule WHERE (TRUE)"); n != 1 {
+		t.Errorf("got statements %q, want the table emptied with DELETE", f.queries(""))
+	}
+	inserts := f.queries("INSERT")
+	if len(inserts) != 1 || !strings.Contains(inserts[0], `("id", "ptype", "v0"`) {
+		t.Fatalf("got inserts %q, want the ids inserted", inserts)
+	}
+	if strings.Contains(inserts[0], "DEFAULT, 'p'") || strings.Contains(inserts[0], "DEFAULT, 'g'") {
+		t.Errorf("got insert %q, want ids generated by the adapter", inserts[0])
+	}
+}
+
+func TestSpannerAddPolicyReturningID(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithSpannerDialect(pgdialect.New()))
+	id, err := a.AddPolicyReturningID(context.Background(), "p", []string{"alice", "data1", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id <= 0 {
+		t.Errorf("got id %d, want a positive generated id", id)
+	}
+	inserts := f.queries("INSERT")
+	if len(inserts) != 1 || !strings.Contains(inserts[0], fmt.Sprintf("VALUES (%d, 'p'", id)) {
+		t.Errorf("got inserts %q, want id %d inserted", inserts, id)
+	}
+	if strings.Contains(inserts[0], "RETURNING") {
+		t.Errorf("got insert %q, want no RETURNING", inserts[0])
+	}
+}
+
+func TestSpannerNeedsDialect(t *testing.T) {
+	f, _ := newFakeDB(t, "pg")
+	if _, err := NewAdapter("spanner", f.dsn); !errors.Is(err, ErrSpannerDialect) {
+		t.Errorf("got error %v, want ErrSpannerDialect", err)
+	}
+	a, err := NewAdapter("spanner", f.dsn, WithSpannerDialect(pgdialect.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if err := a.Clear(a.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count("DELETE FROM"); n != 1 {
+		t.Errorf("got statements %q, want a DELETE", f.queries(""))
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidRows is returned by loads with WithStrictLoad when stored rows
+// cannot be turned into policy lines.
+var ErrInvalidRows = errors.New("rows cannot be loaded as policy lines")
+
+// WithStrictLoad makes loads fail with ErrInvalidRows, listing the ids of the
+// rows that have no ptype or only empty V columns, instead of silently
+// skipping them. The valid rows are still added to the model.
+func WithStrictLoad() Option {
+	return func(a *Adapter) error {
+		a.strictLoad = true
+		return nil
+	}
+}
+
+// loadRules adds lines to model, counting them as the rows of the operation
+// of ctx.
+func (a *Adapter) loadRules(ctx context.Context, lines []*CasbinRule, model model.Model) error {
+	var check loadCheck
+	if err := a.loadRuleBatch(ctx, lines, model, &check); err != nil {
+		return err
+	}
+	return check.err()
+}
+
+// loadRuleBatch adds lines to model like loadRules, recording the rows the
+// load validations reject in check.
+func (a *Adapter) loadRuleBatch(ctx context.Context, lines []*CasbinRule, model model.Model, check *loadCheck) error {
+	a.addOpRows(ctx, int64(len(lines)))
+	for _, line := range lines {
+		if isBlankRule(line) {
+			// Casbin ignores a line without values and panics on one
+			// without ptype, so neither is loaded.
+			if a.strictLoad {
+				check.invalid = append(check.invalid, strconv.FormatInt(line.Id, 10))
+			}
+			continue
+		}
+		if err := a.loadRule(line, model); err != nil {
+			return err
+		}
+		if a.detectDuplicates {
+			check.addRule(line)
+		}
+	}
+	return nil
+}
+
+// loadCheck holds the rows rejected by the load validations, across the
+// batches of one load.
+type loadCheck struct {
+	invalid []string
+
+	// firstIDs maps the ruleKey of the loaded rules to the id of their first
+	// row, for WithDetectDuplicatesOnLoad.
+	firstIDs   map[string]int64
+	duplicates []string
+}
+
+// err returns ErrInvalidRows or ErrDuplicateRows for the rejected rows, if
+// any.
+func (c *loadCheck) err() error {
+	if len(c.invalid) > 0 {
+		return errors.Wrapf(ErrInvalidRows, "ids %s", strings.Join(c.invalid, ", "))
+	}
+	if len(c.duplicates) > 0 {
+		return errors.Wrap(ErrDuplicateRows, strings.Join(c.duplicates, "; "))
+	}
+	return nil
+}
+
+// isBlankRule reports whether line has no ptype or no value to load.
+func isBlankRule(line *CasbinRule) bool {
+	if line.Ptype == "" {
+		return true
+	}
+	for _, field := range line.fields() {
+		if *field != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestStrictLoad(t *testing.T) {
+	rows := []*CasbinRule{
+		{Id: 1, Ptype: "p", V0: "alice", V1: "data1", V2: "read"},
+		{Id: 2, Ptype: "p"},
+		{Id: 3, Ptype: "", V0: "bob"},
+	}
+
+	a, f := newTestAdapter(t, "pg", WithStrictLoad())
+	f.on("SELECT").returnsRules(rows...)
+	m := newTestModel(t)
+	err := a.LoadPolicy(m)
+	if !errors.Is(err, ErrInvalidRows) || !strings.Contains(err.Error(), "ids 2, 3") {
+		t.Errorf("got error %v, want ErrInvalidRows for ids 2 and 3", err)
+	}
+	if got := m.GetPolicy("p", "p"); len(got) != 1 {
+		t.Errorf("got rules %q, want the valid row loaded", got)
+	}
+
+	// Loads are lenient by default.
+	a, f = newTestAdapter(t, "pg")
+	f.on("SELECT").returnsRules(rows...)
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Errorf("got error %v without WithStrictLoad", err)
+	}
+}
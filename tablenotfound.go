@@ -0,0 +1,62 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// ErrTableNotFound is returned by LoadPolicy, LoadFilteredPolicy and
+// SavePolicy when the policy table does not exist.
+var ErrTableNotFound = errors.New("casbinbunadapter: policy table not found")
+
+// checkTableNotFound replaces the error of a missing table with one matching
+// ErrTableNotFound, keeping the driver error in the chain. It is meant to be
+// deferred after mapError, so that it runs before it.
+func checkTableNotFound(err *error) {
+	if *err != nil && isTableNotFound(*err) {
+		*err = mapped(ErrTableNotFound,
+			errors.Wrap(*err, "policy table not found, create it with CreateTable or Migrate"))
+	}
+}
+
+// isTableNotFound reports whether err is the error of the dialect for a
+// query on a table that does not exist.
+func isTableNotFound(err error) bool {
+	var pgdriverErr interface{ Field(byte) string }
+	var pgxErr interface{ SQLState() string }
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	var myErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &pgdriverErr):
+		return pgdriverErr.Field('C') == "42P01"
+	case errors.As(err, &pgxErr):
+		return pgxErr.SQLState() == "42P01"
+	case errors.As(err, &mssqlErr):
+		return mssqlErr.SQLErrorNumber() == 208
+	case errors.As(err, &myErr):
+		return myErr.Number == 1146
+	}
+	// The SQLite drivers only expose the error in its message.
+	return strings.Contains(err.Error(), "no such table")
+}
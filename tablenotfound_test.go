@@ -0,0 +1,79 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTableNotFound(t *testing.T) {
+	tests := []struct {
+		dialect string
+		err     error
+	}{
+		{"pg", pgError("42P01", `relation "public.casbin_rule" does not exist`)},
+		{"mysql", mysqlError(1146, "Table 'app.casbin_rule' doesn't exist")},
+		{"mssql", mssqlError(208, "Invalid object name 'casbin_rule'.")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			a, f := newTestAdapter(t, tt.dialect)
+			f.on("casbin_rule").fails(tt.err)
+			for name, call := range map[string]func() error{
+				"LoadPolicy": func() error { return a.LoadPolicy(newTestModel(t)) },
+				"LoadFilteredPolicy": func() error {
+					return a.LoadFilteredPolicy(newTestModel(t), Filter{Ptype: []string{"p"}})
+				},
+				"SavePolicy": func() error {
+					return a.SavePolicy(newTestModel(t, []string{"p", "alice", "data1", "read"}))
+				},
+			} {
+				err := call()
+				if !errors.Is(err, ErrTableNotFound) {
+					t.Errorf("%s() = %v, want ErrTableNotFound", name, err)
+					continue
+				}
+				if !errors.Is(err, tt.err) {
+					t.Errorf("%s() = %v, want the driver error kept", name, err)
+				}
+				if !strings.Contains(err.Error(), "CreateTable or Migrate") {
+					t.Errorf("%s() = %v, want a hint to create the table", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTableNotFoundOtherErrors(t *testing.T) {
+	a, f := newTestAdapter(t, "pg")
+	f.on("SELECT").fails(pgError("42501", "permission denied for table casbin_rule"))
+	if err := a.LoadPolicy(newTestModel(t)); err == nil || errors.Is(err, ErrTableNotFound) {
+		t.Errorf("LoadPolicy() = %v, want an error other than ErrTableNotFound", err)
+	}
+}
+
+func TestIsTableNotFoundSQLite(t *testing.T) {
+	if !isTableNotFound(errors.New("no such table: casbin_rule")) {
+		t.Error("isTableNotFound() = false for the SQLite error")
+	}
+}
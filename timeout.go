@@ -0,0 +1,109 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// WithReadTimeout bounds the duration of every load and lookup: LoadPolicy,
+// LoadFilteredPolicy, LoadPolicySince, LoadPolicyLineByID, GetPolicy, Stats
+// and ExportCSV.
+func WithReadTimeout(d time.Duration) Option {
+	return func(a *Adapter) error {
+		a.readTimeout = d
+		return nil
+	}
+}
+
+// WithWriteTimeout bounds the duration of every operation that writes
+// policies: the save, add, remove and update methods, Clear, ImportCSV,
+// SavePartial and UpsertPolicy.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(a *Adapter) error {
+		a.writeTimeout = d
+		return nil
+	}
+}
+
+// WithDeadlineFromModelSize bounds LoadPolicy by base plus perThousand for
+// every started thousand rows of the tables, instead of the fixed timeout of
+// WithReadTimeout, so that large tables don't time out while small ones stay
+// tightly bounded. The rows are counted first, within base.
+func WithDeadlineFromModelSize(base, perThousand time.Duration) Option {
+	return func(a *Adapter) error {
+		if base <= 0 || perThousand < 0 {
+			return errors.Errorf("invalid load deadline %s + %s per 1000 rows", base, perThousand)
+		}
+		a.loadTimeoutBase = base
+		a.loadTimeoutPerThousand = perThousand
+		return nil
+	}
+}
+
+func (a *Adapter) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, a.readTimeout)
+}
+
+func (a *Adapter) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, a.writeTimeout)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// loadContext returns the context of LoadPolicy: the one of readContext, or
+// with WithDeadlineFromModelSize one bounded by the size of the tables in db.
+func (a *Adapter) loadContext(ctx context.Context, db *bun.DB) (context.Context, context.CancelFunc, error) {
+	if a.loadTimeoutBase <= 0 {
+		ctx, cancel := a.readContext(ctx)
+		return ctx, cancel, nil
+	}
+	countCtx, countCancel := withTimeout(ctx, a.loadTimeoutBase)
+	defer countCancel()
+	tables, err := a.getReadTableNames(countCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows int
+	for _, table := range tables {
+		n, err := a.newSelect(countCtx, db, (*CasbinRule)(nil), table).Count(countCtx)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows += n
+	}
+	ctx, cancel := withTimeout(ctx, a.loadTimeout(rows))
+	return ctx, cancel, nil
+}
+
+// loadTimeout returns the timeout of WithDeadlineFromModelSize for a load of
+// rows rows.
+func (a *Adapter) loadTimeout(rows int) time.Duration {
+	return a.loadTimeoutBase + time.Duration((rows+999)/1000)*a.loadTimeoutPerThousand
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+func TestWriteTimeout(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithReadTimeout(time.Second), WithWriteTimeout(20*time.Millisecond))
+	// Every statement takes longer than the write timeout.
+	f.on("").takes(50 * time.Millisecond)
+
+	err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v for a slow write, want context.DeadlineExceeded", err)
+	}
+	if err := a.LoadPolicy(newTestModel(t)); err != nil {
+		t.Errorf("got error %v for a slow load within the read timeout", err)
+	}
+	if _, err := a.Stats(context.Background()); err != nil {
+		t.Errorf("got error %v for slow stats within the read timeout", err)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithReadTimeout(20*time.Millisecond), WithWriteTimeout(time.Second))
+	f.on("").takes(50 * time.Millisecond)
+
+	if err := a.LoadPolicy(newTestModel(t)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v for a slow load, want context.DeadlineExceeded", err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Errorf("got error %v for a slow write within the write timeout", err)
+	}
+}
+
+func TestLoadTimeoutScalesWithRows(t *testing.T) {
+	a, _ := newTestAdapter(t, "pg", WithDeadlineFromModelSize(time.Second, 10*time.Second))
+	for _, test := range []struct {
+		rows int
+		want time.Duration
+	}{
+		{0, time.Second},
+		{1, 11 * time.Second},
+		{1000, 11 * time.Second},
+		{1001, 21 * time.Second},
+		{50000, 501 * time.Second},
+	} {
+		if got := a.loadTimeout(test.rows); got != test.want {
+			t.Errorf("loadTimeout(%d) = %s, want %s", test.rows, got, test.want)
+		}
+	}
+}
+
+// deadlineRecorder is a bun query hook recording the time left before the
+// deadline of the SELECTs loading rules.
+type deadlineRecorder struct {
+	left []time.Duration
+}
+
+func (r *deadlineRecorder) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if deadline, ok := ctx.Deadline(); ok && event.Operation() == "SELECT" && !strings.Contains(event.Query, "count(*)") {
+		r.left = append(r.left, time.Until(deadline))
+	}
+	return ctx
+}
+
+func (r *deadlineRecorder) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+func TestDeadlineFromModelSize(t *testing.T) {
+	for _, test := range []struct {
+		rows int64
+		want time.Duration
+	}{
+		{500, 11 * time.Second},
+		{25000, 251 * time.Second},
+	} {
+		f, db := newFakeDB(t, "pg")
+		recorder := new(deadlineRecorder)
+		db.AddQueryHook(recorder)
+		a, err := NewAdapterWithClient(db, WithDeadlineFromModelSize(time.Second, 10*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.on("count(*)").returns([]string{"count"}, []driver.Value{test.rows})
+		if err := a.LoadPolicy(newTestModel(t)); err != nil {
+			t.Fatal(err)
+		}
+		if len(recorder.left) != 1 {
+			t.Fatalf("%d rows: got %d loads with a deadline, want 1", test.rows, len(recorder.left))
+		}
+		if left := recorder.left[0]; left > test.want || left < test.want-time.Second {
+			t.Errorf("%d rows: got %s left to load, want about %s", test.rows, left, test.want)
+		}
+	}
+}
+
+func TestDeadlineFromModelSizeCountTimeout(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithDeadlineFromModelSize(20*time.Millisecond, time.Second))
+	f.on("count(*)").takes(50 * time.Millisecond)
+	if err := a.LoadPolicy(newTestModel(t)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v for a slow count, want context.DeadlineExceeded", err)
+	}
+	if n := f.count("FROM public.casbin_rule AS \"casbin_rule\" ORDER BY"); n != 0 {
+		t.Errorf("got %d loads after the count timed out, want none", n)
+	}
+}
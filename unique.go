@@ -0,0 +1,165 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// CreateUniqueConstraint creates, on each policy table of ctx, a unique index
+// over the ptype column and the V columns at the indices cols, or all V
+// columns if cols is empty, unless the table already has it. The index keeps
+// duplicate rules out and can serve as the conflict target of UpsertPolicy.
+//
+// SQL treats NULLs as distinct, so with WithNullSafeMatching, which
+// WithNullEmptyFields turns on, the index is built on COALESCE(v, ”) on
+// Postgres, SQLite and MySQL (8.0.13 or later), making NULL and empty V
+// columns equal. SQL Server and Spanner already index NULLs as equal values,
+// but there a NULL and an empty column still differ.
+//
+// MySQL keys are at most 3072 bytes, less than the VARCHAR(255) utf8mb4
+// columns of CreateTable take once more than two of them are indexed. The
+// index then covers an equal prefix of each column instead, so rules that
+// only differ after the first characters of a column, e.g. 85 of them with
+// all V columns indexed, count as duplicates there.
+func (a *Adapter) CreateUniqueConstraint(ctx context.Context, cols ...int) (err error) {
+	ctx, endOp := a.startOp(ctx, "CreateUniqueConstraint")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	if len(cols) == 0 {
+		for i := 0; i < a.fieldCount(); i++ {
+			cols = append(cols, i)
+		}
+	}
+	seen := make(map[int]bool, len(cols))
+	for _, col := range cols {
+		if col < 0 || col >= a.fieldCount() {
+			return errors.Errorf("v%d is not one of the %d V columns of the table", col, a.fieldCount())
+		}
+		if seen[col] {
+			return errors.Errorf("v%d is given twice", col)
+		}
+		seen[col] = true
+	}
+	tables, err := a.getTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := a.createUniqueIndex(ctx, table, cols); err != nil {
+			return errors.Wrapf(err, "creating unique index on %s", table)
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) createUniqueIndex(ctx context.Context, table string, cols []int) error {
+	index := uniqueIndexName(table, cols)
+	dialectName := a.client.Dialect().Name()
+	coalesce := a.nullSafe && a.spannerDialect == nil && dialectName != dialect.MSSQL
+	prefix := 0
+	if dialectName == dialect.MySQL {
+		prefix = mysqlKeyPrefix(len(cols) + 1)
+	}
+	exprs := []interface{}{a.keyPart(a.ptypeColumnName(), prefix)}
+	for _, col := range cols {
+		name := fmt.Sprintf("v%d", col)
+		column := bun.Ident(name)
+		switch {
+		case !coalesce:
+			exprs = append(exprs, a.keyPart(name, prefix))
+		case dialectName == dialect.MySQL && prefix > 0:
+			// Functional key parts take no prefix length, so LEFT cuts
+			// the value down instead; they need their own parentheses.
+			exprs = append(exprs, schema.SafeQuery("(LEFT(COALESCE(?, ''), ?))", []interface{}{column, prefix}))
+		case dialectName == dialect.MySQL:
+			exprs = append(exprs, schema.SafeQuery("(COALESCE(?, ''))", []interface{}{column}))
+		default:
+			exprs = append(exprs, schema.SafeQuery("COALESCE(?, '')", []interface{}{column}))
+		}
+	}
+	var q *bun.RawQuery
+	switch dialectName {
+	case dialect.MySQL:
+		q = a.client.NewRaw("CREATE UNIQUE INDEX ? ON ? (?)", bun.Ident(index), bun.Safe(table), bun.In(exprs))
+	case dialect.MSSQL:
+		q = a.client.NewRaw("IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = ? AND object_id = OBJECT_ID(?)) "+
+			"CREATE UNIQUE INDEX ? ON ? (?)", index, table, bun.Ident(index), bun.Safe(table), bun.In(exprs))
+	default:
+		q = a.client.NewRaw("CREATE UNIQUE INDEX IF NOT EXISTS ? ON ? (?)", bun.Ident(index), bun.Safe(table), bun.In(exprs))
+	}
+	_, err := q.Conn(a.queryConn(ctx, a.client)).Exec(ctx)
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1061 {
+		// MySQL has no IF NOT EXISTS for indexes; 1061 is its duplicate
+		// key name error.
+		return nil
+	}
+	return err
+}
+
+const (
+	// mysqlMaxKeyBytes is the longest key of an InnoDB index.
+	mysqlMaxKeyBytes = 3072
+	// mysqlColumnBytes is what a VARCHAR(255) utf8mb4 column takes in a key.
+	mysqlColumnBytes = 255 * 4
+)
+
+// mysqlKeyPrefix returns the number of characters of each of n indexed
+// columns that fit in a MySQL key, or 0 if the whole columns fit.
+func mysqlKeyPrefix(n int) int {
+	if n*mysqlColumnBytes <= mysqlMaxKeyBytes {
+		return 0
+	}
+	return mysqlMaxKeyBytes / 4 / n
+}
+
+// keyPart returns column as a key part of an index, limited to its first
+// prefix characters if prefix is not 0.
+func (a *Adapter) keyPart(column string, prefix int) interface{} {
+	if prefix == 0 {
+		return bun.Ident(column)
+	}
+	b := a.client.Formatter().AppendIdent(nil, column)
+	return bun.Safe(fmt.Sprintf("%s(%d)", b, prefix))
+}
+
+// uniqueIndexName returns the name of the unique index over cols of table,
+// e.g. casbin_rule_ptype_v0_v1_key.
+func uniqueIndexName(table string, cols []int) string {
+	base := table[strings.LastIndex(table, ".")+1:]
+	base = strings.Trim(base, "\"`[]")
+	var b strings.Builder
+	b.WriteString(base)
+	b.WriteString("_ptype")
+	for _, col := range cols {
+		fmt.Fprintf(&b, "_v%d", col)
+	}
+	b.WriteString("_key")
+	return b.String()
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCreateUniqueConstraint(t *testing.T) {
+	tests := []struct {
+		dialect string
+		options []Option
+		want    string
+	}{
+		{"pg", nil, `CREATE UNIQUE INDEX IF NOT EXISTS "casbin_rule_ptype_v0_v1_key" ON public.casbin_rule ("ptype", "v0", "v1")`},
+		{"pg", []Option{WithNullEmptyFields(true)}, `CREATE UNIQUE INDEX IF NOT EXISTS "casbin_rule_ptype_v0_v1_key" ON public.casbin_rule ("ptype", COALESCE("v0", ''), COALESCE("v1", ''))`},
+		{"mysql", nil, "CREATE UNIQUE INDEX `casbin_rule_ptype_v0_v1_key` ON casbin_rule (`ptype`, `v0`, `v1`)"},
+		{"mysql", []Option{WithNullEmptyFields(true)}, "CREATE UNIQUE INDEX `casbin_rule_ptype_v0_v1_key` ON casbin_rule (`ptype`, (COALESCE(`v0`, '')), (COALESCE(`v1`, '')))"},
+		{"mssql", []Option{WithNullEmptyFields(true)}, `IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = N'casbin_rule_ptype_v0_v1_key' AND object_id = OBJECT_ID(N'public.casbin_rule')) CREATE UNIQUE INDEX "casbin_rule_ptype_v0_v1_key" ON public.casbin_rule ("ptype", "v0", "v1")`},
+	}
+	for _, tt := range tests {
+		a, f := newTestAdapter(t, tt.dialect, tt.options...)
+		if err := a.CreateUniqueConstraint(context.Background(), 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.queries(""); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestCreateUniqueConstraintAllColumns(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithColumnCount(3))
+	if err := a.CreateUniqueConstraint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `CREATE UNIQUE INDEX IF NOT EXISTS "casbin_rule_ptype_v0_v1_v2_key" ON public.casbin_rule ("ptype", "v0", "v1", "v2")`
+	if got := f.queries(""); len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCreateUniqueConstraintExistsOnMySQL(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql")
+	f.on("CREATE UNIQUE INDEX").fails(mysqlError(1061, "Duplicate key name 'casbin_rule_ptype_v0_key'"))
+	if err := a.CreateUniqueConstraint(context.Background(), 0); err != nil {
+		t.Errorf("got error %v for an existing index, want none", err)
+	}
+}
+
+func TestCreateUniqueConstraintMySQLKeyLength(t *testing.T) {
+	tests := []struct {
+		options []Option
+		want    string
+	}{
+		{nil, "CREATE UNIQUE INDEX `casbin_rule_ptype_v0_v1_v2_v3_v4_v5_v6_v7_key` ON casbin_rule " +
+			"(`ptype`(85), `v0`(85), `v1`(85), `v2`(85), `v3`(85), `v4`(85), `v5`(85), `v6`(85), `v7`(85))"},
+		{[]Option{WithNullEmptyFields(true)}, "CREATE UNIQUE INDEX `casbin_rule_ptype_v0_v1_v2_v3_v4_v5_v6_v7_key` ON casbin_rule " +
+			"(`ptype`(85), (LEFT(COALESCE(`v0`, ''), 85)), (LEFT(COALESCE(`v1`, ''), 85)), (LEFT(COALESCE(`v2`, ''), 85)), " +
+			"(LEFT(COALESCE(`v3`, ''), 85)), (LEFT(COALESCE(`v4`, ''), 85)), (LEFT(COALESCE(`v5`, ''), 85)), " +
+			"(LEFT(COALESCE(`v6`, ''), 85)), (LEFT(COALESCE(`v7`, ''), 85)))"},
+	}
+	for _, tt := range tests {
+		a, f := newTestAdapter(t, "mysql", tt.options...)
+		if err := a.CreateUniqueConstraint(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.queries(""); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestMySQLKeyPrefix(t *testing.T) {
+	for n, want := range map[int]int{1: 0, 3: 0, 4: 192, 9: 85} {
+		if got := mysqlKeyPrefix(n); got != want {
+			t.Errorf("got prefix %d for %d columns, want %d", got, n, want)
+		}
+		if got := mysqlKeyPrefix(n); got*4*n > mysqlMaxKeyBytes {
+			t.Errorf("%d columns of %d characters exceed the MySQL key length", n, got)
+		}
+	}
+}
+
+func TestCreateUniqueConstraintTooLongOnMySQL(t *testing.T) {
+	a, f := newTestAdapter(t, "mysql")
+	f.on("CREATE UNIQUE INDEX").fails(mysqlError(1071, "Specified key was too long; max key length is 3072 bytes"))
+	if err := a.CreateUniqueConstraint(context.Background(), 0); err == nil {
+		t.Error("got no error for a key that is too long")
+	}
+}
+
+func TestCreateUniqueConstraintInvalid(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithColumnCount(3))
+	for _, cols := range [][]int{{-1}, {3}, {0, 0}} {
+		if err := a.CreateUniqueConstraint(context.Background(), cols...); err == nil {
+			t.Errorf("got no error for columns %v", cols)
+		}
+	}
+	if n := len(f.queries("")); n != 0 {
+		t.Errorf("got %d statements, want none", n)
+	}
+}
+
+func TestUniqueConstraintRejectsDuplicate(t *testing.T) {
+	a, f := newTestAdapter(t, "pg", WithErrorMapper(PostgresErrorMapper))
+	if err := a.CreateUniqueConstraint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The index lets the first insert of the rule through and rejects the
+	// second.
+	f.on("INSERT").times(1)
+	f.on("INSERT").fails(pgError("23505", `duplicate key value violates unique constraint "casbin_rule_ptype_v0_v1_v2_v3_v4_v5_v6_v7_key"`))
+	rule := []string{"alice", "data1", "read"}
+	if err := a.AddPolicy("p", "p", rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicy("p", "p", rule); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("got error %v for a duplicate rule, want ErrDuplicate", err)
+	}
+	if n := f.count("ROLLBACK"); n != 1 {
+		t.Errorf("got %d rollbacks, want 1", n)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUpsertNotSupported is returned by UpsertPolicy on dialects without an
+// INSERT ... ON CONFLICT equivalent.
+var ErrUpsertNotSupported = errors.New("upsert is not supported by this dialect")
+
+// WithConflictColumns sets the conflict target UpsertPolicy uses for ptype,
+// e.g. WithConflictColumns("g", "ptype", "v0", "v1"). The columns must be
+// covered by a unique index. Ptypes without a configured target conflict on
+// all policy columns.
+func WithConflictColumns(ptype string, columns ...string) Option {
+	return func(a *Adapter) error {
+		for _, column := range columns {
+			if err := validateIdentifier(column); err != nil {
+				return err
+			}
+		}
+		if a.conflictColumns == nil {
+			a.conflictColumns = make(map[string][]string)
+		}
+		a.conflictColumns[ptype] = columns
+		return nil
+	}
+}
+
+// UpsertPolicy inserts a policy rule, or updates the stored rule that
+// conflicts with it on the ptype's conflict columns (see WithConflictColumns).
+// MySQL ignores the conflict target and uses whichever unique index matched.
+func (a *Adapter) UpsertPolicy(ctx context.Context, ptype string, rule []string) (err error) {
+	ctx, endOp := a.startOp(ctx, "UpsertPolicy")
+	defer endOp(&err)
+	defer a.mapError(&err)
+	ctx, cancel := a.writeContext(ctx)
+	defer cancel()
+	columns := a.policyColumns()
+	target := a.conflictColumns[ptype]
+	if len(target) == 0 {
+		target = columns
+	}
+
+	update := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !containsString(target, column) {
+			update = append(update, column)
+		}
+	}
+
+	table, err := a.ruleTableName(ctx, ptype, rule)
+	if err != nil {
+		return err
+	}
+	return a.withTx(ctx, func(tx bun.Tx) error {
+		line, err := a.savePolicyLine(ptype, rule)
+		if err != nil {
+			return err
+		}
+		q := a.insertQuery(ctx, tx, table, line).Returning("")
+
+		switch a.client.Dialect().Name() {
+		case dialect.PG, dialect.SQLite:
+			if len(update) == 0 {
+				q.On("CONFLICT (?) DO NOTHING", bun.Safe(strings.Join(target, ", ")))
+				break
+			}
+			q.On("CONFLICT (?) DO UPDATE", bun.Safe(strings.Join(target, ", ")))
+			for _, column := range update {
+				q.Set("? = EXCLUDED.?", bun.Ident(column), bun.Ident(column))
+			}
+		case dialect.MySQL:
+			q.On("DUPLICATE KEY UPDATE")
+			for _, column := range update {
+				q.Set("? = VALUES(?)", bun.Ident(column), bun.Ident(column))
+			}
+			if len(update) == 0 {
+				q.Set("? = ?", bun.Ident(a.idColumnName()), bun.Ident(a.idColumnName()))
+			}
+		default:
+			return ErrUpsertNotSupported
+		}
+
+		if _, err := q.Exec(ctx); err != nil {
+			return err
+		}
+		return a.publish(ctx, tx, "UpsertPolicy", ptype, rule)
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUpsertPolicyConflictTarget(t *testing.T) {
+	tests := []struct {
+		dialect string
+		ptype   string
+		rule    []string
+		want    string
+	}{
+		{"pg", "p", []string{"alice", "data1", "read"},
+			`ON CONFLICT (ptype, v0, v1, v2) DO UPDATE SET "v3" = EXCLUDED."v3", "v4" = EXCLUDED."v4", "v5" = EXCLUDED."v5", "v6" = EXCLUDED."v6", "v7" = EXCLUDED."v7"`},
+		{"pg", "g", []string{"alice", "admin"},
+			`ON CONFLICT (ptype, v0, v1) DO UPDATE SET "v2" = EXCLUDED."v2", "v3" = EXCLUDED."v3", "v4" = EXCLUDED."v4", "v5" = EXCLUDED."v5", "v6" = EXCLUDED."v6", "v7" = EXCLUDED."v7"`},
+		{"pg", "p2", []string{"alice", "data1"},
+			`ON CONFLICT (ptype, v0, v1, v2, v3, v4, v5, v6, v7) DO NOTHING`},
+		{"mysql", "g", []string{"alice", "admin"},
+			"ON DUPLICATE KEY UPDATE `v2` = VALUES(`v2`), `v3` = VALUES(`v3`), `v4` = VALUES(`v4`), `v5` = VALUES(`v5`), `v6` = VALUES(`v6`), `v7` = VALUES(`v7`)"},
+		{"mysql", "p2", []string{"alice", "data1"},
+			"ON DUPLICATE KEY UPDATE `id` = `id`"},
+	}
+	for _, test := range tests {
+		a, f := newTestAdapter(t, test.dialect,
+			WithConflictColumns("p", "ptype", "v0", "v1", "v2"),
+			WithConflictColumns("g", "ptype", "v0", "v1"))
+		if err := a.UpsertPolicy(context.Background(), test.ptype, test.rule); err != nil {
+			t.Fatal(err)
+		}
+		got := f.queries("INSERT")
+		if len(got) != 1 || !strings.HasSuffix(got[0], test.want) {
+			t.Errorf("%s upsert of %s ran %q, want a query ending with %q", test.dialect, test.ptype, got, test.want)
+		}
+	}
+}
+
+func TestUpsertPolicyUnsupported(t *testing.T) {
+	a, f := newTestAdapter(t, "mssql")
+	if err := a.UpsertPolicy(context.Background(), "p", []string{"alice", "data1", "read"}); !errors.Is(err, ErrUpsertNotSupported) {
+		t.Errorf("got error %v, want ErrUpsertNotSupported", err)
+	}
+	if n := f.count("INSERT"); n != 0 {
+		t.Errorf("got %d inserts, want none", n)
+	}
+}
+
+func TestConflictColumnsValidated(t *testing.T) {
+	_, db := newFakeDB(t, "pg")
+	if _, err := NewAdapterWithClient(db, WithConflictColumns("g", "ptype", "v0); DROP TABLE casbin_rule; --")); err == nil {
+		t.Error("got no error for an invalid conflict column")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pkg/errors"
+)
+
+// WithSoftValidateModel checks when the adapter is created that every policy
+// and role definition of m fits in the V columns of the table (see
+// WithColumnCount), so that rules are not rejected one by one later on.
+func WithSoftValidateModel(m model.Model) Option {
+	return func(a *Adapter) error {
+		a.validateModel = m
+		return nil
+	}
+}
+
+// checkModel validates the model given to WithSoftValidateModel.
+func (a *Adapter) checkModel() error {
+	if a.validateModel == nil {
+		return nil
+	}
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range a.validateModel[sec] {
+			if len(ast.Tokens) > a.fieldCount() {
+				return errors.Errorf("%s definition has %d tokens, the table only has %d V columns",
+					ptype, len(ast.Tokens), a.fieldCount())
+			}
+		}
+	}
+	return nil
+}
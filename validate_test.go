@@ -0,0 +1,75 @@
+// Copyright (c) 2022 cuipeiyu (i@cuipeiyu.com)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package casbinbunadapter
+
+import (
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+func TestSoftValidateModel(t *testing.T) {
+	// p has 7 tokens and g has 2.
+	wide, err := model.NewModelFromString(`
+[request_definition]
+r = sub, dom, obj, act, env, region, level
+
+[policy_definition]
+p = sub, dom, obj, act, env, region, level
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name    string
+		model   model.Model
+		columns int
+		fits    bool
+	}{
+		{name: "fitting", model: newTestModel(t), columns: 3, fits: true},
+		{name: "fitting default columns", model: wide, fits: true},
+		{name: "over-sized", model: newTestModel(t), columns: 2},
+		{name: "over-sized wide", model: wide, columns: 6},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, db := newFakeDB(t, "pg")
+			options := []Option{WithSoftValidateModel(test.model)}
+			if test.columns != 0 {
+				options = append(options, WithColumnCount(test.columns))
+			}
+			_, err := NewAdapterWithClient(db, options...)
+			if test.fits && err != nil {
+				t.Errorf("got error %v for a fitting model", err)
+			}
+			if !test.fits && err == nil {
+				t.Error("got no error for an over-sized model")
+			}
+		})
+	}
+}